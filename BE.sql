@@ -9,3 +9,521 @@ CREATE TABLE "alamat_pemesanan" (
     "country" VARCHAR(50) NOT NULL, -- Negara
     "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP -- Tanggal pembuatan alamat
 );
+
+-- Quote checkout: menyimpan rincian harga yang ditampilkan ke pembeli
+CREATE TABLE "checkout_quote" (
+    "id" VARCHAR(64) PRIMARY KEY, -- ID quote yang dikirim kembali saat CreateOrder
+    "user_id" INT NOT NULL, -- Pemilik quote (akun.id_user)
+    "request" JSONB NOT NULL, -- Payload keranjang asli
+    "quote" JSONB NOT NULL, -- Rincian harga per produk dan per peternakan
+    "grand_total" NUMERIC(14,2) NOT NULL, -- Total yang harus dibayar
+    "expires_at" TIMESTAMP NOT NULL, -- Batas waktu quote dapat dipakai
+    "used_at" TIMESTAMP, -- Waktu quote dipakai untuk membuat order
+    "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP -- Tanggal pembuatan quote
+);
+
+CREATE TABLE "voucher" (
+    "id" SERIAL PRIMARY KEY, -- ID unik voucher
+    "code" VARCHAR(50) NOT NULL UNIQUE, -- Kode voucher yang dimasukkan pembeli
+    "farm_id" INT, -- Jika diisi, voucher hanya berlaku untuk produk peternakan ini
+    "discount_percent" NUMERIC(5,2) NOT NULL DEFAULT 0, -- Diskon persen dari subtotal
+    "discount_amount" NUMERIC(14,2) NOT NULL DEFAULT 0, -- Diskon nominal
+    "max_discount" NUMERIC(14,2), -- Batas maksimum diskon
+    "min_purchase" NUMERIC(14,2) NOT NULL DEFAULT 0, -- Minimum subtotal agar voucher berlaku
+    "valid_from" TIMESTAMP, -- Awal masa berlaku
+    "valid_until" TIMESTAMP, -- Akhir masa berlaku
+    "is_active" BOOLEAN NOT NULL DEFAULT TRUE, -- Status aktif voucher
+    "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP -- Tanggal pembuatan voucher
+);
+
+ALTER TABLE "invoice"
+    ADD COLUMN "shipping_cost" NUMERIC(14,2), -- Total ongkir
+    ADD COLUMN "discount_amount" NUMERIC(14,2) DEFAULT 0, -- Total diskon voucher
+    ADD COLUMN "tax_amount" NUMERIC(14,2) DEFAULT 0, -- Total pajak
+    ADD COLUMN "quote_id" VARCHAR(64) REFERENCES "checkout_quote" ("id"); -- Quote yang dipakai saat checkout
+
+-- Checkout induk: satu pembayaran untuk keranjang yang berisi produk dari beberapa peternakan
+CREATE TABLE "checkout" (
+    "id" SERIAL PRIMARY KEY, -- ID unik checkout
+    "user_id" INT NOT NULL, -- Pembeli (akun.id_user)
+    "checkout_number" VARCHAR(100) NOT NULL UNIQUE, -- Nomor checkout yang ditampilkan ke pembeli
+    "payment_status" VARCHAR(50) NOT NULL DEFAULT 'Pending', -- Status pembayaran gabungan
+    "payment_method" VARCHAR(100), -- Metode pembayaran
+    "total_amount" NUMERIC(14,2) NOT NULL DEFAULT 0, -- Jumlah seluruh sub-invoice
+    "proof_of_transfer" VARCHAR(255), -- Bukti transfer untuk seluruh checkout
+    "quote_id" VARCHAR(64) REFERENCES "checkout_quote" ("id"), -- Quote yang dipakai saat checkout
+    "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Tanggal checkout
+    "updated_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP -- Tanggal pembaruan
+);
+
+ALTER TABLE "invoice"
+    ADD COLUMN "checkout_id" INT REFERENCES "checkout" ("id"), -- Checkout induk dari sub-invoice
+    ADD COLUMN "farm_id" INT REFERENCES "farms" ("id"); -- Peternakan pemilik sub-invoice
+
+-- Invoice lama: ambil peternakan dari produk pertama pada invoice
+UPDATE "invoice" i SET "farm_id" = (
+    SELECT fp.farm_id FROM orders o JOIN farm_products fp ON fp.id = o.product_id
+    WHERE o.invoice_id = i.id ORDER BY o.id LIMIT 1
+) WHERE i.farm_id IS NULL;
+
+CREATE INDEX "invoice_farm_id_idx" ON "invoice" ("farm_id");
+CREATE INDEX "invoice_checkout_id_idx" ON "invoice" ("checkout_id");
+
+-- Keranjang belanja per akun
+CREATE TABLE "cart_item" (
+    "id" SERIAL PRIMARY KEY, -- ID unik item keranjang
+    "akun_id" INT NOT NULL REFERENCES "akun" ("id_user") ON DELETE CASCADE, -- Pemilik keranjang
+    "product_id" INT NOT NULL REFERENCES "farm_products" ("id") ON DELETE CASCADE, -- Produk yang dipilih
+    "quantity" INT NOT NULL CHECK ("quantity" > 0), -- Jumlah yang dipesan
+    "price_at_add" NUMERIC(10,2) NOT NULL, -- Harga saat produk dimasukkan / terakhir disetujui
+    "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Tanggal ditambahkan
+    "updated_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Tanggal pembaruan
+    UNIQUE ("akun_id", "product_id")
+);
+
+-- Snapshot produk pada setiap baris order agar riwayat tidak ikut berubah saat produk diubah atau dihapus
+ALTER TABLE "orders"
+    ADD COLUMN "product_name" VARCHAR(255), -- Nama produk saat dibeli
+    ADD COLUMN "unit_price" NUMERIC(10,2), -- Harga satuan saat dibeli
+    ADD COLUMN "unit" VARCHAR(20), -- Satuan penjualan saat dibeli
+    ADD COLUMN "image_url" TEXT; -- Gambar produk saat dibeli
+
+-- Harga satuan diturunkan dari baris order itu sendiri, bukan dari harga produk saat ini
+UPDATE "orders" o
+SET "product_name" = fp."name",
+    "unit_price" = COALESCE(ROUND(o."total_harga"::NUMERIC / NULLIF(o."quantity", 0), 2), 0),
+    "unit" = 'kg',
+    "image_url" = COALESCE(fp."image_url", '')
+FROM "farm_products" fp
+WHERE fp."id" = o."product_id" AND o."product_name" IS NULL;
+
+UPDATE "orders"
+SET "product_name" = '',
+    "unit_price" = COALESCE(ROUND("total_harga"::NUMERIC / NULLIF("quantity", 0), 2), 0),
+    "unit" = 'kg',
+    "image_url" = ''
+WHERE "product_name" IS NULL;
+
+ALTER TABLE "orders"
+    ALTER COLUMN "product_name" SET NOT NULL,
+    ALTER COLUMN "unit_price" SET NOT NULL,
+    ALTER COLUMN "unit" SET NOT NULL,
+    ALTER COLUMN "image_url" SET DEFAULT '',
+    ALTER COLUMN "image_url" SET NOT NULL;
+
+-- Produk boleh dihapus tanpa merusak riwayat order
+ALTER TABLE "orders" ALTER COLUMN "product_id" DROP NOT NULL;
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "orders_product_id_fkey";
+ALTER TABLE "orders" ADD CONSTRAINT "orders_product_id_fkey"
+    FOREIGN KEY ("product_id") REFERENCES "farm_products" ("id") ON DELETE SET NULL;
+
+-- Satuan jual dan kuantitas desimal
+ALTER TABLE "farm_products"
+    ADD COLUMN "sale_unit" VARCHAR(10) NOT NULL DEFAULT 'kg' CHECK ("sale_unit" IN ('kg', 'piece', 'tray', 'liter')), -- Satuan jual produk
+    ADD COLUMN "stock_per_unit" NUMERIC(12,3) NOT NULL DEFAULT 1 CHECK ("stock_per_unit" > 0), -- Konversi satu satuan jual ke stok (kg)
+    ADD COLUMN "price_per_unit" NUMERIC(10,2), -- Harga per satuan jual, NULL berarti price_per_kg * stock_per_unit
+    ADD COLUMN "min_qty" NUMERIC(12,3) NOT NULL DEFAULT 1 CHECK ("min_qty" > 0), -- Minimal pembelian
+    ADD COLUMN "step_qty" NUMERIC(12,3) NOT NULL DEFAULT 1 CHECK ("step_qty" > 0); -- Kelipatan pembelian
+
+ALTER TABLE "orders" ALTER COLUMN "quantity" TYPE NUMERIC(12,3);
+ALTER TABLE "cart_item" ALTER COLUMN "quantity" TYPE NUMERIC(12,3);
+
+-- Produk catch-weight (hewan hidup / karkas utuh) ditagih berdasarkan berat timbang
+ALTER TABLE "farm_products"
+    ADD COLUMN "catch_weight" BOOLEAN NOT NULL DEFAULT FALSE, -- Harga final ditentukan saat penimbangan
+    ADD COLUMN "weight_tolerance_percent" NUMERIC(5,2) NOT NULL DEFAULT 10; -- Batas selisih berat yang boleh ditagihkan
+
+ALTER TABLE "orders"
+    ADD COLUMN "catch_weight" BOOLEAN NOT NULL DEFAULT FALSE, -- Baris order catch-weight
+    ADD COLUMN "estimated_weight_kg" NUMERIC(12,3), -- Estimasi berat saat order
+    ADD COLUMN "actual_weight_kg" NUMERIC(12,3), -- Berat aktual hasil timbang
+    ADD COLUMN "price_per_kg" NUMERIC(10,2), -- Harga per kg saat order
+    ADD COLUMN "weight_tolerance_percent" NUMERIC(5,2), -- Toleransi berat saat order
+    ADD COLUMN "weighed_at" TIMESTAMP; -- Waktu penimbangan
+
+-- Selisih tagihan akibat penimbangan
+CREATE TABLE "invoice_adjustment" (
+    "id" SERIAL PRIMARY KEY, -- ID unik penyesuaian
+    "invoice_id" INT NOT NULL REFERENCES "invoice" ("id") ON DELETE CASCADE, -- Invoice yang disesuaikan
+    "order_id" INT NOT NULL REFERENCES "orders" ("id") ON DELETE CASCADE, -- Baris order yang ditimbang
+    "estimated_weight_kg" NUMERIC(12,3) NOT NULL, -- Estimasi berat
+    "actual_weight_kg" NUMERIC(12,3) NOT NULL, -- Berat aktual
+    "charged_weight_kg" NUMERIC(12,3) NOT NULL, -- Berat yang ditagihkan setelah toleransi
+    "amount" NUMERIC(12,2) NOT NULL, -- Selisih harga produk (positif = tagihan tambahan, negatif = kredit)
+    "tax_amount" NUMERIC(12,2) NOT NULL DEFAULT 0, -- Selisih pajak
+    "type" VARCHAR(10) NOT NULL CHECK ("type" IN ('charge', 'credit')), -- Jenis penyesuaian
+    "status" VARCHAR(10) NOT NULL CHECK ("status" IN ('Applied', 'Pending', 'Settled')), -- Applied = masuk ke invoice, Pending = perlu diselesaikan
+    "created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Tanggal dibuat
+    "settled_at" TIMESTAMP -- Tanggal diselesaikan
+);
+CREATE INDEX "idx_invoice_adjustment_invoice" ON "invoice_adjustment" ("invoice_id");
+
+-- Kode peternakan untuk penomoran invoice
+ALTER TABLE "farms" ADD COLUMN "code" VARCHAR(20) UNIQUE; -- Kode singkat peternakan, mis. SBR01
+UPDATE "farms" SET "code" = 'F' || LPAD("id"::TEXT, GREATEST(LENGTH("id"::TEXT), 4), '0') WHERE "code" IS NULL;
+
+-- Counter nomor dokumen per jenis, lingkup dan tahun; dikunci per baris di dalam transaksi
+CREATE TABLE "number_sequence" (
+    "kind" VARCHAR(30) NOT NULL, -- Jenis dokumen, mis. invoice atau checkout
+    "scope" VARCHAR(30) NOT NULL, -- Lingkup counter, mis. id peternakan (bukan kodenya, yang dapat diganti)
+    "year" INT NOT NULL, -- Tahun counter
+    "last_value" BIGINT NOT NULL DEFAULT 0, -- Nomor terakhir yang terbit
+    "updated_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Tanggal pembaruan
+    PRIMARY KEY ("kind", "scope", "year")
+);
+
+-- Invoice dibatalkan, bukan dihapus, agar nomor tidak hilang
+ALTER TABLE "invoice"
+    ADD COLUMN "cancelled_at" TIMESTAMP, -- Tanggal pembatalan
+    ADD COLUMN "cancel_reason" TEXT; -- Alasan pembatalan
+ALTER TABLE "invoice" ADD CONSTRAINT "invoice_invoice_number_key" UNIQUE ("invoice_number");
+
+-- Kode verifikasi publik untuk invoice tercetak (QR code)
+ALTER TABLE "invoice" ADD COLUMN "verification_code" VARCHAR(32) UNIQUE; -- HMAC nomor invoice, diisi saat invoice dibuat atau pertama kali dicetak
+
+-- Batas waktu pembayaran per peternakan; invoice Pending yang lewat due_date otomatis Expired
+ALTER TABLE "farms" ADD COLUMN "payment_window_hours" INT NOT NULL DEFAULT 168 CHECK ("payment_window_hours" > 0); -- 7 hari
+CREATE INDEX "idx_invoice_pending_due" ON "invoice" ("due_date") WHERE "payment_status" = 'Pending';
+
+-- Antrean job latar belakang (helper/jobs)
+CREATE TABLE "job" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "kind" VARCHAR(100) NOT NULL, -- Jenis job, menentukan handler
+    "payload" JSONB NOT NULL DEFAULT '{}',
+    "status" VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK ("status" IN ('queued', 'running', 'done', 'dead')),
+    "run_at" TIMESTAMP NOT NULL DEFAULT NOW(), -- Paling cepat dijalankan pada waktu ini
+    "attempts" INT NOT NULL DEFAULT 0,
+    "max_attempts" INT NOT NULL DEFAULT 5,
+    "last_error" TEXT,
+    "locked_at" TIMESTAMP, -- Diisi saat diambil worker; job running yang terlalu lama diantrekan ulang
+    "finished_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_job_queued" ON "job" ("run_at", "id") WHERE "status" = 'queued';
+
+-- Job yang gagal setelah semua percobaan
+CREATE TABLE "job_dead_letter" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "job_id" BIGINT NOT NULL REFERENCES "job" ("id") ON DELETE CASCADE,
+    "kind" VARCHAR(100) NOT NULL,
+    "payload" JSONB NOT NULL,
+    "attempts" INT NOT NULL,
+    "last_error" TEXT NOT NULL,
+    "failed_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Jadwal berulang (cron); baris dikunci saat membuat job agar tiap jadwal hanya berjalan sekali
+CREATE TABLE "job_schedule" (
+    "name" VARCHAR(100) PRIMARY KEY,
+    "kind" VARCHAR(100) NOT NULL,
+    "next_run_at" TIMESTAMP NOT NULL,
+    "last_run_at" TIMESTAMP,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Waktu pembayaran invoice disetujui peternak
+ALTER TABLE "invoice" ADD COLUMN "paid_at" TIMESTAMP;
+
+-- Outbox domain event, ditulis di transaksi yang sama dengan perubahan datanya (helper/events)
+CREATE TABLE "outbox" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "event_type" VARCHAR(100) NOT NULL, -- OrderPlaced, PaymentApproved, ShipmentDelivered, ...
+    "aggregate_type" VARCHAR(50) NOT NULL, -- invoice, akun, farm
+    "aggregate_id" VARCHAR(100) NOT NULL,
+    "payload" JSONB NOT NULL DEFAULT '{}',
+    "occurred_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "attempts" INT NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "last_error" TEXT,
+    "dispatched_at" TIMESTAMP, -- Semua subscriber berhasil
+    "failed_at" TIMESTAMP -- Berhenti dicoba setelah batas percobaan
+);
+CREATE INDEX "idx_outbox_pending" ON "outbox" ("id") WHERE "dispatched_at" IS NULL AND "failed_at" IS NULL;
+CREATE INDEX "idx_outbox_aggregate" ON "outbox" ("aggregate_type", "aggregate_id");
+
+-- Subscriber yang sudah berhasil menerima event, agar tidak dipanggil ulang saat event dicoba lagi
+CREATE TABLE "outbox_delivery" (
+    "event_id" BIGINT NOT NULL REFERENCES "outbox" ("id") ON DELETE CASCADE,
+    "subscriber" VARCHAR(100) NOT NULL,
+    "delivered_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("event_id", "subscriber")
+);
+
+-- Notifikasi (helper/notify)
+ALTER TABLE "akun" ADD COLUMN "language" VARCHAR(5) NOT NULL DEFAULT 'id'; -- Bahasa template notifikasi: id atau en
+
+-- Inbox notifikasi di aplikasi untuk akun maupun pengirim
+CREATE TABLE "notifications" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "recipient_type" VARCHAR(20) NOT NULL CHECK ("recipient_type" IN ('akun', 'pengirim')),
+    "recipient_id" BIGINT NOT NULL,
+    "event" VARCHAR(100) NOT NULL,
+    "title" VARCHAR(255) NOT NULL,
+    "body" TEXT NOT NULL,
+    "link" TEXT,
+    "data" JSONB NOT NULL DEFAULT '{}', -- mis. invoice_id untuk membuka halaman terkait
+    "read_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_notifications_recipient" ON "notifications" ("recipient_type", "recipient_id", "id" DESC);
+
+-- Preferensi channel; channel tanpa baris dianggap aktif
+CREATE TABLE "notification_preference" (
+    "recipient_type" VARCHAR(20) NOT NULL,
+    "recipient_id" BIGINT NOT NULL,
+    "channel" VARCHAR(20) NOT NULL CHECK ("channel" IN ('in_app', 'whatsapp', 'email')),
+    "enabled" BOOLEAN NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("recipient_type", "recipient_id", "channel")
+);
+
+-- Log setiap percobaan pengiriman notifikasi
+CREATE TABLE "notification_delivery" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "dedupe_key" VARCHAR(100) NOT NULL, -- mis. event:<id outbox>
+    "recipient_type" VARCHAR(20) NOT NULL,
+    "recipient_id" BIGINT NOT NULL,
+    "event" VARCHAR(100) NOT NULL,
+    "channel" VARCHAR(20) NOT NULL,
+    "status" VARCHAR(20) NOT NULL CHECK ("status" IN ('sent', 'failed')),
+    "error" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_notification_delivery_key" ON "notification_delivery" ("dedupe_key", "recipient_type", "recipient_id", "channel");
+
+-- Inbox: hitung notifikasi belum dibaca dengan cepat
+CREATE INDEX "idx_notifications_unread" ON "notifications" ("recipient_type", "recipient_id") WHERE "read_at" IS NULL;
+
+-- Batas stok untuk event product.low_stock
+ALTER TABLE "farm_products" ADD COLUMN "low_stock_threshold_kg" NUMERIC(12,3); -- NULL berarti tidak dipantau
+
+-- Webhook keluar milik peternakan
+CREATE TABLE "webhook_endpoint" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "farm_id" BIGINT NOT NULL REFERENCES "farms" ("id") ON DELETE CASCADE,
+    "url" TEXT NOT NULL,
+    "description" VARCHAR(255),
+    "event_types" TEXT NOT NULL, -- Dipisah koma: order.created,payment.approved,shipment.updated,product.low_stock
+    "secret" VARCHAR(100) NOT NULL, -- Kunci HMAC-SHA256 untuk header X-Webhook-Signature
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_webhook_endpoint_farm" ON "webhook_endpoint" ("farm_id");
+
+CREATE TABLE "webhook_delivery" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "endpoint_id" BIGINT NOT NULL REFERENCES "webhook_endpoint" ("id") ON DELETE CASCADE,
+    "event_id" BIGINT NOT NULL REFERENCES "outbox" ("id"),
+    "event_type" VARCHAR(50) NOT NULL,
+    "payload" JSONB NOT NULL, -- Body yang dikirim, sama persis saat replay
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'succeeded', 'failed')),
+    "attempts" INT NOT NULL DEFAULT 0,
+    "max_attempts" INT NOT NULL DEFAULT 5, -- Ditambah saat replay
+    "last_status_code" INT,
+    "last_error" TEXT,
+    "delivered_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("endpoint_id", "event_id")
+);
+
+CREATE TABLE "webhook_attempt" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "delivery_id" BIGINT NOT NULL REFERENCES "webhook_delivery" ("id") ON DELETE CASCADE,
+    "attempt" INT NOT NULL,
+    "status_code" INT,
+    "response_body" TEXT, -- Maksimal 1 KB pertama
+    "error" TEXT,
+    "duration_ms" BIGINT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_webhook_attempt_delivery" ON "webhook_attempt" ("delivery_id");
+
+-- Riwayat perubahan status invoice, order dan pengiriman
+CREATE TABLE "order_events" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "invoice_id" INT NOT NULL REFERENCES "invoice" ("id") ON DELETE CASCADE,
+    "entity_type" VARCHAR(20) NOT NULL CHECK ("entity_type" IN ('invoice', 'order', 'shipment')),
+    "entity_id" BIGINT NOT NULL, -- invoice.id, orders.id atau proses_pengiriman.id
+    "previous_status" VARCHAR(50), -- NULL untuk data baru
+    "new_status" VARCHAR(50) NOT NULL,
+    "actor_type" VARCHAR(20) NOT NULL CHECK ("actor_type" IN ('akun', 'pengirim', 'system')),
+    "actor_id" BIGINT, -- akun.id_user atau pengirim.id; NULL untuk system
+    "actor_role" VARCHAR(50) NOT NULL, -- buyer, farm, courier, system atau nama role akun
+    "reason" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_order_events_invoice" ON "order_events" ("invoice_id", "created_at");
+
+-- Audit log operasi istimewa (akun, role, menu, pembatalan invoice); hanya boleh INSERT
+CREATE TABLE "audit_log" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "actor_type" VARCHAR(20) NOT NULL, -- akun atau pengirim
+    "actor_id" BIGINT, -- Tanpa FK agar catatan tetap ada setelah akun dihapus
+    "action" VARCHAR(100) NOT NULL, -- mis. akun.update, role.delete, invoice.cancel
+    "target_type" VARCHAR(50) NOT NULL,
+    "target_id" VARCHAR(100) NOT NULL,
+    "before" JSONB, -- NULL untuk data baru; kolom password/secret tidak disimpan
+    "after" JSONB, -- NULL untuk data yang dihapus
+    "changes" JSONB, -- {"kolom": {"from": ..., "to": ...}}
+    "ip" VARCHAR(45),
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_audit_log_actor" ON "audit_log" ("actor_type", "actor_id");
+CREATE INDEX "idx_audit_log_target" ON "audit_log" ("target_type", "target_id");
+CREATE INDEX "idx_audit_log_action" ON "audit_log" ("action" varchar_pattern_ops);
+
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_log_no_update_delete" BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE FUNCTION "audit_log_append_only"();
+CREATE TRIGGER "audit_log_no_truncate" BEFORE TRUNCATE ON "audit_log"
+    FOR EACH STATEMENT EXECUTE FUNCTION "audit_log_append_only"();
+
+-- Pengajuan akun menjadi peternak (menggantikan koleksi Mongo req_peternakan)
+CREATE TABLE "farm_application" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "user_id" INT NOT NULL REFERENCES "akun" ("id_user") ON DELETE CASCADE, -- Pemohon
+    "status" VARCHAR(20) NOT NULL DEFAULT 'submitted'
+        CHECK ("status" IN ('submitted', 'under_review', 'needs_info', 'approved', 'rejected')),
+    "keterangan" TEXT NOT NULL DEFAULT '', -- Catatan bebas dari pemohon
+    -- Data peternakan yang diusulkan, disalin ke farms/addressfarm saat disetujui
+    "name" VARCHAR(255) NOT NULL,
+    "farm_type" VARCHAR(100) NOT NULL DEFAULT '',
+    "phonenumber_farm" VARCHAR(20) NOT NULL DEFAULT '',
+    "email" VARCHAR(255) NOT NULL DEFAULT '',
+    "description" TEXT NOT NULL DEFAULT '',
+    "street" VARCHAR(255) NOT NULL,
+    "city" VARCHAR(100) NOT NULL,
+    "state" VARCHAR(100) NOT NULL,
+    "postal_code" VARCHAR(20) NOT NULL,
+    "country" VARCHAR(100) NOT NULL,
+    "lat" DOUBLE PRECISION NOT NULL,
+    "lon" DOUBLE PRECISION NOT NULL,
+    "rejection_reason" TEXT, -- Wajib jika status rejected
+    "reviewer_id" INT REFERENCES "akun" ("id_user") ON DELETE SET NULL, -- Admin yang terakhir meninjau
+    "reviewed_at" TIMESTAMP,
+    "farm_id" INT REFERENCES "farms" ("id") ON DELETE SET NULL, -- Peternakan yang dibuat saat disetujui
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ("status" <> 'rejected' OR "rejection_reason" IS NOT NULL)
+);
+-- Satu akun hanya boleh memiliki satu pengajuan yang masih berjalan
+CREATE UNIQUE INDEX "uq_farm_application_open" ON "farm_application" ("user_id")
+    WHERE "status" IN ('submitted', 'under_review', 'needs_info');
+CREATE INDEX "idx_farm_application_status" ON "farm_application" ("status", "id");
+
+CREATE TABLE "farm_application_document" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "application_id" BIGINT NOT NULL REFERENCES "farm_application" ("id") ON DELETE CASCADE,
+    "doc_type" VARCHAR(30) NOT NULL CHECK ("doc_type" IN ('id_card', 'farm_photo', 'business_permit')),
+    "url" TEXT NOT NULL,
+    "file_name" VARCHAR(255) NOT NULL DEFAULT '',
+    "uploaded_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_farm_application_document_app" ON "farm_application_document" ("application_id", "doc_type");
+
+-- Riwayat status dan komentar peninjau/pemohon; to_status = from_status untuk komentar saja
+CREATE TABLE "farm_application_event" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "application_id" BIGINT NOT NULL REFERENCES "farm_application" ("id") ON DELETE CASCADE,
+    "from_status" VARCHAR(20), -- NULL untuk pengajuan baru
+    "to_status" VARCHAR(20) NOT NULL,
+    "actor_id" INT REFERENCES "akun" ("id_user") ON DELETE SET NULL,
+    "actor_role" VARCHAR(20) NOT NULL CHECK ("actor_role" IN ('applicant', 'reviewer')),
+    "comment" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_farm_application_event_app" ON "farm_application_event" ("application_id", "created_at");
+
+-- Anggota peternakan dengan role per peternakan; owner = farms.owner_id
+CREATE TABLE "farm_member" (
+    "farm_id" INT NOT NULL REFERENCES "farms" ("id") ON DELETE CASCADE,
+    "akun_id" INT NOT NULL REFERENCES "akun" ("id_user") ON DELETE CASCADE,
+    "role" VARCHAR(20) NOT NULL CHECK ("role" IN ('owner', 'manager', 'packer', 'cashier')),
+    "invited_by" INT REFERENCES "akun" ("id_user") ON DELETE SET NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("farm_id", "akun_id")
+);
+CREATE INDEX "idx_farm_member_akun" ON "farm_member" ("akun_id");
+-- Satu owner per peternakan
+CREATE UNIQUE INDEX "uq_farm_member_owner" ON "farm_member" ("farm_id") WHERE "role" = 'owner';
+
+-- Pemilik peternakan yang sudah ada menjadi anggota owner
+INSERT INTO "farm_member" ("farm_id", "akun_id", "role", "created_at")
+SELECT "id", "owner_id", 'owner', COALESCE("created_at", NOW()) FROM "farms" WHERE "owner_id" IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- Undangan menjadi anggota peternakan lewat email atau nomor telepon; diterima oleh akun yang cocok
+CREATE TABLE "farm_invitation" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "farm_id" INT NOT NULL REFERENCES "farms" ("id") ON DELETE CASCADE,
+    "role" VARCHAR(20) NOT NULL CHECK ("role" IN ('manager', 'packer', 'cashier')),
+    "email" VARCHAR(255), -- Disimpan huruf kecil; hanya untuk pemberitahuan
+    "no_telp" VARCHAR(20),
+    "status" VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'accepted', 'declined', 'revoked')),
+    "invited_by" INT REFERENCES "akun" ("id_user") ON DELETE SET NULL,
+    "accepted_by" INT REFERENCES "akun" ("id_user") ON DELETE SET NULL,
+    "expires_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "responded_at" TIMESTAMP,
+    CHECK ("email" IS NOT NULL OR "no_telp" IS NOT NULL)
+);
+CREATE INDEX "idx_farm_invitation_farm" ON "farm_invitation" ("farm_id", "status");
+CREATE INDEX "idx_farm_invitation_no_telp" ON "farm_invitation" ("no_telp") WHERE "status" = 'pending';
+
+-- Kategori produk bertingkat, mis. Ternak > Sapi > Daging sapi
+CREATE TABLE "product_category" (
+    "id" SERIAL PRIMARY KEY,
+    "parent_id" INT REFERENCES "product_category" ("id") ON DELETE RESTRICT, -- NULL untuk kategori teratas
+    "name" VARCHAR(100) NOT NULL,
+    "slug" VARCHAR(120) NOT NULL UNIQUE,
+    "description" TEXT,
+    "sort_order" INT NOT NULL DEFAULT 0,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ("parent_id" IS NULL OR "parent_id" <> "id")
+);
+CREATE INDEX "idx_product_category_parent" ON "product_category" ("parent_id");
+
+ALTER TABLE "farm_products" ADD COLUMN "category_id" INT REFERENCES "product_category" ("id") ON DELETE SET NULL;
+CREATE INDEX "idx_farm_products_category" ON "farm_products" ("category_id");
+
+-- Pencarian teks produk: nama berbobot lebih tinggi dari deskripsi, dengan stemming bahasa Indonesia
+ALTER TABLE "farm_products" ADD COLUMN "search_vector" TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('indonesian', COALESCE("name", '')), 'A') ||
+    setweight(to_tsvector('indonesian', COALESCE("description", '')), 'B')
+) STORED;
+CREATE INDEX "idx_farm_products_search" ON "farm_products" USING GIN ("search_vector");
+CREATE INDEX "idx_farm_products_created" ON "farm_products" ("created_at" DESC, "id" DESC);
+
+-- Ledger persediaan: setiap perubahan farm_products.stock_kg dicatat sebagai mutasi,
+-- sehingga stock_kg selalu sama dengan jumlah quantity_kg produk tersebut
+CREATE TABLE "inventory_movements" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "product_id" INT REFERENCES "farm_products" ("id") ON DELETE SET NULL, -- NULL jika produk dihapus; riwayat tetap disimpan
+    "farm_id" INT NOT NULL,
+    "type" VARCHAR(30) NOT NULL CHECK ("type" IN ('restock', 'sale', 'cancellation_return', 'spoilage', 'adjustment')),
+    "quantity_kg" NUMERIC(12,3) NOT NULL CHECK ("quantity_kg" <> 0), -- Positif menambah stok, negatif mengurangi
+    "balance_kg" NUMERIC(12,3) NOT NULL, -- stock_kg setelah mutasi
+    "reason" TEXT,
+    "actor_type" VARCHAR(20) NOT NULL CHECK ("actor_type" IN ('akun', 'pengirim', 'system')),
+    "actor_id" BIGINT, -- akun.id_user atau pengirim.id; NULL untuk system
+    "reference_type" VARCHAR(30), -- mis. invoice atau order
+    "reference_id" BIGINT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX "idx_inventory_movements_product" ON "inventory_movements" ("product_id", "id" DESC);
+CREATE INDEX "idx_inventory_movements_reference" ON "inventory_movements" ("reference_type", "reference_id");
+
+-- Saldo awal produk yang sudah ada sebelum ledger
+INSERT INTO "inventory_movements" ("product_id", "farm_id", "type", "quantity_kg", "balance_kg", "reason", "actor_type")
+SELECT "id", "farm_id", 'adjustment', "stock_kg", "stock_kg", 'Opening balance', 'system'
+FROM "farm_products"
+WHERE "stock_kg" <> 0;
@@ -1,17 +1,44 @@
 package main
 
 import (
+	"context"
+	"farmdistribution_be/config"
+	"farmdistribution_be/controller/realtime"
 	"farmdistribution_be/routes"
+	"farmdistribution_be/worker"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	// "gobizdevelop/config"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Println("Job worker is running")
+		if err := worker.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	router := routes.InitializeRoutes()
 
+	// Setiap instance mendengarkan NOTIFY agar koneksi /stream menerima event dari instance mana pun
+	go realtime.Listen(context.Background())
+
+	if config.JobsInProcess {
+		go func() {
+			if err := worker.Run(context.Background()); err != nil {
+				log.Println("[ERROR] Job worker stopped:", err)
+			}
+		}()
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
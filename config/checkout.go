@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// TaxRate adalah tarif pajak (mis. 0.11 untuk PPN 11%) yang dikenakan pada subtotal setelah diskon
+var TaxRate float64 = envFloat("TAX_RATE", 0)
+
+// QuoteTTL adalah lama sebuah quote checkout dapat dipakai untuk membuat order
+var QuoteTTL = 30 * time.Minute
+
+// Format nomor dokumen, lihat helper/numbering untuk token yang didukung
+var InvoiceNumberFormat = envString("INVOICE_NUMBER_FORMAT", "INV/{SCOPE}/{YYYY}/{MM}/{SEQ:6}")
+var CheckoutNumberFormat = envString("CHECKOUT_NUMBER_FORMAT", "CO/{YYYY}/{MM}/{SEQ:6}")
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// PublicBaseURL adalah alamat publik API, dipakai untuk tautan verifikasi invoice pada QR code
+var PublicBaseURL = envString("PUBLIC_BASE_URL", "https://farmdistribution.id")
+
+// InvoiceVerifySecret adalah kunci HMAC untuk kode verifikasi invoice
+var InvoiceVerifySecret = os.Getenv("INVOICE_VERIFY_SECRET")
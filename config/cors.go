@@ -34,7 +34,7 @@ func CORSMiddleware(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Login")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Login, X-Farm-ID")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 
 			// Tangani preflight request
@@ -52,3 +52,6 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// TrustedProxies adalah IP/CIDR reverse proxy (dipisah koma) yang header X-Forwarded-For-nya dipercaya
+var TrustedProxies = envString("TRUSTED_PROXIES", "")
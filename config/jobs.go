@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// JobsInProcess menjalankan worker job di dalam proses server HTTP.
+// Set JOBS_IN_PROCESS=false jika worker dijalankan terpisah dengan perintah "worker".
+var JobsInProcess = envString("JOBS_IN_PROCESS", "true") != "false"
+
+// JobWorkers adalah jumlah job yang diproses bersamaan
+var JobWorkers = int(envFloat("JOB_WORKERS", 2))
+
+// InvoiceExpirySchedule adalah jadwal cron pemeriksaan invoice yang melewati batas pembayaran
+var InvoiceExpirySchedule = envString("INVOICE_EXPIRY_SCHEDULE", "*/5 * * * *")
+
+// InventoryReconcileSchedule adalah jadwal cron rekonsiliasi stok produk dengan ledger persediaan
+var InventoryReconcileSchedule = envString("INVENTORY_RECONCILE_SCHEDULE", "0 2 * * *")
+
+// AdminRoleName adalah name_role untuk akun administrator
+var AdminRoleName = envString("ADMIN_ROLE_NAME", "admin")
+
+// FarmerRoleID adalah id_role yang diberikan saat pengajuan peternak disetujui
+var FarmerRoleID = int(envFloat("FARMER_ROLE_ID", 11))
+
+// FarmApplicationDocsRepo adalah repo GitHub (org ayala-crea) untuk dokumen pengajuan peternak
+var FarmApplicationDocsRepo = envString("FARM_APPLICATION_DOCS_REPO", "imagePeternakan")
+
+// FarmInvitationTTL adalah masa berlaku undangan anggota peternakan
+var FarmInvitationTTL = time.Duration(envFloat("FARM_INVITATION_TTL_HOURS", 7*24)) * time.Hour
@@ -0,0 +1,12 @@
+package config
+
+// Gateway WhatsApp untuk notifikasi; jika kosong pesan hanya ditulis ke log
+var WhatsAppGatewayURL = envString("WA_GATEWAY_URL", "")
+var WhatsAppGatewayToken = envString("WA_GATEWAY_TOKEN", "")
+
+// SMTP untuk notifikasi email; jika SMTP_HOST kosong pesan hanya ditulis ke log
+var SMTPHost = envString("SMTP_HOST", "")
+var SMTPPort = envString("SMTP_PORT", "587")
+var SMTPUsername = envString("SMTP_USERNAME", "")
+var SMTPPassword = envString("SMTP_PASSWORD", "")
+var SMTPFrom = envString("SMTP_FROM", "no-reply@farmdistribution.id")
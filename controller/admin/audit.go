@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/listquery"
+	"log"
+	"net/http"
+	"time"
+)
+
+type auditRow struct {
+	ID         int64           `json:"id"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *int64          `json:"actor_id"`
+	ActorName  *string         `json:"actor_name"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Changes    json.RawMessage `json:"changes"`
+	IP         *string         `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// parseTimeParam menerima tanggal (2006-01-02) atau RFC3339; nil jika kosong
+func parseTimeParam(v string, endOfDay bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func rawOrNull(b []byte) json.RawMessage {
+	if b == nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// auditListSpec adalah field audit log yang boleh diurutkan, difilter dan dicari. action tidak
+// difilter listquery karena filter action pada GetAuditLog berupa awalan.
+var auditListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"actor_type":  {Column: "l.actor_type", Type: listquery.Text, Filter: true},
+		"actor_id":    {Column: "l.actor_id", Type: listquery.Int, Filter: true},
+		"action":      {Column: "l.action", Type: listquery.Text, Sort: true},
+		"target_type": {Column: "l.target_type", Type: listquery.Text, Filter: true},
+		"target_id":   {Column: "l.target_id", Type: listquery.Text, Filter: true},
+		"ip":          {Column: "l.ip", Type: listquery.Text, Filter: true},
+		"created_at":  {Column: "l.created_at", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:           "l.id",
+	Search:       []string{"l.action", "l.target_id", "l.changes::text"},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetAuditLog mencari catatan audit dari yang terbaru; parameter mengikuti listquery.
+// Selain filter listquery: action (awalan, mis. "akun." atau "role.delete") dan from/to
+// (tanggal atau RFC3339; to berupa tanggal mencakup seluruh hari itu).
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !requireAdmin(w, r, sqlDB) {
+		return
+	}
+
+	q := r.URL.Query()
+	from, err := parseTimeParam(q.Get("from"), false)
+	if err != nil {
+		http.Error(w, "from must be a date (YYYY-MM-DD) or RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"), true)
+	if err != nil {
+		http.Error(w, "to must be a date (YYYY-MM-DD) or RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	lq, err := listquery.Parse(r, auditListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+	if v := q.Get("action"); v != "" {
+		lq.Where("position(? in l.action) = 1", v)
+	}
+	if from != nil {
+		lq.Where("l.created_at >= ?", *from)
+	}
+	if to != nil {
+		lq.Where("l.created_at < ?", *to)
+	}
+
+	page, err := listquery.Run(sqlDB, lq,
+		`l.id, l.actor_type, l.actor_id,
+			CASE l.actor_type
+				WHEN 'akun' THEN (SELECT a.nama FROM akun a WHERE a.id_user = l.actor_id)
+				WHEN 'pengirim' THEN (SELECT p.name FROM pengirim p WHERE p.id = l.actor_id)
+			END,
+			l.action, l.target_type, l.target_id, l.before, l.after, l.changes, l.ip, l.created_at`,
+		`audit_log l`,
+		func(rows *sql.Rows, key ...interface{}) (auditRow, error) {
+			var a auditRow
+			var before, after, changes []byte
+			err := rows.Scan(append([]interface{}{&a.ID, &a.ActorType, &a.ActorID, &a.ActorName, &a.Action, &a.TargetType,
+				&a.TargetID, &before, &after, &changes, &a.IP, &a.CreatedAt}, key...)...)
+			a.Before, a.After, a.Changes = rawOrNull(before), rawOrNull(after), rawOrNull(changes)
+			return a, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch audit log:", err)
+		listquery.WriteError(w, err)
+		return
+	}
+
+	listquery.Write(w, "Audit log retrieved successfully", page)
+}
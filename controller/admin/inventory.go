@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/inventory"
+	"log"
+	"net/http"
+)
+
+// GetInventoryDrift menampilkan produk yang stoknya berbeda dari jumlah ledger persediaan.
+// Tidak ada yang dikoreksi otomatis; selisih diselesaikan dengan mutasi adjustment.
+func GetInventoryDrift(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !requireAdmin(w, r, sqlDB) {
+		return
+	}
+
+	drifts, err := inventory.ReconcileAll(sqlDB)
+	if err != nil {
+		log.Println("[ERROR] Failed to reconcile inventory:", err)
+		http.Error(w, "Failed to reconcile inventory", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Inventory drift retrieved successfully",
+		"data":    drifts,
+	})
+}
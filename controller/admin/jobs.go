@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
+	"farmdistribution_be/helper/watoken"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type jobRow struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+}
+
+type deadLetterRow struct {
+	ID        int64           `json:"id"`
+	JobID     int64           `json:"job_id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// requireAdmin memvalidasi token dan memastikan pemanggil adalah admin
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB) bool {
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return false
+	}
+	isAdmin, err := identity.IsAdmin(db, payload.Id)
+	if err != nil {
+		log.Println("[ERROR] Failed to check admin role:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !isAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only administrators can access this resource.",
+		})
+		return false
+	}
+	return true
+}
+
+func limitParam(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 200 {
+		return 50
+	}
+	return limit
+}
+
+// jobListSpec adalah field daftar job yang boleh diurutkan, difilter dan dicari
+var jobListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"status":     {Column: "status", Type: listquery.Text, Filter: true},
+		"kind":       {Column: "kind", Type: listquery.Text, Sort: true, Filter: true},
+		"attempts":   {Column: "attempts", Type: listquery.Int, Sort: true, Filter: true},
+		"run_at":     {Column: "run_at", Type: listquery.Time, Sort: true, Filter: true},
+		"created_at": {Column: "created_at", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:           "id",
+	Search:       []string{"kind", "last_error"},
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetJobs menampilkan job terbaru beserta jumlah job per status; parameter mengikuti listquery
+func GetJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !requireAdmin(w, r, sqlDB) {
+		return
+	}
+
+	lq, err := listquery.Parse(r, jobListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+	page, err := listquery.Run(sqlDB, lq,
+		`id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, finished_at`,
+		`job`,
+		func(rows *sql.Rows, key ...interface{}) (jobRow, error) {
+			var j jobRow
+			var payload []byte
+			err := rows.Scan(append([]interface{}{&j.ID, &j.Kind, &payload, &j.Status, &j.Attempts, &j.MaxAttempts,
+				&j.RunAt, &j.LastError, &j.CreatedAt, &j.FinishedAt}, key...)...)
+			j.Payload = payload
+			return j, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch jobs:", err)
+		listquery.WriteError(w, err)
+		return
+	}
+
+	counts := map[string]int{}
+	countRows, err := sqlDB.Query(`SELECT status, COUNT(*) FROM job GROUP BY status`)
+	if err != nil {
+		log.Println("[ERROR] Failed to count jobs:", err)
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	defer countRows.Close()
+	for countRows.Next() {
+		var s string
+		var n int
+		if err := countRows.Scan(&s, &n); err == nil {
+			counts[s] = n
+		}
+	}
+
+	listquery.Write(w, "Jobs retrieved successfully", struct {
+		listquery.Page[jobRow]
+		Counts map[string]int `json:"counts"`
+	}{page, counts})
+}
+
+// GetJobFailures menampilkan job yang masuk dead letter setelah semua percobaan gagal
+func GetJobFailures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !requireAdmin(w, r, sqlDB) {
+		return
+	}
+
+	query := `SELECT id, job_id, kind, payload, attempts, last_error, failed_at
+		FROM job_dead_letter
+		ORDER BY failed_at DESC
+		LIMIT $1`
+	rows, err := sqlDB.Query(query, limitParam(r))
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch dead letters:", err)
+		http.Error(w, "Failed to fetch job failures", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := []deadLetterRow{}
+	for rows.Next() {
+		var d deadLetterRow
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.JobID, &d.Kind, &payload, &d.Attempts, &d.LastError, &d.FailedAt); err != nil {
+			log.Println("[ERROR] Failed to scan dead letter:", err)
+			http.Error(w, "Failed to fetch job failures", http.StatusInternalServerError)
+			return
+		}
+		d.Payload = payload
+		list = append(list, d)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Job failures retrieved successfully",
+		"data":    list,
+	})
+}
@@ -1,8 +1,12 @@
 package akun
 
 import (
+	"database/sql"
 	"encoding/json"
 	"farmdistribution_be/config"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/model"
 	"log"
 	"net/http"
@@ -10,42 +14,53 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// akunListItem adalah akun pada /all/akun; password tidak ikut ditampilkan
+type akunListItem struct {
+	ID     int    `json:"id_user"`
+	Nama   string `json:"nama"`
+	NoTelp string `json:"no_telp"`
+	Email  string `json:"email"`
+	RoleID int    `json:"id_role"`
+}
+
+// akunListSpec adalah field /all/akun yang boleh diurutkan, difilter dan dicari
+var akunListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"nama":    {Column: "nama", Type: listquery.Text, Sort: true, Filter: true},
+		"email":   {Column: "email", Type: listquery.Text, Sort: true, Filter: true},
+		"no_telp": {Column: "no_telp", Type: listquery.Text, Filter: true},
+		"id_role": {Column: "id_role", Type: listquery.Int, Sort: true, Filter: true},
+	},
+	ID:     "id_user",
+	Search: []string{"nama", "email", "no_telp"},
+}
+
+// GetAllAkun menampilkan akun per halaman; parameter mengikuti listquery
 func GetAllAkun(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var akun []model.Akun
-	w.Header().Set("Content-Type", "application/json")
-
-	query := `SELECT id_user, nama, no_telp, email, id_role, password FROM akun`
-	rows, err := sqlDB.Query(query)
+	lq, err := listquery.Parse(r, akunListSpec)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to fetch users.",
-		})
+		listquery.WriteError(w, err)
 		return
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		var a model.Akun
-		err := rows.Scan(&a.ID, &a.Nama, &a.NoTelp, &a.Email, &a.RoleID, &a.Password)
-		if err != nil {
-			log.Fatal(err)
-		}
-		akun = append(akun, a)
+	page, err := listquery.Run(sqlDB, lq, `id_user, nama, no_telp, email, id_role`, `akun`,
+		func(rows *sql.Rows, key ...interface{}) (akunListItem, error) {
+			var a akunListItem
+			err := rows.Scan(append([]interface{}{&a.ID, &a.Nama, &a.NoTelp, &a.Email, &a.RoleID}, key...)...)
+			return a, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch users:", err)
+		listquery.WriteError(w, err)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Users fetched successfully",
-		"users":   akun,
-	})
+	listquery.Write(w, "Users fetched successfully", page)
 }
 
 func EditDataAkun(w http.ResponseWriter, r *http.Request) {
@@ -54,6 +69,11 @@ func EditDataAkun(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var akun model.Akun
 	w.Header().Set("Content-Type", "application/json")
 
@@ -85,9 +105,43 @@ func EditDataAkun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `UPDATE akun SET nama = $1, no_telp = $2, email = $3, id_role = $4 WHERE id_user = $5`
-	_, err = sqlDB.Exec(query, akun.Nama, akun.NoTelp, akun.Email, akun.RoleID, id)
+	// Perubahan akun (termasuk id_role) dicatat ke audit_log dalam transaksi yang sama
+	entry := audit.New(r, caller.Type, caller.ID, "akun.update", "akun", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to update user.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	entry.Before, err = audit.Snapshot(tx, "akun", "id_user", id)
+	if err == nil && entry.Before == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "User not found",
+			"message": "No user found with the provided ID.",
+		})
+		return
+	}
+	if err == nil {
+		query := `UPDATE akun SET nama = $1, no_telp = $2, email = $3, id_role = $4 WHERE id_user = $5`
+		_, err = tx.Exec(query, akun.Nama, akun.NoTelp, akun.Email, akun.RoleID, id)
+	}
+	if err == nil {
+		entry.After, err = audit.Snapshot(tx, "akun", "id_user", id)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
+		log.Println("[ERROR] Failed to update akun:", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Database error",
@@ -156,6 +210,11 @@ func DeleteAkun(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	// Ambil ID dari query parameter
@@ -169,9 +228,33 @@ func DeleteAkun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entry := audit.New(r, caller.Type, caller.ID, "akun.delete", "akun", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete user.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Data akun disimpan di audit_log sebelum dihapus
+	entry.Before, err = audit.Snapshot(tx, "akun", "id_user", id)
+	if err != nil {
+		log.Println("[ERROR] Failed to snapshot akun:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete user.",
+		})
+		return
+	}
+
 	// Query untuk menghapus data berdasarkan ID
 	query := `DELETE FROM akun WHERE id_user = $1`
-	result, err := sqlDB.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -201,6 +284,20 @@ func DeleteAkun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = audit.Record(tx, entry)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to delete akun:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete user.",
+		})
+		return
+	}
+
 	// Kirim respon sukses
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -214,6 +311,11 @@ func AddAkun(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	var akun model.Akun
@@ -247,9 +349,30 @@ func AddAkun(w http.ResponseWriter, r *http.Request) {
 	}
 	akun.Password = string(hashedPassword)
 
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to add user.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	// Insert akun into database
-	query := `INSERT INTO akun (nama, no_telp, email, id_role, password) VALUES ($1, $2, $3, $4, $5)`
-	_, err = sqlDB.Exec(query, akun.Nama, akun.NoTelp, akun.Email, akun.RoleID, akun.Password)
+	var newID int64
+	query := `INSERT INTO akun (nama, no_telp, email, id_role, password) VALUES ($1, $2, $3, $4, $5) RETURNING id_user`
+	err = tx.QueryRow(query, akun.Nama, akun.NoTelp, akun.Email, akun.RoleID, akun.Password).Scan(&newID)
+	if err == nil {
+		entry := audit.New(r, caller.Type, caller.ID, "akun.create", "akun", newID)
+		if entry.After, err = audit.Snapshot(tx, "akun", "id_user", newID); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("Error executing query: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -265,3 +388,38 @@ func AddAkun(w http.ResponseWriter, r *http.Request) {
 		"message": "User added successfully",
 	})
 }
+
+// requireAdmin memastikan pemanggil adalah akun administrator. Caller yang dikembalikan
+// dicatat sebagai pelaku di audit_log.
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB) (identity.Caller, bool) {
+	caller, err := identity.FromRequest(db, r)
+	if err == nil && caller.Type != identity.TypeAkun {
+		err = identity.ErrNotFound
+	}
+	if err == identity.ErrUnauthorized || err == identity.ErrNotFound {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return caller, false
+	}
+	isAdmin := false
+	if err == nil {
+		isAdmin, err = identity.IsAdmin(db, caller.NoTelp)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to check admin role:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return caller, false
+	}
+	if !isAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only administrators can access this resource.",
+		})
+		return caller, false
+	}
+	return caller, true
+}
@@ -62,7 +62,7 @@ func LoginUsers(w http.ResponseWriter, r *http.Request) {
 		log.Printf("User ditemukan di tabel akun: %s", akun.Email)
 	} else {
 		// Jika tidak ditemukan di akun, coba di tabel pengirim
-		query = `SELECT id, name, phone, email, password, id_role FROM pengirim WHERE email = $1`
+		query = `SELECT id, name AS nama, phone AS no_telp, email, password, id_role FROM pengirim WHERE email = $1`
 		result = config.PostgresDB.Raw(query, loginData.Email).Scan(&pengirim)
 		if result.RowsAffected > 0 {
 			userFound = true
@@ -111,7 +111,7 @@ func LoginUsers(w http.ResponseWriter, r *http.Request) {
 	if userType == "akun" {
 		token, err = watoken.EncodeforHours(akun.NoTelp, akun.Nama, PrivateKey, 18)
 	} else {
-		token, err = watoken.EncodeforHours("081313131316", pengirim.Nama, PrivateKey, 18)
+		token, err = watoken.EncodeforHours(pengirim.NoTelp, pengirim.Nama, PrivateKey, 18)
 	}
 
 	if err != nil {
@@ -0,0 +1,329 @@
+package cart
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/qty"
+	"farmdistribution_be/helper/watoken"
+	"farmdistribution_be/model"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// unitPriceExpr adalah harga per satuan jual produk, sama dengan perhitungan di checkout
+const unitPriceExpr = `COALESCE(fp.price_per_unit, ROUND(fp.price_per_kg * fp.stock_per_unit, 2))`
+
+// cartOwner mengambil id_user pemilik keranjang dari token login
+func cartOwner(sqlDB *sql.DB, r *http.Request) (int, error) {
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		return 0, err
+	}
+
+	var akunID int
+	query := `SELECT id_user FROM akun WHERE no_telp = $1`
+	err = sqlDB.QueryRow(query, payload.Id).Scan(&akunID)
+	return akunID, err
+}
+
+func AddToCart(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	akunID, err := cartOwner(sqlDB, r)
+	if err != nil {
+		log.Println("[ERROR] Invalid token or user not found:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var item model.Product
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if item.ProductID == 0 || item.Quantity <= 0 {
+		http.Error(w, "product_id and a positive quantity are required", http.StatusBadRequest)
+		return
+	}
+
+	var price float64
+	var unit string
+	var minQty, stepQty qty.Qty
+	query := `SELECT ` + unitPriceExpr + `, fp.sale_unit, fp.min_qty, fp.step_qty FROM farm_products fp WHERE fp.id = $1`
+	err = sqlDB.QueryRow(query, item.ProductID).Scan(&price, &unit, &minQty, &stepQty)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		log.Println("[ERROR] Failed to fetch product:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Produk yang sudah ada di keranjang ditambah jumlahnya
+	var current qty.Qty
+	query = `SELECT quantity FROM cart_item WHERE akun_id = $1 AND product_id = $2`
+	if err := sqlDB.QueryRow(query, akunID, item.ProductID).Scan(&current); err != nil && err != sql.ErrNoRows {
+		log.Println("[ERROR] Failed to fetch cart item:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := (current + item.Quantity).Validate(minQty, stepQty); err != nil {
+		http.Error(w, err.Error()+" "+unit, http.StatusBadRequest)
+		return
+	}
+
+	query = `INSERT INTO cart_item (akun_id, product_id, quantity, price_at_add, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (akun_id, product_id)
+		DO UPDATE SET quantity = cart_item.quantity + EXCLUDED.quantity, price_at_add = EXCLUDED.price_at_add, updated_at = NOW()`
+	_, err = sqlDB.Exec(query, akunID, item.ProductID, item.Quantity, price)
+	if err != nil {
+		log.Println("[ERROR] Failed to add cart item:", err)
+		http.Error(w, "Failed to add product to cart", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product added to cart",
+	})
+}
+
+func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	akunID, err := cartOwner(sqlDB, r)
+	if err != nil {
+		log.Println("[ERROR] Invalid token or user not found:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var item model.Product
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if item.ProductID == 0 || item.Quantity < 0 {
+		http.Error(w, "product_id and a non-negative quantity are required", http.StatusBadRequest)
+		return
+	}
+
+	if item.Quantity > 0 {
+		var unit string
+		var minQty, stepQty qty.Qty
+		query := `SELECT sale_unit, min_qty, step_qty FROM farm_products WHERE id = $1`
+		if err := sqlDB.QueryRow(query, item.ProductID).Scan(&unit, &minQty, &stepQty); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Product not found", http.StatusNotFound)
+				return
+			}
+			log.Println("[ERROR] Failed to fetch product:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := item.Quantity.Validate(minQty, stepQty); err != nil {
+			http.Error(w, err.Error()+" "+unit, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result sql.Result
+	if item.Quantity == 0 {
+		query := `DELETE FROM cart_item WHERE akun_id = $1 AND product_id = $2`
+		result, err = sqlDB.Exec(query, akunID, item.ProductID)
+	} else {
+		// Mengubah jumlah sekaligus menyetujui harga terbaru produk
+		query := `UPDATE cart_item SET quantity = $1, updated_at = NOW(),
+			price_at_add = COALESCE((SELECT ` + unitPriceExpr + ` FROM farm_products fp WHERE fp.id = $3), price_at_add)
+			WHERE akun_id = $2 AND product_id = $3`
+		result, err = sqlDB.Exec(query, item.Quantity, akunID, item.ProductID)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to update cart item:", err)
+		http.Error(w, "Failed to update cart", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Product is not in the cart", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Cart updated",
+	})
+}
+
+func RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	akunID, err := cartOwner(sqlDB, r)
+	if err != nil {
+		log.Println("[ERROR] Invalid token or user not found:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	productID, err := strconv.Atoi(r.URL.Query().Get("product_id"))
+	if err != nil {
+		http.Error(w, "Invalid product_id", http.StatusBadRequest)
+		return
+	}
+
+	query := `DELETE FROM cart_item WHERE akun_id = $1 AND product_id = $2`
+	result, err := sqlDB.Exec(query, akunID, productID)
+	if err != nil {
+		log.Println("[ERROR] Failed to remove cart item:", err)
+		http.Error(w, "Failed to remove product from cart", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Product is not in the cart", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product removed from cart",
+	})
+}
+
+func ClearCart(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	akunID, err := cartOwner(sqlDB, r)
+	if err != nil {
+		log.Println("[ERROR] Invalid token or user not found:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := `DELETE FROM cart_item WHERE akun_id = $1`
+	if _, err := sqlDB.Exec(query, akunID); err != nil {
+		log.Println("[ERROR] Failed to clear cart:", err)
+		http.Error(w, "Failed to clear cart", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Cart cleared",
+	})
+}
+
+// GetCart mengembalikan isi keranjang yang sudah divalidasi ulang terhadap harga, stok dan status produk saat ini
+func GetCart(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	akunID, err := cartOwner(sqlDB, r)
+	if err != nil {
+		log.Println("[ERROR] Invalid token or user not found:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := `
+		SELECT
+			ci.product_id, fp.name, COALESCE(fp.image_url, ''), fp.farm_id, f.name,
+			ci.quantity, fp.sale_unit, fp.min_qty, fp.step_qty, fp.stock_per_unit,
+			ci.price_at_add, ` + unitPriceExpr + `, fp.stock_kg,
+			COALESCE(sp.name, ''),
+			(sp.available_date IS NULL OR sp.available_date <= NOW()) AS available,
+			ci.updated_at
+		FROM cart_item ci
+		JOIN farm_products fp ON fp.id = ci.product_id
+		JOIN farms f ON f.id = fp.farm_id
+		LEFT JOIN status_product sp ON sp.id = fp.status_id
+		WHERE ci.akun_id = $1
+		ORDER BY ci.created_at`
+	rows, err := sqlDB.Query(query, akunID)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch cart:", err)
+		http.Error(w, "Failed to fetch cart", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []model.CartItem{}
+	var subtotal float64
+	hasChanges := false
+	for rows.Next() {
+		var item model.CartItem
+		var stockPerUnit qty.Qty
+		err := rows.Scan(&item.ProductID, &item.ProductName, &item.ImageURL, &item.FarmID, &item.FarmName,
+			&item.Quantity, &item.Unit, &item.MinQty, &item.StepQty, &stockPerUnit,
+			&item.PriceAtAdd, &item.CurrentPrice, &item.StockKg,
+			&item.StatusName, &item.Available, &item.UpdatedAt)
+		if err != nil {
+			log.Println("[ERROR] Failed to scan cart item:", err)
+			http.Error(w, "Failed to fetch cart", http.StatusInternalServerError)
+			return
+		}
+
+		item.PriceChanged = item.PriceAtAdd != item.CurrentPrice
+		item.OutOfStock = item.StockKg <= 0
+		item.InsufficientStock = !item.OutOfStock && item.StockKg < item.Quantity.Mul(stockPerUnit).Float64()
+		item.InvalidQuantity = item.Quantity.Validate(item.MinQty, item.StepQty) != nil
+		item.LineTotal = item.Quantity.Amount(item.CurrentPrice)
+		if item.PriceChanged || item.OutOfStock || item.InsufficientStock || item.InvalidQuantity || !item.Available {
+			hasChanges = true
+		}
+		if item.Available && !item.OutOfStock {
+			subtotal += item.LineTotal
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("[ERROR] Failed to read cart items:", err)
+		http.Error(w, "Failed to fetch cart", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Cart retrieved successfully",
+		"data": map[string]interface{}{
+			"items":       items,
+			"subtotal":    math.Round(subtotal*100) / 100,
+			"has_changes": hasChanges,
+		},
+	})
+}
@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type notificationItem struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	Link      *string         `json:"link"`
+	Data      json.RawMessage `json:"data"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// caller mengambil koneksi database dan pemilik token; false berarti respons error sudah ditulis
+func caller(w http.ResponseWriter, r *http.Request) (*sql.DB, identity.Caller, bool) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, identity.Caller{}, false
+	}
+	c, err := identity.FromRequest(sqlDB, r)
+	switch err {
+	case nil:
+		return sqlDB, c, true
+	case identity.ErrUnauthorized:
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+	case identity.ErrNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+	default:
+		log.Println("[ERROR] Failed to resolve caller:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+	return nil, identity.Caller{}, false
+}
+
+// notificationListSpec adalah field inbox yang boleh diurutkan, difilter dan dicari
+var notificationListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"event":      {Column: "event", Type: listquery.Text, Filter: true},
+		"read_at":    {Column: "read_at", Type: listquery.Time, Filter: true},
+		"created_at": {Column: "created_at", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:     "id",
+	Search: []string{"title", "body"},
+}
+
+// GetNotifications menampilkan inbox pemanggil dari yang terbaru; parameter mengikuti listquery.
+// unread=true hanya menampilkan yang belum dibaca (sama dengan read_at[null]=true).
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	lq, err := listquery.Parse(r, notificationListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+	lq.Where("recipient_type = ? AND recipient_id = ?", c.Type, c.ID)
+	if r.URL.Query().Get("unread") == "true" {
+		lq.Where("read_at IS NULL")
+	}
+
+	page, err := listquery.Run(sqlDB, lq,
+		`id, event, title, body, link, data, read_at, created_at`,
+		`notifications`,
+		func(rows *sql.Rows, key ...interface{}) (notificationItem, error) {
+			var n notificationItem
+			var data []byte
+			err := rows.Scan(append([]interface{}{&n.ID, &n.Event, &n.Title, &n.Body, &n.Link, &data, &n.ReadAt, &n.CreatedAt}, key...)...)
+			n.Data = data
+			return n, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch notifications:", err)
+		listquery.WriteError(w, err)
+		return
+	}
+
+	listquery.Write(w, "Notifications retrieved successfully", page)
+}
+
+// GetUnreadCount mengembalikan jumlah notifikasi yang belum dibaca
+func GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE recipient_type = $1 AND recipient_id = $2 AND read_at IS NULL`
+	if err := sqlDB.QueryRow(query, c.Type, c.ID).Scan(&count); err != nil {
+		log.Println("[ERROR] Failed to count notifications:", err)
+		http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Unread count retrieved successfully",
+		"data":    map[string]int{"unread": count},
+	})
+}
+
+// MarkNotificationRead menandai satu notifikasi milik pemanggil sebagai sudah dibaca
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND recipient_type = $2 AND recipient_id = $3`
+	result, err := sqlDB.Exec(query, id, c.Type, c.ID)
+	if err != nil {
+		log.Println("[ERROR] Failed to mark notification read:", err)
+		http.Error(w, "Failed to update notification", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead menandai semua notifikasi pemanggil sebagai sudah dibaca
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	query := `UPDATE notifications SET read_at = NOW() WHERE recipient_type = $1 AND recipient_id = $2 AND read_at IS NULL`
+	result, err := sqlDB.Exec(query, c.Type, c.ID)
+	if err != nil {
+		log.Println("[ERROR] Failed to mark notifications read:", err)
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+	updated, _ := result.RowsAffected()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "All notifications marked as read",
+		"data":    map[string]int64{"updated": updated},
+	})
+}
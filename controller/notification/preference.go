@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"encoding/json"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/notify"
+	"log"
+	"net/http"
+)
+
+var knownChannels = []string{notify.ChannelInApp, notify.ChannelWhatsApp, notify.ChannelEmail}
+
+// GetPreferences menampilkan channel notifikasi yang aktif dan bahasa pesan milik pemanggil
+func GetPreferences(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	to, err := (&notify.PGStore{DB: sqlDB}).Recipient(r.Context(), c.Type, c.ID)
+	if err != nil {
+		log.Println("[ERROR] Failed to find recipient:", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	prefs, err := (&notify.PGStore{DB: sqlDB}).Enabled(r.Context(), to)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch notification preferences:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	channels := map[string]bool{}
+	for _, ch := range knownChannels {
+		enabled, ok := prefs[ch]
+		channels[ch] = !ok || enabled
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Notification preferences retrieved successfully",
+		"data": map[string]interface{}{
+			"channels": channels,
+			"language": to.Lang,
+		},
+	})
+}
+
+// UpdatePreferences mengaktifkan/mematikan channel notifikasi dan mengganti bahasa pesan
+func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	sqlDB, c, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Channels map[string]bool `json:"channels"`
+		Language string          `json:"language"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid request payload",
+			"message": "Failed to decode request body.",
+		})
+		return
+	}
+	for ch := range req.Channels {
+		valid := false
+		for _, known := range knownChannels {
+			valid = valid || ch == known
+		}
+		if !valid {
+			http.Error(w, "Unknown channel: "+ch, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Language != "" && req.Language != "id" && req.Language != "en" {
+		http.Error(w, "language must be id or en", http.StatusBadRequest)
+		return
+	}
+
+	// Bahasa hanya tersimpan untuk akun; pengirim memakai bahasa default
+	if req.Language != "" && c.Type != identity.TypeAkun {
+		http.Error(w, "language can only be set for user accounts", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for channel, enabled := range req.Channels {
+		query := `INSERT INTO notification_preference (recipient_type, recipient_id, channel, enabled, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (recipient_type, recipient_id, channel) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()`
+		if _, err := tx.Exec(query, c.Type, c.ID, channel, enabled); err != nil {
+			log.Println("[ERROR] Failed to save notification preference:", err)
+			http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.Language != "" {
+		if _, err := tx.Exec(`UPDATE akun SET language = $1 WHERE id_user = $2`, req.Language, c.ID); err != nil {
+			log.Println("[ERROR] Failed to save language:", err)
+			http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing transaction:", err)
+		http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Notification preferences updated successfully",
+	})
+}
@@ -0,0 +1,153 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/format"
+	"farmdistribution_be/helper/notify"
+	"fmt"
+	"math"
+)
+
+// newNotifier menyiapkan channel notifikasi; channel yang belum dikonfigurasi diganti Fake yang menulis ke log
+func newNotifier(db *sql.DB) *notify.Notifier {
+	channels := []notify.Channel{&notify.InApp{DB: db}}
+	if config.WhatsAppGatewayURL != "" {
+		channels = append(channels, &notify.WhatsApp{URL: config.WhatsAppGatewayURL, Token: config.WhatsAppGatewayToken})
+	} else {
+		channels = append(channels, &notify.Fake{Channel: notify.ChannelWhatsApp, Log: true})
+	}
+	if config.SMTPHost != "" {
+		channels = append(channels, &notify.SMTP{Host: config.SMTPHost, Port: config.SMTPPort, Username: config.SMTPUsername, Password: config.SMTPPassword, From: config.SMTPFrom})
+	} else {
+		channels = append(channels, &notify.Fake{Channel: notify.ChannelEmail, Log: true})
+	}
+	return &notify.Notifier{Store: &notify.PGStore{DB: db}, Channels: channels}
+}
+
+// buyerTemplates memetakan event invoice ke template untuk pembeli
+var buyerTemplates = map[string]string{
+	events.OrderPlaced:        "OrderPlaced",
+	events.PaymentApproved:    "PaymentApproved",
+	events.PaymentRejected:    "PaymentRejected",
+	events.InvoiceCancelled:   "InvoiceCancelled",
+	events.InvoiceExpired:     "InvoiceExpired",
+	events.OrderWeighed:       "OrderWeighed",
+	events.ShipmentDispatched: "ShipmentDispatched",
+	events.ShipmentDelivered:  "ShipmentDelivered",
+}
+
+// RegisterSubscribers mendaftarkan pengiriman notifikasi untuk domain event
+func RegisterSubscribers() {
+	for eventType := range buyerTemplates {
+		events.Subscribe("notify.invoice", eventType, handleInvoiceEvent)
+	}
+	events.Subscribe("notify.invoice", events.PaymentSubmitted, handleInvoiceEvent)
+	events.Subscribe("notify.farm", events.FarmApproved, handleFarmEvent)
+	events.Subscribe("notify.farm", events.FarmNeedsInfo, handleFarmEvent)
+	events.Subscribe("notify.farm", events.FarmRejected, handleFarmEvent)
+	events.Subscribe("notify.farm", events.FarmInvited, handleFarmEvent)
+}
+
+func rupiah(v float64) string {
+	return format.FormatCurrency(v) + "0"
+}
+
+func handleInvoiceEvent(ctx context.Context, e events.Event) error {
+	var p events.InvoicePayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return err
+	}
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	n := newNotifier(sqlDB)
+	key := fmt.Sprintf("event:%d", e.ID)
+
+	data := map[string]string{
+		"invoice_id":     fmt.Sprint(p.InvoiceID),
+		"invoice_number": p.InvoiceNumber,
+		"total":          rupiah(p.TotalAmount),
+		"reason":         p.Reason,
+	}
+	if p.Adjustment != 0 {
+		sign := "+"
+		if p.Adjustment < 0 {
+			sign = "-"
+		}
+		data["adjustment"] = sign + rupiah(math.Abs(p.Adjustment))
+	}
+	var dueDate sql.NullTime
+	if err := sqlDB.QueryRowContext(ctx, `SELECT due_date FROM invoice WHERE id = $1`, p.InvoiceID).Scan(&dueDate); err == nil && dueDate.Valid {
+		data["due_date"] = dueDate.Time.Format("02 Jan 2006 15:04")
+	}
+
+	// Pembeli
+	if tpl, ok := buyerTemplates[e.Type]; ok && !(e.Type == events.OrderWeighed && p.Adjustment == 0) {
+		if err := n.Notify(ctx, key, notify.RecipientAkun, p.UserID, tpl, data); err != nil {
+			return err
+		}
+	}
+
+	// Anggota peternakan yang menangani pesanan (OrderPlaced) atau pembayaran (PaymentSubmitted)
+	if (e.Type == events.OrderPlaced || e.Type == events.PaymentSubmitted) && p.FarmID != 0 {
+		tpl, perm := "OrderReceived", farmaccess.FulfilOrders
+		if e.Type == events.PaymentSubmitted {
+			tpl, perm = "PaymentSubmitted", farmaccess.VerifyPayments
+		}
+		members, err := farmaccess.Members(ctx, sqlDB, p.FarmID, perm)
+		if err != nil {
+			return err
+		}
+		for _, akunID := range members {
+			if err := n.Notify(ctx, key, notify.RecipientAkun, akunID, tpl, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Pengirim yang ditugaskan
+	if e.Type == events.ShipmentDispatched {
+		var pengirimID sql.NullInt64
+		query := `SELECT id_pengirim FROM proses_pengiriman WHERE id_invoice = $1 AND id_pengirim IS NOT NULL AND id_pengirim <> 0 ORDER BY id LIMIT 1`
+		if err := sqlDB.QueryRowContext(ctx, query, p.InvoiceID).Scan(&pengirimID); err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if pengirimID.Valid {
+			if err := n.Notify(ctx, key, notify.RecipientPengirim, pengirimID.Int64, "ShipmentAssigned", data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleFarmEvent mengabari pemohon tentang hasil peninjauan pengajuan peternak
+// dan akun yang diundang menjadi anggota peternakan
+func handleFarmEvent(ctx context.Context, e events.Event) error {
+	var p events.FarmPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return err
+	}
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	data := map[string]string{}
+	for k, v := range map[string]string{"reason": p.Reason, "farm_name": p.FarmName, "role": p.Role} {
+		if v != "" {
+			data[k] = v
+		}
+	}
+	key := fmt.Sprintf("event:%d", e.ID)
+	if e.Type == events.FarmInvited && p.UserID == 0 {
+		to := notify.Recipient{ID: p.InvitationID, Email: p.Email, Phone: p.NoTelp}
+		return newNotifier(sqlDB).NotifyContact(ctx, key, to, "FarmInvitedContact", data)
+	}
+	return newNotifier(sqlDB).Notify(ctx, key, notify.RecipientAkun, p.UserID, e.Type, data)
+}
@@ -0,0 +1,72 @@
+package order
+
+import (
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/watoken"
+	"farmdistribution_be/model"
+	"log"
+	"net/http"
+)
+
+// QuoteCheckout menghitung rincian harga keranjang tanpa membuat order dan mengembalikan quote_id
+func QuoteCheckout(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		log.Println("Unauthorized: failed to decode token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var userID int
+	query := `SELECT id_user FROM akun WHERE no_telp = $1`
+	err = sqlDB.QueryRow(query, payload.Id).Scan(&userID)
+	if err != nil {
+		log.Println("Error retrieving user ID:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var cart model.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&cart); err != nil {
+		log.Println("Error decoding request body:", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if cart.FromCart {
+		cart.Products, err = loadCartProducts(sqlDB, userID)
+		if err != nil {
+			writeCheckoutError(w, err, "Failed to load cart")
+			return
+		}
+	}
+
+	quote, err := priceCart(sqlDB, cart)
+	if err != nil {
+		writeCheckoutError(w, err, "Failed to calculate quote")
+		return
+	}
+
+	if err := saveQuote(sqlDB, userID, cart, &quote); err != nil {
+		log.Println("Error saving quote:", err)
+		http.Error(w, "Failed to save quote", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Quote calculated successfully",
+		"data":    quote,
+	})
+}
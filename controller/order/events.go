@@ -0,0 +1,38 @@
+package order
+
+import (
+	"database/sql"
+	"farmdistribution_be/helper/events"
+	"strings"
+)
+
+// loadInvoicePayload membaca data invoice untuk isi event
+func loadInvoicePayload(db queryer, invoiceID int64) (events.InvoicePayload, error) {
+	var p events.InvoicePayload
+	query := `SELECT i.id, i.invoice_number, COALESCE(i.checkout_id, 0), i.user_id, COALESCE(i.farm_id, 0), i.total_amount, i.payment_status,
+			COALESCE((SELECT status_pengiriman FROM proses_pengiriman WHERE id_invoice = i.id ORDER BY id LIMIT 1), '')
+		FROM invoice i WHERE i.id = $1`
+	err := db.QueryRow(query, invoiceID).Scan(&p.InvoiceID, &p.InvoiceNumber, &p.CheckoutID, &p.UserID, &p.FarmID, &p.TotalAmount, &p.PaymentStatus, &p.ShipmentStatus)
+	return p, err
+}
+
+// publishInvoiceEvent menulis event invoice ke outbox di dalam transaksi yang sedang berjalan
+func publishInvoiceEvent(tx *sql.Tx, eventType string, invoiceID int64, reason string) error {
+	p, err := loadInvoicePayload(tx, invoiceID)
+	if err != nil {
+		return err
+	}
+	p.Reason = reason
+	return events.Publish(tx, eventType, "invoice", invoiceID, p)
+}
+
+// shipmentEvent memetakan status_pengiriman ke event pengiriman; kosong jika tidak ada event
+func shipmentEvent(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "dikirim", "shipped", "dispatched", "on delivery", "dalam pengiriman":
+		return events.ShipmentDispatched
+	case "diterima", "delivered", "selesai", "completed":
+		return events.ShipmentDelivered
+	}
+	return ""
+}
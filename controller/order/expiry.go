@@ -0,0 +1,83 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/jobs"
+	"log"
+)
+
+// expireBatchSize membatasi jumlah invoice yang diproses dalam satu putaran
+const expireBatchSize = 100
+
+// ExpireOverdueInvoices menandai invoice Pending yang melewati due_date sebagai Expired
+// dan mengembalikan stoknya. Setiap invoice diproses dalam transaksi sendiri dan dikunci
+// dengan SKIP LOCKED agar beberapa instance server tidak memproses invoice yang sama.
+func ExpireOverdueInvoices(db *sql.DB) (int, error) {
+	expired := 0
+	for expired < expireBatchSize {
+		ok, err := expireNextInvoice(db)
+		if err != nil {
+			return expired, err
+		}
+		if !ok {
+			break
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func expireNextInvoice(db *sql.DB) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var invoiceID, userID int64
+	var invoiceNumber string
+	query := `SELECT id, user_id, invoice_number FROM invoice
+		WHERE payment_status = 'Pending' AND due_date < NOW()
+		ORDER BY due_date
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	err = tx.QueryRow(query).Scan(&invoiceID, &userID, &invoiceNumber)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := closeInvoice(tx, invoiceID, "Expired", []string{"Pending"}, systemActor, "Payment window elapsed"); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Printf("[INFO] Invoice %s expired, stock restored (buyer %d)", invoiceNumber, userID)
+	return true, nil
+}
+
+// JobExpireInvoices adalah jenis job untuk ExpireOverdueInvoices
+const JobExpireInvoices = "invoice.expire"
+
+// RegisterJobs mendaftarkan job latar belakang milik modul order
+func RegisterJobs() error {
+	jobs.Register(JobExpireInvoices, func(ctx context.Context, _ json.RawMessage) error {
+		sqlDB, err := config.PostgresDB.DB()
+		if err != nil {
+			return err
+		}
+		n, err := ExpireOverdueInvoices(sqlDB)
+		if n > 0 {
+			log.Printf("[INFO] Invoice expiry: %d invoice(s) expired", n)
+		}
+		return err
+	})
+	return jobs.Schedule("invoice-expiry", config.InvoiceExpirySchedule, JobExpireInvoices)
+}
@@ -0,0 +1,214 @@
+package order
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/identity"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Jenis data yang perubahan statusnya dicatat di order_events
+const (
+	entityInvoice  = "invoice"
+	entityOrder    = "order"
+	entityShipment = "shipment"
+)
+
+// actor adalah pelaku perubahan status: akun, pengirim atau sistem (job latar belakang)
+type actor struct {
+	Type string
+	ID   int64
+}
+
+var systemActor = actor{Type: "system"}
+
+// actorFor mencari akun atau pengirim pemilik nomor telepon dari token
+func actorFor(db *sql.DB, noTelp string) (actor, error) {
+	c, err := identity.Lookup(db, noTelp)
+	if err != nil {
+		return actor{}, err
+	}
+	return actor{Type: c.Type, ID: c.ID}, nil
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordStatusChange mencatat satu perubahan status ke riwayat invoice. Peran pelaku ditentukan
+// relatif terhadap invoice: buyer, farm, courier, system, atau nama role akun untuk pihak lain.
+func recordStatusChange(tx execer, invoiceID int64, entity string, entityID int64, from, to string, a actor, reason string) error {
+	if from == to {
+		return nil
+	}
+	query := `INSERT INTO order_events (invoice_id, entity_type, entity_id, previous_status, new_status, actor_type, actor_id, actor_role, reason, created_at)
+		SELECT i.id, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, 0),
+			CASE
+				WHEN $6 = 'system' THEN 'system'
+				WHEN $6 = 'pengirim' THEN 'courier'
+				WHEN i.user_id = $7 THEN 'buyer'
+				WHEN EXISTS (SELECT 1 FROM farm_member m WHERE m.farm_id = i.farm_id AND m.akun_id = $7) THEN 'farm'
+				ELSE COALESCE((SELECT r.name_role FROM akun a JOIN role r ON r.id_role = a.id_role WHERE a.id_user = $7), 'unknown')
+			END,
+			NULLIF($8, ''), NOW()
+		FROM invoice i
+		WHERE i.id = $1`
+	_, err := tx.Exec(query, invoiceID, entity, entityID, from, to, a.Type, a.ID, reason)
+	return err
+}
+
+// recordStatusChanges mencatat perubahan semua order atau pengiriman milik invoice yang statusnya
+// belum to. Dipanggil sebelum UPDATE agar status lama masih terbaca.
+func recordStatusChanges(tx *sql.Tx, invoiceID int64, entity, to string, a actor, reason string) error {
+	query := `SELECT id, COALESCE(status, '') FROM orders WHERE invoice_id = $1 AND COALESCE(status, '') <> $2 ORDER BY id`
+	if entity == entityShipment {
+		query = `SELECT id, COALESCE(status_pengiriman, '') FROM proses_pengiriman WHERE id_invoice = $1 AND COALESCE(status_pengiriman, '') <> $2 ORDER BY id`
+	}
+	rows, err := tx.Query(query, invoiceID, to)
+	if err != nil {
+		return err
+	}
+	type change struct {
+		id   int64
+		from string
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.id, &c.from); err != nil {
+			rows.Close()
+			return err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := recordStatusChange(tx, invoiceID, entity, c.id, c.from, to, a, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type orderEvent struct {
+	ID             int64     `json:"id"`
+	EntityType     string    `json:"entity_type"`
+	EntityID       int64     `json:"entity_id"`
+	PreviousStatus *string   `json:"previous_status"`
+	NewStatus      string    `json:"new_status"`
+	ActorType      string    `json:"actor_type"`
+	ActorID        *int64    `json:"actor_id"`
+	ActorName      *string   `json:"actor_name"`
+	ActorRole      string    `json:"actor_role"`
+	Reason         *string   `json:"reason"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GetOrderHistory menampilkan riwayat status invoice, order dan pengiriman secara kronologis.
+// {invoice} dapat berupa ID atau nomor invoice; hanya pembeli, pemilik peternakan atau admin yang boleh melihat.
+func GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	c, err := identity.FromRequest(sqlDB, r)
+	if err == identity.ErrUnauthorized || err == identity.ErrNotFound {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve caller:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	ref := mux.Vars(r)["invoice"]
+	invoiceID, _ := strconv.ParseInt(ref, 10, 64)
+
+	var id, buyerID int64
+	var member bool
+	var invoiceNumber string
+	query := `SELECT i.id, i.invoice_number, i.user_id,
+			EXISTS (SELECT 1 FROM farm_member m WHERE m.farm_id = i.farm_id AND m.akun_id = $3)
+		FROM invoice i
+		WHERE i.id = $1 OR i.invoice_number = $2`
+	err = sqlDB.QueryRow(query, invoiceID, ref, c.ID).Scan(&id, &invoiceNumber, &buyerID, &member)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch invoice:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	allowed := c.Type == identity.TypeAkun && (c.ID == buyerID || member)
+	if !allowed {
+		if allowed, err = identity.IsAdmin(sqlDB, c.NoTelp); err != nil {
+			log.Println("[ERROR] Failed to check admin role:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !allowed {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+
+	query = `SELECT e.id, e.entity_type, e.entity_id, e.previous_status, e.new_status, e.actor_type, e.actor_id,
+			CASE e.actor_type
+				WHEN 'akun' THEN (SELECT a.nama FROM akun a WHERE a.id_user = e.actor_id)
+				WHEN 'pengirim' THEN (SELECT p.name FROM pengirim p WHERE p.id = e.actor_id)
+			END,
+			e.actor_role, e.reason, e.created_at
+		FROM order_events e
+		WHERE e.invoice_id = $1
+		ORDER BY e.created_at, e.id`
+	rows, err := sqlDB.Query(query, id)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch order history:", err)
+		http.Error(w, "Failed to fetch order history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	timeline := []orderEvent{}
+	for rows.Next() {
+		var e orderEvent
+		if err := rows.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.PreviousStatus, &e.NewStatus, &e.ActorType, &e.ActorID,
+			&e.ActorName, &e.ActorRole, &e.Reason, &e.CreatedAt); err != nil {
+			log.Println("[ERROR] Failed to scan order history:", err)
+			http.Error(w, "Failed to fetch order history", http.StatusInternalServerError)
+			return
+		}
+		timeline = append(timeline, e)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Order history retrieved successfully",
+		"data": map[string]interface{}{
+			"invoice_id":     id,
+			"invoice_number": invoiceNumber,
+			"events":         timeline,
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package order
+
+import (
+	"database/sql"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/invoicepdf"
+	"farmdistribution_be/helper/qty"
+	"farmdistribution_be/helper/watoken"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// invoiceVerifyURL adalah tautan publik yang dikodekan ke QR code pada invoice
+func invoiceVerifyURL(code string) string {
+	return strings.TrimRight(config.PublicBaseURL, "/") + "/invoice/verify/" + url.PathEscape(code)
+}
+
+// InvoicePDF mengirim invoice dalam bentuk PDF kepada pembeli atau pemilik peternakan
+func InvoicePDF(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		log.Println("Unauthorized: failed to decode token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var userID int64
+	if err := sqlDB.QueryRow(`SELECT id_user FROM akun WHERE no_telp = $1`, payload.Id).Scan(&userID); err != nil {
+		log.Println("Error retrieving user ID:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invoiceID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	var inv invoicepdf.Invoice
+	var checkoutNo, paymentMethod sql.NullString
+	var buyerAddress sql.NullString
+	query := `
+		SELECT i.invoice_number, c.checkout_number, i.issued_date, i.due_date, i.payment_method, i.payment_status,
+		       i.total_harga_product, COALESCE(i.shipping_cost, i.total_amount - i.total_harga_product),
+		       COALESCE(i.discount_amount, 0), COALESCE(i.tax_amount, 0), i.total_amount,
+		       COALESCE(f.name, ''), COALESCE(CONCAT_WS(', ', af.street, af.city, af.state, af.postal_code, af.country), ''),
+		       COALESCE(f.phonenumber_farm, ''), COALESCE(f.email, ''),
+		       a.nama, a.no_telp, COALESCE(a.email, ''),
+		       (SELECT pp.alamat_penerima FROM proses_pengiriman pp WHERE pp.id_invoice = i.id ORDER BY pp.id LIMIT 1)
+		FROM invoice i
+		JOIN akun a ON a.id_user = i.user_id
+		LEFT JOIN checkout c ON c.id = i.checkout_id
+		LEFT JOIN farms f ON f.id = i.farm_id
+		LEFT JOIN addressfarm af ON af.id_addressfarm = f.addressfarm_id
+		WHERE i.id = $1 AND (i.user_id = $2 OR EXISTS (SELECT 1 FROM farm_member m WHERE m.farm_id = i.farm_id AND m.akun_id = $2))`
+	err = sqlDB.QueryRow(query, invoiceID, userID).Scan(
+		&inv.Number, &checkoutNo, &inv.IssuedDate, &inv.DueDate, &paymentMethod, &inv.PaymentStatus,
+		&inv.Subtotal, &inv.Shipping, &inv.Discount, &inv.Tax, &inv.Total,
+		&inv.Farm.Name, &inv.Farm.Address, &inv.Farm.Phone, &inv.Farm.Email,
+		&inv.Buyer.Name, &inv.Buyer.Phone, &inv.Buyer.Email, &buyerAddress,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invoice not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error retrieving invoice:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	inv.CheckoutNo = checkoutNo.String
+	inv.PaymentMethod = paymentMethod.String
+	inv.Buyer.Address = buyerAddress.String
+
+	code, err := ensureVerificationCode(sqlDB, invoiceID, inv.Number)
+	if err != nil {
+		log.Println("Error creating verification code:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	inv.VerifyURL = invoiceVerifyURL(code)
+
+	rows, err := sqlDB.Query(`SELECT product_name, quantity, unit, unit_price, total_harga FROM orders WHERE invoice_id = $1 ORDER BY id`, invoiceID)
+	if err != nil {
+		log.Println("Error retrieving orders:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line invoicepdf.Line
+		var quantity qty.Qty
+		if err := rows.Scan(&line.Name, &quantity, &line.Unit, &line.UnitPrice, &line.Total); err != nil {
+			log.Println("Error scanning order row:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		line.Quantity = quantity.String()
+		inv.Lines = append(inv.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("Error iterating over order rows:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	out, err := invoicepdf.Render(inv)
+	if err != nil {
+		log.Println("Error rendering invoice PDF:", err)
+		http.Error(w, "Failed to render invoice", http.StatusInternalServerError)
+		return
+	}
+
+	filename := strings.NewReplacer("/", "-", " ", "_").Replace(inv.Number) + ".pdf"
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Write(out)
+}
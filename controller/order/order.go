@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
 	"farmdistribution_be/helper/format"
 	"farmdistribution_be/helper/ghupload"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/inventory"
+	"farmdistribution_be/helper/listquery"
+	"farmdistribution_be/helper/numbering"
+	"farmdistribution_be/helper/qty"
 	"farmdistribution_be/helper/watoken"
 	"farmdistribution_be/model"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -41,137 +49,222 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var Orders struct {
-		Products         []model.Product `json:"products"`
-		PengirimanID     int             `json:"pengiriman_id"`
-		PaymentMethod    string          `json:"payment_method"`
-		DistanceKM       float64         `json:"distance_km"`
-		AlamatPengirim   string          `json:"alamat_pengirim"`
-		AlamatPenerima   string          `json:"alamat_penerima"`
-		LocationPengirim []float64       `json:"location_pengirim"`
-		LocationPenerima []float64       `json:"location_penerima"`
-	}
+	var Orders model.CheckoutRequest
 	if err := json.NewDecoder(r.Body).Decode(&Orders); err != nil {
 		log.Println("Error decoding request body:", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	if len(Orders.Products) == 0 || Orders.PengirimanID == 0 {
-		log.Println("Validation failed: Products or Pengiriman ID cannot be empty.")
-		http.Error(w, "Products and Pengiriman ID are required", http.StatusBadRequest)
-		return
-	}
-
-	var fuelConsumption, fuelPrice float64
-	queryPengiriman := `SELECT fuel_consumption, fuel_price FROM pengiriman WHERE id = $1`
-	err = sqlDB.QueryRow(queryPengiriman, Orders.PengirimanID).Scan(&fuelConsumption, &fuelPrice)
-	if err != nil {
-		log.Println("Error retrieving shipping data:", err)
-		http.Error(w, "Invalid Pengiriman ID", http.StatusBadRequest)
-		return
-	}
-
-	shippingCost := Orders.DistanceKM * fuelConsumption * fuelPrice
-
 	tx, err := sqlDB.Begin()
 	if err != nil {
 		log.Println("Failed to start transaction:", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-			log.Println("Transaction rolled back due to error")
+	defer tx.Rollback()
+
+	// Jika quote_id dikirim, pembeli membayar persis sesuai quote yang ditampilkan
+	var quote model.Quote
+	if Orders.QuoteID != "" {
+		paymentMethod := Orders.PaymentMethod
+		Orders, quote, err = consumeQuote(tx, Orders.QuoteID, ownerID)
+		if paymentMethod != "" {
+			Orders.PaymentMethod = paymentMethod
 		}
-	}()
-
-	invoiceNumber := fmt.Sprintf("INV-%d-%d", ownerID, time.Now().Unix())
-	var invoiceId int
-	insertInvoiceQuery := `INSERT INTO invoice (user_id, invoice_number, payment_status, payment_method, issued_date, due_date, total_amount, created_at, updated_at) VALUES ($1, $2, 'Pending', $3, NOW(), NOW() + INTERVAL '7 days', 0, NOW(), NOW()) RETURNING id`
-	err = tx.QueryRow(insertInvoiceQuery, ownerID, invoiceNumber, Orders.PaymentMethod).Scan(&invoiceId)
+	} else {
+		if Orders.FromCart {
+			Orders.Products, err = loadCartProducts(tx, ownerID)
+		}
+		if err == nil {
+			quote, err = priceCart(tx, Orders)
+		}
+	}
 	if err != nil {
-		log.Println("Error inserting invoice:", err)
+		writeCheckoutError(w, err, "Failed to calculate order total")
 		return
 	}
 
-	var prosesPengirimanID int
-	insertShippingQuery := `INSERT INTO proses_pengiriman 
-		(hari_dikirim, tanggal_dikirim, id_invoice, status_pengiriman, alamat_pengirim, alamat_penerima, location_pengirim, location_penerima, created_at, updated_at) 
-		VALUES ($1, NOW(), $2, 'Pending', $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326), ST_SetSRID(ST_MakePoint($7, $8), 4326), NOW(), NOW()) RETURNING id`
-
-	err = tx.QueryRow(insertShippingQuery,
-		time.Now().Weekday().String(), invoiceId,
-		Orders.AlamatPengirim, Orders.AlamatPenerima,
-		Orders.LocationPengirim[0], Orders.LocationPengirim[1],
-		Orders.LocationPenerima[0], Orders.LocationPenerima[1]).Scan(&prosesPengirimanID)
+	if len(Orders.LocationPenerima) != 2 {
+		log.Println("Validation failed: location_penerima must be [lon, lat].")
+		http.Error(w, "Location penerima is required", http.StatusBadRequest)
+		return
+	}
 
+	// Checkout induk: pembeli membayar sekali untuk seluruh keranjang
+	now := time.Now()
+	checkoutNumber, err := numbering.Issue(tx, "checkout", config.CheckoutNumberFormat, "ALL", "ALL", now)
+	if err != nil {
+		log.Println("Error issuing checkout number:", err)
+		http.Error(w, "Failed to create checkout", http.StatusInternalServerError)
+		return
+	}
+	var checkoutID int
+	insertCheckoutQuery := `INSERT INTO checkout (user_id, checkout_number, payment_status, payment_method, total_amount, quote_id, created_at, updated_at) VALUES ($1, $2, 'Pending', $3, $4, NULLIF($5, ''), NOW(), NOW()) RETURNING id`
+	err = tx.QueryRow(insertCheckoutQuery, ownerID, checkoutNumber, Orders.PaymentMethod, quote.GrandTotal, Orders.QuoteID).Scan(&checkoutID)
 	if err != nil {
-		log.Println("Error inserting shipping process:", err)
-		tx.Rollback()
-		http.Error(w, "Failed to create shipping process", http.StatusInternalServerError)
+		log.Println("Error inserting checkout:", err)
+		http.Error(w, "Failed to create checkout", http.StatusInternalServerError)
 		return
 	}
 
-	for _, product := range Orders.Products {
-		var productPrice, stockKg float64
-		queryProduct := `SELECT price_per_kg, stock_kg FROM farm_products WHERE id = $1`
-		err = tx.QueryRow(queryProduct, product.ProductID).Scan(&productPrice, &stockKg)
+	// Satu sub-invoice dan satu pengiriman untuk setiap peternakan di keranjang
+	var invoices []map[string]interface{}
+	for _, farm := range quote.Farms {
+		// Nomor invoice berurutan per peternakan per tahun; counter memakai id peternakan
+		// dan kode hanya dipakai saat render
+		var farmCode string
+		err = tx.QueryRow(`SELECT COALESCE(code, 'F' || LPAD(id::TEXT, GREATEST(LENGTH(id::TEXT), 4), '0')) FROM farms WHERE id = $1`, farm.FarmID).Scan(&farmCode)
 		if err != nil {
-			log.Println("Error retrieving product details:", err)
-			tx.Rollback()
-			http.Error(w, "Product not found", http.StatusBadRequest)
+			log.Println("Error retrieving farm code:", err)
+			http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
 			return
 		}
-
-		if stockKg < float64(product.Quantity) {
-			log.Println("Stock tidak mencukupi untuk produk:", product.ProductID)
-			tx.Rollback()
-			http.Error(w, "Stock is insufficient", http.StatusBadRequest)
+		invoiceNumber, err := numbering.Issue(tx, "invoice", config.InvoiceNumberFormat,
+			strconv.FormatInt(farm.FarmID, 10), farmCode, now)
+		if err != nil {
+			log.Println("Error issuing invoice number:", err)
+			http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
+			return
+		}
+		verificationCode, err := newVerificationCode(invoiceNumber)
+		if err != nil {
+			log.Println("Error creating verification code:", err)
+			http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
+			return
+		}
+		var invoiceId int
+		insertInvoiceQuery := `INSERT INTO invoice (user_id, checkout_id, farm_id, invoice_number, payment_status, payment_method, issued_date, due_date, total_amount, total_harga_product, shipping_cost, discount_amount, tax_amount, quote_id, verification_code, created_at, updated_at) VALUES ($1, $2, $3, $4, 'Pending', $5, NOW(), NOW() + make_interval(hours => (SELECT payment_window_hours FROM farms WHERE id = $3)), $6, $7, $8, $9, $10, NULLIF($11, ''), $12, NOW(), NOW()) RETURNING id`
+		err = tx.QueryRow(insertInvoiceQuery, ownerID, checkoutID, farm.FarmID, invoiceNumber, Orders.PaymentMethod,
+			farm.Total, farm.Subtotal, farm.ShippingCost, farm.Discount, farm.Tax, Orders.QuoteID, verificationCode).Scan(&invoiceId)
+		if err != nil {
+			log.Println("Error inserting invoice:", err)
+			http.Error(w, "Failed to create invoice", http.StatusInternalServerError)
 			return
 		}
 
-		productTotal := productPrice * float64(product.Quantity)
+		// Asal pengiriman adalah peternakan itu sendiri, kecuali keranjang satu peternakan yang mengirim alamat pengirim
+		alamatPengirim := Orders.AlamatPengirim
+		useFarmOrigin := len(quote.Farms) > 1 || len(Orders.LocationPengirim) != 2
+		if useFarmOrigin || alamatPengirim == "" {
+			queryFarmAddress := `SELECT COALESCE(CONCAT_WS(', ', af.street, af.city, af.state, af.postal_code, af.country), '')
+				FROM farms f LEFT JOIN addressfarm af ON f.addressfarm_id = af.id_addressfarm WHERE f.id = $1`
+			err = tx.QueryRow(queryFarmAddress, farm.FarmID).Scan(&alamatPengirim)
+			if err != nil {
+				log.Println("Error retrieving farm address:", err)
+				http.Error(w, "Failed to create shipping process", http.StatusInternalServerError)
+				return
+			}
+		}
 
-		insertOrderQuery := `INSERT INTO orders (user_id, product_id, quantity, total_harga, status, pengiriman_id, invoice_id, id_proses_pengiriman, created_at, updated_at) VALUES ($1, $2, $3, $4, 'Pending', $5, $6, $7, NOW(), NOW())`
-		_, err = tx.Exec(insertOrderQuery, ownerID, product.ProductID, product.Quantity, productTotal, Orders.PengirimanID, invoiceId, prosesPengirimanID)
+		var prosesPengirimanID int
+		if useFarmOrigin {
+			insertShippingQuery := `INSERT INTO proses_pengiriman 
+				(hari_dikirim, tanggal_dikirim, id_invoice, status_pengiriman, alamat_pengirim, alamat_penerima, location_pengirim, location_penerima, created_at, updated_at) 
+				VALUES ($1, NOW(), $2, 'Pending', $3, $4, (SELECT location FROM farms WHERE id = $5), ST_SetSRID(ST_MakePoint($6, $7), 4326), NOW(), NOW()) RETURNING id`
+			err = tx.QueryRow(insertShippingQuery,
+				now.Weekday().String(), invoiceId,
+				alamatPengirim, Orders.AlamatPenerima, farm.FarmID,
+				Orders.LocationPenerima[0], Orders.LocationPenerima[1]).Scan(&prosesPengirimanID)
+		} else {
+			insertShippingQuery := `INSERT INTO proses_pengiriman 
+				(hari_dikirim, tanggal_dikirim, id_invoice, status_pengiriman, alamat_pengirim, alamat_penerima, location_pengirim, location_penerima, created_at, updated_at) 
+				VALUES ($1, NOW(), $2, 'Pending', $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326), ST_SetSRID(ST_MakePoint($7, $8), 4326), NOW(), NOW()) RETURNING id`
+			err = tx.QueryRow(insertShippingQuery,
+				now.Weekday().String(), invoiceId,
+				alamatPengirim, Orders.AlamatPenerima,
+				Orders.LocationPengirim[0], Orders.LocationPengirim[1],
+				Orders.LocationPenerima[0], Orders.LocationPenerima[1]).Scan(&prosesPengirimanID)
+		}
 		if err != nil {
-			log.Println("Error inserting order:", err)
-			tx.Rollback()
+			log.Println("Error inserting shipping process:", err)
+			http.Error(w, "Failed to create shipping process", http.StatusInternalServerError)
+			return
+		}
+
+		for _, line := range quote.Lines {
+			if line.FarmID != farm.FarmID {
+				continue
+			}
+
+			// Nama, harga, satuan dan gambar produk disimpan sebagai snapshot agar riwayat order tidak berubah
+			insertOrderQuery := `INSERT INTO orders (user_id, product_id, quantity, total_harga, status, pengiriman_id, invoice_id, id_proses_pengiriman,
+				product_name, unit_price, unit, image_url, catch_weight, estimated_weight_kg, price_per_kg, weight_tolerance_percent, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, 'Pending', $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW()) RETURNING id`
+			var orderID int64
+			err = tx.QueryRow(insertOrderQuery, ownerID, line.ProductID, line.Quantity, line.LineTotal, Orders.PengirimanID, invoiceId, prosesPengirimanID,
+				line.ProductName, line.UnitPrice, line.Unit, line.ImageURL, line.CatchWeight, line.StockQty, line.PricePerKg, line.WeightTolerance).Scan(&orderID)
+			if err != nil {
+				log.Println("Error inserting order:", err)
+				http.Error(w, "Failed to create order", http.StatusInternalServerError)
+				return
+			}
+
+			// Stok dicek ulang saat dikurangi karena quote bisa dibuat sebelum stok berubah
+			stock, err := inventory.Move(tx, inventory.Movement{
+				ProductID:  line.ProductID,
+				Type:       inventory.Sale,
+				QuantityKg: -line.StockQty,
+				Reason:     "Order " + invoiceNumber,
+				ActorType:  identity.TypeAkun,
+				ActorID:    int64(ownerID),
+				RefType:    "order",
+				RefID:      orderID,
+			})
+			if err == inventory.ErrInsufficient || err == inventory.ErrNotFound {
+				log.Println("Stock tidak mencukupi untuk produk:", line.ProductID)
+				http.Error(w, "Stock is insufficient", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Println("Error updating product stock:", err)
+				http.Error(w, "Failed to update stock", http.StatusInternalServerError)
+				return
+			}
+
+			// Event stok menipis hanya terbit saat stok melewati batas, bukan setiap order berikutnya
+			stockLeft, lowStock := stock.BalanceKg.Float64(), stock.LowStockKg
+			if lowStock.Valid && stockLeft < lowStock.Float64 && stockLeft+line.StockQty.Float64() >= lowStock.Float64 {
+				err = events.Publish(tx, events.ProductLowStock, "product", line.ProductID, events.ProductPayload{
+					ProductID: line.ProductID, FarmID: farm.FarmID, Name: line.ProductName, StockKg: stockLeft, ThresholdKg: lowStock.Float64,
+				})
+				if err != nil {
+					log.Println("Error publishing low stock event:", err)
+					http.Error(w, "Failed to update stock", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		buyer := actor{Type: identity.TypeAkun, ID: int64(ownerID)}
+		if err := recordStatusChange(tx, int64(invoiceId), entityInvoice, int64(invoiceId), "", "Pending", buyer, ""); err != nil {
+			log.Println("Error recording order history:", err)
 			http.Error(w, "Failed to create order", http.StatusInternalServerError)
 			return
 		}
 
-		updateStockQuery := `UPDATE farm_products SET stock_kg = stock_kg - $1 WHERE id = $2`
-		_, err = tx.Exec(updateStockQuery, product.Quantity, product.ProductID)
-		if err != nil {
-			log.Println("Error updating product stock:", err)
-			tx.Rollback()
-			http.Error(w, "Failed to update stock", http.StatusInternalServerError)
+		if err := publishInvoiceEvent(tx, events.OrderPlaced, int64(invoiceId), ""); err != nil {
+			log.Println("Error publishing order event:", err)
+			http.Error(w, "Failed to create order", http.StatusInternalServerError)
 			return
 		}
-	}
 
-	var totalHargaOrders float64
-	queryTotalHarga := `SELECT COALESCE(SUM(total_harga), 0) FROM orders WHERE invoice_id = $1`
-	err = tx.QueryRow(queryTotalHarga, invoiceId).Scan(&totalHargaOrders)
-	if err != nil {
-		log.Println("Error retrieving total harga from orders:", err)
-		tx.Rollback()
-		http.Error(w, "Failed to calculate total order amount", http.StatusInternalServerError)
-		return
+		invoices = append(invoices, map[string]interface{}{
+			"invoice_id":     invoiceId,
+			"invoice_number": invoiceNumber,
+			"farm_id":        farm.FarmID,
+			"farm_name":      farm.FarmName,
+			"shipping_cost":  format.FormatCurrency(farm.ShippingCost) + "0",
+			"total_amount":   format.FormatCurrency(farm.Total) + "0",
+		})
 	}
 
-	totalAmount := totalHargaOrders + shippingCost
-
-	updateInvoiceQuery := `UPDATE invoice SET total_amount = $1, total_harga_product = $2 WHERE id = $3`
-	_, err = tx.Exec(updateInvoiceQuery, totalAmount, totalHargaOrders, invoiceId)
-	if err != nil {
-		log.Println("Error updating invoice total_amount:", err)
-		tx.Rollback()
-		http.Error(w, "Failed to update invoice total amount", http.StatusInternalServerError)
-		return
+	if Orders.FromCart {
+		if err := clearOrderedCartItems(tx, ownerID, quote.Lines); err != nil {
+			log.Println("Error clearing cart:", err)
+			http.Error(w, "Failed to clear cart", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -183,13 +276,33 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":        "Order and Invoice created successfully",
-		"invoice_number": invoiceNumber,
-		"shipping_cost":  format.FormatCurrency(shippingCost) + "0",
+		"message":         "Order and Invoice created successfully",
+		"checkout_id":     checkoutID,
+		"checkout_number": checkoutNumber,
+		"invoice_number":  invoices[0]["invoice_number"],
+		"invoices":        invoices,
+		"shipping_cost":   format.FormatCurrency(quote.ShippingTotal) + "0",
+		"discount":        format.FormatCurrency(quote.DiscountTotal) + "0",
+		"tax":             format.FormatCurrency(quote.TaxTotal) + "0",
+		"total_amount":    format.FormatCurrency(quote.GrandTotal) + "0",
 	})
 }
 
-// GetOrdersByFarm retrieves all orders for a specific farm
+// farmOrderListSpec adalah field /all/order yang boleh diurutkan, difilter dan dicari
+var farmOrderListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"invoice_number": {Column: "i.invoice_number", Type: listquery.Text, Sort: true, Filter: true},
+		"payment_status": {Column: "i.payment_status", Type: listquery.Text, Sort: true, Filter: true},
+		"created_at":     {Column: "i.created_at", Type: listquery.Time, Sort: true, Filter: true},
+		"total_amount":   {Column: "i.total_amount", Type: listquery.Float, Sort: true, Filter: true},
+		"nama_pembeli":   {Column: "a.nama", Type: listquery.Text, Sort: true, Filter: true},
+	},
+	ID:     "i.id",
+	Search: []string{"i.invoice_number", "a.nama", "a.no_telp", "a.email"},
+}
+
+// GetOrdersByFarm menampilkan sub-invoice peternakan per halaman beserta produknya;
+// parameter mengikuti listquery
 func GetOrdersByFarm(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -222,113 +335,118 @@ func GetOrdersByFarm(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	log.Println("[INFO] Owner ID found:", ownerID)
 
 	// Mendapatkan farm ID
-	var farmId int64
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ViewFarm)
 	if err != nil {
-		log.Println("[ERROR] Failed to find farm ID:", err)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given owner ID.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
 
-	// Query untuk mendapatkan data orders berdasarkan farm ID
-	query = `SELECT o.id, o.invoice_id, o.product_id, o.quantity, o.total_harga, o.status, o.created_at, o.updated_at, i.invoice_number, i.total_amount, i.user_id, i.proof_of_transfer
-              FROM orders o
-              JOIN farm_products fp ON o.product_id = fp.id
-              JOIN invoice i ON o.invoice_id = i.id
-              WHERE fp.farm_id = $1`
-
-	rows, err := sqlDB.Query(query, farmId)
+	lq, err := listquery.Parse(r, farmOrderListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+	// Setiap sub-invoice milik satu peternakan, sehingga peternakan hanya melihat bagiannya
+	lq.Where("i.farm_id = ?", membership.FarmID)
+
+	page, err := listquery.Run(sqlDB, lq,
+		`i.id, i.invoice_number, i.payment_status, i.created_at, i.total_amount, i.proof_of_transfer, a.nama, a.no_telp, a.email`,
+		`invoice i JOIN akun a ON a.id_user = i.user_id`,
+		func(rows *sql.Rows, key ...interface{}) (map[string]interface{}, error) {
+			var inv struct {
+				ID              int64
+				InvoiceNo       string
+				PaymentStatus   string
+				CreatedAt       time.Time
+				TotalAmount     float64
+				ProofOfTransfer *string
+				Nama            string
+				NoTelp          string
+				Email           string
+			}
+			err := rows.Scan(append([]interface{}{&inv.ID, &inv.InvoiceNo, &inv.PaymentStatus, &inv.CreatedAt, &inv.TotalAmount,
+				&inv.ProofOfTransfer, &inv.Nama, &inv.NoTelp, &inv.Email}, key...)...)
+			return map[string]interface{}{
+				"invoice_id":        inv.ID,
+				"invoice_number":    inv.InvoiceNo,
+				"payment_status":    inv.PaymentStatus,
+				"created_at":        inv.CreatedAt,
+				"total_amount":      format.FormatCurrency(inv.TotalAmount) + "0",
+				"nama_pembeli":      inv.Nama,
+				"no_telp":           inv.NoTelp,
+				"email":             inv.Email,
+				"products":          []map[string]interface{}{},
+				"proof_of_transfer": inv.ProofOfTransfer,
+			}, err
+		})
 	if err != nil {
 		log.Println("Error retrieving orders by farm:", err)
-		http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	// Struktur data untuk menyimpan hasil
-	invoices := make(map[int64]map[string]interface{})
 
-	for rows.Next() {
-		var order struct {
-			OrderID         int64
-			InvoiceID       int64
-			ProductID       int64
-			Quantity        int
-			TotalHarga      float64
-			Status          string
-			CreatedAt       string
-			UpdatedAt       string
-			InvoiceNo       string
-			TotalAmount     float64
-			UserID          int64
-			ProofOfTransfer *string
-		}
-
-		if err := rows.Scan(&order.OrderID, &order.InvoiceID, &order.ProductID, &order.Quantity, &order.TotalHarga, &order.Status, &order.CreatedAt, &order.UpdatedAt, &order.InvoiceNo, &order.TotalAmount, &order.UserID, &order.ProofOfTransfer); err != nil {
-			log.Println("Error scanning order row:", err)
-			http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
-			return
-		}
-
-		// Query untuk mendapatkan nama user dari tabel akun
-		var user struct {
-			userName string
-			noTelp   string
-			email    string
-		}
-		queryUser := `SELECT nama, no_telp, email FROM akun WHERE id_user = $1`
-		err = sqlDB.QueryRow(queryUser, order.UserID).Scan(&user.userName, &user.noTelp, &user.email)
+	// Produk hanya dimuat untuk invoice pada halaman ini
+	byInvoice := make(map[int64]map[string]interface{}, len(page.Items))
+	ids := make([]string, 0, len(page.Items))
+	for _, inv := range page.Items {
+		id := inv["invoice_id"].(int64)
+		byInvoice[id] = inv
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	if len(ids) > 0 {
+		rows, err := sqlDB.Query(`SELECT o.id, o.invoice_id, o.product_id, o.product_name, o.unit_price, o.unit, o.image_url, o.quantity, o.total_harga, o.status, o.created_at, o.updated_at
+			FROM orders o
+			WHERE o.invoice_id = ANY(string_to_array($1, ',')::bigint[])
+			ORDER BY o.id`, strings.Join(ids, ","))
 		if err != nil {
-			log.Println("[ERROR] Failed to retrieve user name:", err)
-			http.Error(w, "Failed to retrieve user data", http.StatusInternalServerError)
+			log.Println("Error retrieving orders by farm:", err)
+			http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
 			return
 		}
-
-		// Organisasi data berdasarkan invoice
-		if _, exists := invoices[order.InvoiceID]; !exists {
-			invoices[order.InvoiceID] = map[string]interface{}{
-				"invoice_id":        order.InvoiceID,
-				"invoice_number":    order.InvoiceNo,
-				"total_amount":      format.FormatCurrency(order.TotalAmount) + "0",
-				"nama_pembeli":      user.userName,
-				"no_telp":           user.noTelp,
-				"email":             user.email, // Tambahkan nama user
-				"products":          []map[string]interface{}{},
-				"proof_of_transfer": order.ProofOfTransfer, // Tambahkan proof_of_transfer jika ada
+		defer rows.Close()
+
+		for rows.Next() {
+			var order struct {
+				OrderID     int64
+				InvoiceID   int64
+				ProductID   *int64
+				ProductName string
+				UnitPrice   float64
+				Unit        string
+				ImageURL    string
+				Quantity    qty.Qty
+				TotalHarga  float64
+				Status      string
+				CreatedAt   string
+				UpdatedAt   string
+			}
+			if err := rows.Scan(&order.OrderID, &order.InvoiceID, &order.ProductID, &order.ProductName, &order.UnitPrice, &order.Unit, &order.ImageURL, &order.Quantity, &order.TotalHarga, &order.Status, &order.CreatedAt, &order.UpdatedAt); err != nil {
+				log.Println("Error scanning order row:", err)
+				http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
+				return
 			}
-		}
 
-		product := map[string]interface{}{
-			"order_id":    order.OrderID,
-			"product_id":  order.ProductID,
-			"quantity":    order.Quantity,
-			"total_harga": format.FormatCurrency(order.TotalHarga) + "0",
-			"status":      order.Status,
-			"created_at":  order.CreatedAt,
-			"updated_at":  order.UpdatedAt,
+			product := map[string]interface{}{
+				"order_id":     order.OrderID,
+				"product_id":   order.ProductID,
+				"product_name": order.ProductName,
+				"unit_price":   format.FormatCurrency(order.UnitPrice) + "0",
+				"unit":         order.Unit,
+				"image_url":    order.ImageURL,
+				"quantity":     order.Quantity,
+				"total_harga":  format.FormatCurrency(order.TotalHarga) + "0",
+				"status":       order.Status,
+				"created_at":   order.CreatedAt,
+				"updated_at":   order.UpdatedAt,
+			}
+			inv := byInvoice[order.InvoiceID]
+			inv["products"] = append(inv["products"].([]map[string]interface{}), product)
 		}
-
-		invoices[order.InvoiceID]["products"] = append(invoices[order.InvoiceID]["products"].([]map[string]interface{}), product)
-	}
-
-	// Konversi map ke array untuk response JSON
-	var result []map[string]interface{}
-	for _, invoice := range invoices {
-		result = append(result, invoice)
 	}
 
-	// Kirimkan response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
-	log.Println("Proses pengambilan order berdasarkan peternakan selesai.")
+	listquery.Write(w, "Orders retrieved successfully", page)
 }
 
 // GetOrderByID retrieves a single order by its ID
@@ -358,11 +476,14 @@ func GetOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		DueDate       string  `json:"due_date"`
 		TotalAmount   float64 `json:"total_amount"`
 		ShippingCost  float64 `json:"shipping_cost"`
+		Discount      float64 `json:"discount_amount"`
+		Tax           float64 `json:"tax_amount"`
 		TotalHarga    float64 `json:"total_harga_product"`
 	}
 	queryInvoice := `
 		SELECT invoice_number, payment_status, payment_method, issued_date, due_date, total_amount, 
-		       (total_amount - total_harga_product) AS shipping_cost, total_harga_product
+		       COALESCE(shipping_cost, total_amount - total_harga_product) AS shipping_cost,
+		       COALESCE(discount_amount, 0), COALESCE(tax_amount, 0), total_harga_product
 		FROM invoice WHERE id = $1`
 	err = sqlDB.QueryRow(queryInvoice, invoiceID).Scan(
 		&invoice.InvoiceNumber,
@@ -372,6 +493,8 @@ func GetOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		&invoice.DueDate,
 		&invoice.TotalAmount,
 		&invoice.ShippingCost,
+		&invoice.Discount,
+		&invoice.Tax,
 		&invoice.TotalHarga,
 	)
 	if err != nil {
@@ -386,16 +509,23 @@ func GetOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ambil data orders berdasarkan id_invoice
-	var orders []struct {
-		ProductID   int     `json:"product_id"`
-		ProductName string  `json:"product_name"`
-		Quantity    int     `json:"quantity"`
-		TotalHarga  float64 `json:"total_harga"`
+	type orderLine struct {
+		ProductID   *int64   `json:"product_id"`
+		ProductName string   `json:"product_name"`
+		UnitPrice   float64  `json:"unit_price"`
+		Unit        string   `json:"unit"`
+		ImageURL    string   `json:"image_url"`
+		Quantity    qty.Qty  `json:"quantity"`
+		TotalHarga  float64  `json:"total_harga"`
+		CatchWeight bool     `json:"catch_weight"`
+		Estimated   qty.Qty  `json:"estimated_weight_kg"`
+		Actual      *qty.Qty `json:"actual_weight_kg"`
 	}
+	var orders []orderLine
 	queryOrders := `
-		SELECT o.product_id, fp.name AS product_name, o.quantity, o.total_harga
+		SELECT o.product_id, o.product_name, o.unit_price, o.unit, o.image_url, o.quantity, o.total_harga,
+		       o.catch_weight, o.estimated_weight_kg, o.actual_weight_kg
 		FROM orders o
-		JOIN farm_products fp ON o.product_id = fp.id
 		WHERE o.invoice_id = $1`
 	rows, err := sqlDB.Query(queryOrders, invoiceID)
 	if err != nil {
@@ -406,13 +536,9 @@ func GetOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var order struct {
-			ProductID   int     `json:"product_id"`
-			ProductName string  `json:"product_name"`
-			Quantity    int     `json:"quantity"`
-			TotalHarga  float64 `json:"total_harga"`
-		}
-		if err := rows.Scan(&order.ProductID, &order.ProductName, &order.Quantity, &order.TotalHarga); err != nil {
+		var order orderLine
+		if err := rows.Scan(&order.ProductID, &order.ProductName, &order.UnitPrice, &order.Unit, &order.ImageURL, &order.Quantity, &order.TotalHarga,
+			&order.CatchWeight, &order.Estimated, &order.Actual); err != nil {
 			log.Println("Error scanning order row:", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
@@ -426,16 +552,25 @@ func GetOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	adjustments, err := getInvoiceAdjustments(sqlDB, invoiceID)
+	if err != nil {
+		log.Println("Error retrieving invoice adjustments:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	// Response sukses
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"invoice": invoice,
-		"orders":  orders,
+		"invoice":     invoice,
+		"orders":      orders,
+		"adjustments": adjustments,
 	})
 }
 
-// DeleteOrder deletes an order by its ID
+// DeleteOrderByInvoiceID membatalkan (void) invoice beserta order-nya.
+// Invoice tidak dihapus agar nomor invoice tetap berurutan untuk keperluan pajak.
 func DeleteOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -444,7 +579,7 @@ func DeleteOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
 	if err != nil {
 		log.Println("[ERROR] Invalid or expired token:", err)
 		w.WriteHeader(http.StatusUnauthorized)
@@ -455,9 +590,19 @@ func DeleteOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var userID int64
+	query := `SELECT id_user FROM akun WHERE no_telp = $1`
+	if err := sqlDB.QueryRow(query, payload.Id).Scan(&userID); err != nil {
+		log.Println("Error retrieving user ID:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Decode request body untuk mendapatkan invoice_id
 	var requestData struct {
-		InvoiceID int64 `json:"invoice_id"`
+		InvoiceID int64  `json:"invoice_id"`
+		Reason    string `json:"reason"`
+		Refund    bool   `json:"refund"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
 		log.Println("Error decoding request body:", err)
@@ -472,31 +617,81 @@ func DeleteOrderByInvoiceID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Hapus data dari tabel orders berdasarkan id_invoice
-	deleteOrdersQuery := `DELETE FROM orders WHERE invoice_id = $1`
-	_, err = sqlDB.Exec(deleteOrdersQuery, requestData.InvoiceID)
+	// Hanya pembeli atau anggota peternakan dengan hak pembatalan yang boleh membatalkan invoice.
+	// Pembeli hanya dapat membatalkan invoice Pending; refund invoice Paid hanya oleh peternakan.
+	var isBuyer, isFarm bool
+	query = `SELECT i.user_id = $2, EXISTS (
+			SELECT 1 FROM farm_member m
+			WHERE m.farm_id = i.farm_id AND m.akun_id = $2 AND m.role = ANY(string_to_array($3, ',')))
+		FROM invoice i WHERE i.id = $1`
+	err = sqlDB.QueryRow(query, requestData.InvoiceID, userID, farmaccess.RolesWith(farmaccess.CancelOrders)).Scan(&isBuyer, &isFarm)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("Error checking invoice access:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !isBuyer && !isFarm {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	if requestData.Refund && !isFarm {
+		http.Error(w, "Only the farm can refund a paid invoice", http.StatusForbidden)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
 	if err != nil {
-		log.Println("Error deleting orders:", err)
-		http.Error(w, "Failed to delete orders", http.StatusInternalServerError)
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	// Hapus data dari tabel invoice berdasarkan id_invoice
-	deleteInvoiceQuery := `DELETE FROM invoice WHERE id = $1`
-	_, err = sqlDB.Exec(deleteInvoiceQuery, requestData.InvoiceID)
+	reason := requestData.Reason
+	if reason == "" {
+		reason = "Cancelled by user"
+	}
+	action := "invoice.cancel"
+	if requestData.Refund {
+		action = "invoice.refund"
+		if requestData.Reason == "" {
+			reason = "Refunded by farm"
+		}
+	}
+	entry := audit.New(r, identity.TypeAkun, userID, action, "invoice", requestData.InvoiceID)
+	entry.Before, err = audit.Snapshot(tx, "invoice", "id", requestData.InvoiceID)
 	if err != nil {
-		log.Println("Error deleting invoice:", err)
-		http.Error(w, "Failed to delete invoice", http.StatusInternalServerError)
+		log.Println("Error reading invoice for audit:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := voidInvoice(tx, requestData.InvoiceID, actor{Type: identity.TypeAkun, ID: userID}, reason, requestData.Refund); err != nil {
+		writeCheckoutError(w, err, "Failed to cancel invoice")
+		return
+	}
+	entry.After, err = audit.Snapshot(tx, "invoice", "id", requestData.InvoiceID)
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err != nil {
+		log.Println("Error recording audit log:", err)
+		http.Error(w, "Failed to cancel invoice", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 		return
 	}
 
 	// Response sukses
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":    "Order and Invoice deleted successfully",
+		"message":    "Order and Invoice cancelled successfully",
 		"invoice_id": requestData.InvoiceID,
 	})
-	log.Println("Proses penghapusan order dan invoice selesai.")
+	log.Println("Proses pembatalan order dan invoice selesai.")
 }
 
 // UpdateOrder updates an order's details
@@ -508,7 +703,7 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
 	if err != nil {
 		log.Println("[ERROR] Invalid or expired token:", err)
 		w.WriteHeader(http.StatusUnauthorized)
@@ -523,6 +718,7 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	var requestData struct {
 		InvoiceID int64  `json:"invoice_id"`
 		Status    string `json:"status"`
+		Reason    string `json:"reason"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
 		log.Println("Error decoding request body:", err)
@@ -537,9 +733,36 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a, err := actorFor(sqlDB, payload.Id)
+	if err != nil {
+		log.Println("Error retrieving user ID:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Status lama dicatat ke riwayat sebelum ditimpa
+	if _, err := tx.Exec(`SELECT id FROM orders WHERE invoice_id = $1 FOR UPDATE`, requestData.InvoiceID); err != nil {
+		log.Println("Error locking orders:", err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+	if err := recordStatusChanges(tx, requestData.InvoiceID, entityOrder, requestData.Status, a, requestData.Reason); err != nil {
+		log.Println("Error recording order history:", err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
 	// Update status berdasarkan id_invoice
 	query := `UPDATE orders SET status = $1 WHERE invoice_id = $2`
-	result, err := sqlDB.Exec(query, requestData.Status, requestData.InvoiceID)
+	result, err := tx.Exec(query, requestData.Status, requestData.InvoiceID)
 	if err != nil {
 		log.Println("Error updating order status:", err)
 		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
@@ -553,6 +776,11 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No orders found with the given invoice ID", http.StatusNotFound)
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing transaction:", err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
 
 	// Response sukses
 	w.Header().Set("Content-Type", "application/json")
@@ -564,6 +792,25 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	log.Println("Proses update status order selesai.")
 }
 
+// buyerOrderListSpec adalah field daftar invoice pembeli yang boleh diurutkan, difilter dan dicari
+var buyerOrderListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"invoice_number":  {Column: "i.invoice_number", Type: listquery.Text, Sort: true, Filter: true},
+		"payment_status":  {Column: "i.payment_status", Type: listquery.Text, Sort: true, Filter: true},
+		"payment_method":  {Column: "i.payment_method", Type: listquery.Text, Filter: true},
+		"issued_date":     {Column: "i.issued_date", Type: listquery.Time, Sort: true, Filter: true},
+		"due_date":        {Column: "i.due_date", Type: listquery.Time, Sort: true, Filter: true},
+		"total_amount":    {Column: "i.total_amount", Type: listquery.Float, Sort: true, Filter: true},
+		"farm_id":         {Column: "i.farm_id", Type: listquery.Int, Filter: true},
+		"checkout_id":     {Column: "i.checkout_id", Type: listquery.Int, Filter: true},
+		"checkout_number": {Column: "c.checkout_number", Type: listquery.Text, Filter: true},
+	},
+	ID:     "i.id",
+	Search: []string{"i.invoice_number", "c.checkout_number"},
+}
+
+// GetAllOrdersByUserID menampilkan sub-invoice milik pembeli per halaman beserta produknya;
+// parameter mengikuti listquery
 func GetAllOrdersByUserID(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -581,7 +828,7 @@ func GetAllOrdersByUserID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mendapatkan ownerID dari nomor telepon
-	var ownerID int
+	var ownerID int64
 	query := `SELECT id_user FROM akun WHERE no_telp = $1`
 	err = sqlDB.QueryRow(query, payload.Id).Scan(&ownerID)
 	if err != nil {
@@ -590,154 +837,131 @@ func GetAllOrdersByUserID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query untuk mendapatkan data orders berdasarkan user_id
-	query = `
-		SELECT 
-			o.id AS order_id, 
-			o.invoice_id, 
-			o.product_id, 
-			o.quantity, 
-			o.total_harga, 
-			o.status, 
-			o.created_at, 
-			o.updated_at, 
-			i.invoice_number, 
-			i.total_amount, 
-			i.payment_method, 
-			i.issued_date, 
-			i.due_date,
-			i.proof_of_transfer
-		FROM 
-			orders o
-		JOIN 
-			invoice i ON o.invoice_id = i.id
-		WHERE 
-			i.user_id = $1
-	`
-
-	rows, err := sqlDB.Query(query, ownerID)
+	lq, err := listquery.Parse(r, buyerOrderListSpec)
 	if err != nil {
-		log.Println("[ERROR] Failed to fetch orders:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to fetch orders.",
+		listquery.WriteError(w, err)
+		return
+	}
+	lq.Where("i.user_id = ?", ownerID)
+
+	page, err := listquery.Run(sqlDB, lq,
+		`i.id, i.invoice_number, i.total_amount, i.payment_method, i.issued_date, i.due_date, i.proof_of_transfer,
+			i.farm_id, c.id, c.checkout_number`,
+		`invoice i LEFT JOIN checkout c ON i.checkout_id = c.id`,
+		func(rows *sql.Rows, key ...interface{}) (map[string]interface{}, error) {
+			var inv struct {
+				ID              int64
+				InvoiceNumber   string
+				TotalAmount     float64
+				PaymentMethod   string
+				IssuedDate      time.Time
+				DueDate         time.Time
+				ProofOfTransfer *string
+				FarmID          *int64
+				CheckoutID      *int64
+				CheckoutNumber  *string
+			}
+			err := rows.Scan(append([]interface{}{&inv.ID, &inv.InvoiceNumber, &inv.TotalAmount, &inv.PaymentMethod,
+				&inv.IssuedDate, &inv.DueDate, &inv.ProofOfTransfer, &inv.FarmID, &inv.CheckoutID, &inv.CheckoutNumber}, key...)...)
+			return map[string]interface{}{
+				"invoice_id":        inv.ID,
+				"invoice_number":    inv.InvoiceNumber,
+				"total_amount":      format.FormatCurrency(inv.TotalAmount) + "0",
+				"payment_method":    inv.PaymentMethod,
+				"issued_date":       inv.IssuedDate,
+				"due_date":          inv.DueDate,
+				"products":          []map[string]interface{}{},
+				"proof_of_transfer": inv.ProofOfTransfer,
+				"farm_id":           inv.FarmID,
+				"checkout_id":       inv.CheckoutID,
+				"checkout_number":   inv.CheckoutNumber,
+			}, err
 		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch orders:", err)
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	// Struktur data untuk menyimpan hasil
-	invoices := make(map[int64]map[string]interface{})
-
-	// Iterasi hasil query
-	for rows.Next() {
-		var order struct {
-			OrderID         int64
-			InvoiceID       int64
-			ProductID       int64
-			Quantity        int
-			TotalHarga      float64
-			Status          string
-			CreatedAt       time.Time
-			UpdatedAt       time.Time
-			InvoiceNumber   string
-			TotalAmount     float64
-			PaymentMethod   string
-			IssuedDate      time.Time
-			DueDate         time.Time
-			ProofOfTransfer *string
-		}
-
-		err := rows.Scan(
-			&order.OrderID,
-			&order.InvoiceID,
-			&order.ProductID,
-			&order.Quantity,
-			&order.TotalHarga,
-			&order.Status,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-			&order.InvoiceNumber,
-			&order.TotalAmount,
-			&order.PaymentMethod,
-			&order.IssuedDate,
-			&order.DueDate,
-			&order.ProofOfTransfer,
-		)
-		if err != nil {
-			log.Println("[ERROR] Failed to scan order row:", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "Database error",
-				"message": "Failed to process orders.",
-			})
-			return
-		}
-		// if order.ProofOfTransfer != nil && strings.Contains(*order.ProofOfTransfer, "https://github.com/") {
-		// 	rawBaseURL := "https://raw.githubusercontent.com"
-		// 	repoPath := "Ayala-crea/productImages/refs/heads/"
-		// 	imagePath := strings.TrimPrefix(*order.ProofOfTransfer, "https://github.com/Ayala-crea/productImages/blob/")
-		// 	formattedURL := fmt.Sprintf("%s/%s%s", rawBaseURL, repoPath, imagePath)
-		// 	order.ProofOfTransfer = &formattedURL
-		// }
-
-		var product model.Products
-		queryProduct := `SELECT name, price_per_kg FROM farm_products WHERE id = $1`
-		err = sqlDB.QueryRow(queryProduct, order.ProductID).Scan(&product.ProductName, &product.PricePerKg)
+	// Produk hanya dimuat untuk invoice pada halaman ini
+	byInvoice := make(map[int64]map[string]interface{}, len(page.Items))
+	ids := make([]string, 0, len(page.Items))
+	for _, inv := range page.Items {
+		id := inv["invoice_id"].(int64)
+		byInvoice[id] = inv
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	if len(ids) > 0 {
+		rows, err := sqlDB.Query(`SELECT o.id, o.invoice_id, o.product_id, o.product_name, o.unit_price, o.unit, o.image_url, o.quantity, o.total_harga, o.status, o.created_at, o.updated_at
+			FROM orders o
+			WHERE o.invoice_id = ANY(string_to_array($1, ',')::bigint[])
+			ORDER BY o.id`, strings.Join(ids, ","))
 		if err != nil {
-			log.Println("[ERROR] Failed to retrieve product details:", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "Database error",
-				"message": "Failed to retrieve product details.",
-			})
+			log.Println("[ERROR] Failed to fetch orders:", err)
+			listquery.WriteError(w, err)
 			return
 		}
-
-		// Organisasi data berdasarkan invoice
-		if _, exists := invoices[order.InvoiceID]; !exists {
-			invoices[order.InvoiceID] = map[string]interface{}{
-				"invoice_id":        order.InvoiceID,
-				"invoice_number":    order.InvoiceNumber,
-				"total_amount":      format.FormatCurrency(order.TotalAmount) + "0",
-				"payment_method":    order.PaymentMethod,
-				"issued_date":       order.IssuedDate,
-				"due_date":          order.DueDate,
-				"products":          []map[string]interface{}{},
-				"proof_of_transfer": order.ProofOfTransfer,
+		defer rows.Close()
+
+		for rows.Next() {
+			var order struct {
+				OrderID     int64
+				InvoiceID   int64
+				ProductID   *int64
+				ProductName string
+				UnitPrice   float64
+				Unit        string
+				ImageURL    string
+				Quantity    qty.Qty
+				TotalHarga  float64
+				Status      string
+				CreatedAt   time.Time
+				UpdatedAt   time.Time
+			}
+			if err := rows.Scan(&order.OrderID, &order.InvoiceID, &order.ProductID, &order.ProductName, &order.UnitPrice, &order.Unit,
+				&order.ImageURL, &order.Quantity, &order.TotalHarga, &order.Status, &order.CreatedAt, &order.UpdatedAt); err != nil {
+				log.Println("[ERROR] Failed to scan order row:", err)
+				listquery.WriteError(w, err)
+				return
 			}
-		}
 
-		productDetails := map[string]interface{}{
-			"order_id":     order.OrderID,
-			"product_id":   order.ProductID,
-			"product_name": product.ProductName,
-			"price_per_kg": product.PricePerKg,
-			"quantity":     order.Quantity,
-			"total_harga":  format.FormatCurrency(order.TotalHarga) + "0",
-			"status":       order.Status,
-			"created_at":   order.CreatedAt,
-			"updated_at":   order.UpdatedAt,
+			product := map[string]interface{}{
+				"order_id":     order.OrderID,
+				"product_id":   order.ProductID,
+				"product_name": order.ProductName,
+				"price_per_kg": order.UnitPrice,
+				"unit":         order.Unit,
+				"image_url":    order.ImageURL,
+				"quantity":     order.Quantity,
+				"total_harga":  format.FormatCurrency(order.TotalHarga) + "0",
+				"status":       order.Status,
+				"created_at":   order.CreatedAt,
+				"updated_at":   order.UpdatedAt,
+			}
+			inv := byInvoice[order.InvoiceID]
+			inv["products"] = append(inv["products"].([]map[string]interface{}), product)
 		}
-		invoices[order.InvoiceID]["products"] = append(invoices[order.InvoiceID]["products"].([]map[string]interface{}), productDetails)
-
 	}
 
-	// Konversi map ke array untuk response JSON
-	var result []map[string]interface{}
-	for _, invoice := range invoices {
-		result = append(result, invoice)
-	}
+	listquery.Write(w, "Orders retrieved successfully.", page)
+}
 
-	// Kirimkan response JSON
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "success",
-		"message": "Orders retrieved successfully.",
-		"data":    result,
+// writeProofError menulis kesalahan unggah bukti transfer; checkoutError membawa status HTTP-nya sendiri
+func writeProofError(w http.ResponseWriter, err error, message string) {
+	if ce, ok := err.(*checkoutError); ok {
+		w.WriteHeader(ce.Status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   http.StatusText(ce.Status),
+			"message": ce.Message,
+		})
+		return
+	}
+	log.Println("[ERROR] Failed to record proof of transfer:", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   "Database error",
+		"message": message,
 	})
-	log.Println("Proses pengambilan semua order berdasarkan user ID selesai.")
 }
 
 func BuktiTransfer(w http.ResponseWriter, r *http.Request) {
@@ -753,7 +977,7 @@ func BuktiTransfer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Decode JWT
-	_, err = watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -762,13 +986,32 @@ func BuktiTransfer(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	uploader, err := actorFor(sqlDB, payload.Id)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Account not found. Please log in again.",
+		})
+		return
+	}
+	if uploader.Type != identity.TypeAkun {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only the buyer can upload a proof of transfer.",
+		})
+		return
+	}
 
+	// Pembeli cukup mengirim satu bukti transfer untuk checkout; id_invoice tetap didukung untuk invoice lama
 	idInvoice := r.URL.Query().Get("id_invoice")
-	if idInvoice == "" {
+	idCheckout := r.URL.Query().Get("id_checkout")
+	if idInvoice == "" && idCheckout == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Bad Request",
-			"message": "Invalid or missing invoice ID.",
+			"message": "Invalid or missing invoice or checkout ID.",
 		})
 		return
 	}
@@ -856,15 +1099,113 @@ func BuktiTransfer(w http.ResponseWriter, r *http.Request) {
 
 	payment_status := "Sending"
 
-	queryUpdate := `UPDATE invoice SET proof_of_transfer = $1, payment_status = $2 WHERE id = $3`
-	_, err = sqlDB.Exec(queryUpdate, imageURL, payment_status, idInvoice)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to update invoice.",
-		})
-		return
+	if idCheckout != "" {
+		tx, err := sqlDB.Begin()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Database error",
+				"message": "Failed to start transaction.",
+			})
+			return
+		}
+		defer tx.Rollback()
+
+		// Checkout harus milik pembeli yang mengunggah
+		var checkoutID int64
+		err = tx.QueryRow(`SELECT id FROM checkout WHERE id = $1 AND user_id = $2 FOR UPDATE`, idCheckout, uploader.ID).Scan(&checkoutID)
+		if err == sql.ErrNoRows {
+			err = &checkoutError{http.StatusNotFound, "Checkout not found."}
+		}
+		var invoiceIDs []int64
+		previous := map[int64]string{}
+		if err == nil {
+			// Invoice yang sudah dibatalkan, kedaluwarsa atau lunas tidak ikut diperbarui
+			queryInvoices := `UPDATE invoice i SET proof_of_transfer = $1, payment_status = $2
+				FROM (SELECT id, payment_status FROM invoice
+					WHERE checkout_id = $3 AND payment_status NOT IN ('Cancelled', 'Expired', 'Paid') FOR UPDATE) old
+				WHERE i.id = old.id RETURNING i.id, old.payment_status`
+			var rows *sql.Rows
+			rows, err = tx.Query(queryInvoices, imageURL, payment_status, checkoutID)
+			if err == nil {
+				for rows.Next() {
+					var id int64
+					var oldStatus string
+					if err = rows.Scan(&id, &oldStatus); err != nil {
+						break
+					}
+					invoiceIDs = append(invoiceIDs, id)
+					previous[id] = oldStatus
+				}
+				rows.Close()
+			}
+		}
+		// Checkout yang seluruh invoicenya sudah ditutup tidak menerima bukti transfer baru
+		if err == nil && len(invoiceIDs) == 0 {
+			err = &checkoutError{http.StatusConflict, "Checkout has no unpaid invoice."}
+		}
+		if err == nil {
+			queryCheckout := `UPDATE checkout SET proof_of_transfer = $1, payment_status = $2, updated_at = NOW() WHERE id = $3`
+			_, err = tx.Exec(queryCheckout, imageURL, payment_status, checkoutID)
+		}
+		for _, id := range invoiceIDs {
+			if err != nil {
+				break
+			}
+			if err = recordStatusChange(tx, id, entityInvoice, id, previous[id], payment_status, uploader, ""); err == nil {
+				err = publishInvoiceEvent(tx, events.PaymentSubmitted, id, "")
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			writeProofError(w, err, "Failed to update checkout.")
+			return
+		}
+	} else {
+		tx, err := sqlDB.Begin()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Database error",
+				"message": "Failed to start transaction.",
+			})
+			return
+		}
+		defer tx.Rollback()
+
+		var invoiceID int64
+		var oldStatus string
+		// Invoice yang sudah dibatalkan, kedaluwarsa atau lunas tidak boleh kembali ke Sending
+		queryUpdate := `UPDATE invoice i SET proof_of_transfer = $1, payment_status = $2
+			FROM (SELECT id, payment_status FROM invoice
+				WHERE id = $3 AND user_id = $4 AND payment_status NOT IN ('Cancelled', 'Expired', 'Paid') FOR UPDATE) old
+			WHERE i.id = old.id RETURNING i.id, old.payment_status`
+		err = tx.QueryRow(queryUpdate, imageURL, payment_status, idInvoice, uploader.ID).Scan(&invoiceID, &oldStatus)
+		if err == sql.ErrNoRows {
+			var status string
+			err = tx.QueryRow(`SELECT payment_status FROM invoice WHERE id = $1 AND user_id = $2`, idInvoice, uploader.ID).Scan(&status)
+			switch {
+			case err == sql.ErrNoRows:
+				err = &checkoutError{http.StatusNotFound, "Invoice not found."}
+			case err == nil:
+				err = &checkoutError{http.StatusConflict, "Invoice is already " + status + "."}
+			}
+		}
+		if err == nil {
+			err = recordStatusChange(tx, invoiceID, entityInvoice, invoiceID, oldStatus, payment_status, uploader, "")
+		}
+		if err == nil {
+			err = publishInvoiceEvent(tx, events.PaymentSubmitted, invoiceID, "")
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			writeProofError(w, err, "Failed to update invoice.")
+			return
+		}
 	}
 
 	response := map[string]interface{}{
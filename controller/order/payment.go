@@ -0,0 +1,144 @@
+package order
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/watoken"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// VerifyPayment dipakai peternak untuk menyetujui atau menolak bukti transfer sebuah invoice.
+// Invoice yang disetujui menjadi Paid; yang ditolak kembali Pending agar pembeli dapat mengirim ulang.
+func VerifyPayment(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		log.Println("Unauthorized: failed to decode token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		InvoiceID int64  `json:"invoice_id"`
+		Approve   bool   `json:"approve"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.InvoiceID == 0 {
+		http.Error(w, "invoice_id is required", http.StatusBadRequest)
+		return
+	}
+	if !req.Approve && req.Reason == "" {
+		http.Error(w, "reason is required when rejecting a payment", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Hanya anggota peternakan penerbit invoice dengan hak verifikasi yang boleh memverifikasi pembayaran
+	var paymentStatus string
+	var checkoutID, farmID sql.NullInt64
+	query := `SELECT i.payment_status, i.checkout_id, i.farm_id FROM invoice i WHERE i.id = $1 FOR UPDATE`
+	err = tx.QueryRow(query, req.InvoiceID).Scan(&paymentStatus, &checkoutID, &farmID)
+	if err == nil && farmID.Valid {
+		_, err = farmaccess.CheckPhone(tx, payload.Id, farmID.Int64, farmaccess.VerifyPayments)
+	}
+	if err == sql.ErrNoRows || err == farmaccess.ErrNoFarm || (err == nil && !farmID.Valid) {
+		http.Error(w, "Invoice not found", http.StatusNotFound)
+		return
+	}
+	if err == farmaccess.ErrForbidden {
+		farmaccess.WriteError(w, err)
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch invoice:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if paymentStatus != "Pending" && paymentStatus != "Sending" {
+		http.Error(w, "Invoice is already "+paymentStatus, http.StatusConflict)
+		return
+	}
+
+	verifier, err := actorFor(sqlDB, payload.Id)
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve verifier:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	newStatus := "Paid"
+	if !req.Approve {
+		newStatus = "Pending"
+	}
+	if err := recordStatusChange(tx, req.InvoiceID, entityInvoice, req.InvoiceID, paymentStatus, newStatus, verifier, req.Reason); err != nil {
+		log.Println("[ERROR] Failed to record payment history:", err)
+		http.Error(w, "Failed to verify payment", http.StatusInternalServerError)
+		return
+	}
+
+	eventType := events.PaymentApproved
+	if req.Approve {
+		query = `UPDATE invoice SET payment_status = 'Paid', paid_at = NOW(), updated_at = NOW() WHERE id = $1`
+		_, err = tx.Exec(query, req.InvoiceID)
+	} else {
+		eventType = events.PaymentRejected
+		query = `UPDATE invoice SET payment_status = 'Pending', updated_at = NOW() WHERE id = $1`
+		_, err = tx.Exec(query, req.InvoiceID)
+	}
+	if err == nil && checkoutID.Valid {
+		// Checkout lunas jika semua invoice yang masih berlaku sudah Paid
+		query = `UPDATE checkout SET payment_status = CASE
+				WHEN NOT EXISTS (
+					SELECT 1 FROM invoice WHERE checkout_id = $1 AND payment_status NOT IN ('Paid', 'Cancelled', 'Expired')
+				) THEN 'Paid'
+				WHEN $2 THEN payment_status
+				ELSE 'Pending' END,
+			updated_at = NOW()
+			WHERE id = $1`
+		_, err = tx.Exec(query, checkoutID.Int64, req.Approve)
+	}
+	if err == nil {
+		err = publishInvoiceEvent(tx, eventType, req.InvoiceID, req.Reason)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to verify payment:", err)
+		http.Error(w, "Failed to verify payment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Payment verification saved",
+		"data": map[string]interface{}{
+			"invoice_id":     req.InvoiceID,
+			"payment_status": newStatus,
+		},
+	})
+}
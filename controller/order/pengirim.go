@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/helper/watoken"
 	"farmdistribution_be/model"
 	"fmt"
@@ -38,17 +41,12 @@ func CreatePengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var farmId int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ManageCouriers)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given owner ID.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmId := int(membership.FarmID)
 
 	var pengirim model.Pengirim
 	if err := json.NewDecoder(r.Body).Decode(&pengirim); err != nil {
@@ -120,17 +118,12 @@ func GetAllPengirimByFarmID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var farmId int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ViewFarm)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given owner ID.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmId := int(membership.FarmID)
 	query = `SELECT id, email, phone, name, address, vehicle_plate, vehicle_type, vehicle_color FROM pengirim WHERE farm_id = $1`
 	rows, err := sqlDB.Query(query, farmId)
 	if err != nil {
@@ -168,6 +161,21 @@ func GetAllPengirimByFarmID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// pengirimListSpec adalah field /pengirim/all yang boleh diurutkan, difilter dan dicari
+var pengirimListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name":         {Column: "name", Type: listquery.Text, Sort: true, Filter: true},
+		"email":        {Column: "email", Type: listquery.Text, Filter: true},
+		"phone":        {Column: "phone", Type: listquery.Text, Filter: true},
+		"vehicle_type": {Column: "vehicle_type", Type: listquery.Text, Sort: true, Filter: true},
+		"farm_id":      {Column: "farm_id", Type: listquery.Int, Filter: true},
+	},
+	ID:     "id",
+	Search: []string{"name", "email", "phone", "vehicle_plate"},
+}
+
+// GetAllPengirim menampilkan kurir seluruh peternakan per halaman (khusus admin);
+// parameter mengikuti listquery
 func GetAllPengirim(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -175,41 +183,30 @@ func GetAllPengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, email, phone, name, address, vehicle_plate, vehicle_type, vehicle_color FROM pengirim`
-	rows, err := sqlDB.Query(query)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !requireAdmin(w, r, sqlDB) {
 		return
 	}
-	defer rows.Close()
 
-	var pengirims []model.Pengirim
-
-	for rows.Next() {
-		var pengirim model.Pengirim
-		if err := rows.Scan(&pengirim.ID, &pengirim.Email, &pengirim.NoTelp, &pengirim.Nama, &pengirim.Alamat, &pengirim.PlatKendaraan, &pengirim.TypeKendaraan, &pengirim.WarnaKendaraan); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		pengirims = append(pengirims, pengirim)
+	lq, err := listquery.Parse(r, pengirimListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
 	}
 
-	if len(pengirims) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "No Pengirim found",
-			"message": "No pengirim found in the database.",
+	page, err := listquery.Run(sqlDB, lq,
+		`id, email, phone, name, address, vehicle_plate, vehicle_type, vehicle_color, COALESCE(farm_id, 0)`, `pengirim`,
+		func(rows *sql.Rows, key ...interface{}) (model.Pengirim, error) {
+			var p model.Pengirim
+			err := rows.Scan(append([]interface{}{&p.ID, &p.Email, &p.NoTelp, &p.Nama, &p.Alamat, &p.PlatKendaraan, &p.TypeKendaraan, &p.WarnaKendaraan, &p.FarmId}, key...)...)
+			return p, err
 		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch pengirim:", err)
+		listquery.WriteError(w, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message":   "Pengirim retrieved successfully",
-		"pengirims": pengirims,
-	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Pengirim retrieved successfully", page)
 }
 
 func GetPengirimByID(w http.ResponseWriter, r *http.Request) {
@@ -230,6 +227,10 @@ func GetPengirimByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !pengirimAccess(w, r, sqlDB, pengirimID, farmaccess.ViewFarm) {
+		return
+	}
+
 	query := `SELECT id, email, phone, name, address, vehicle_plate, vehicle_type, vehicle_color FROM pengirim WHERE id = $1`
 	var pengirim model.Pengirim
 	err = sqlDB.QueryRow(query, pengirimID).Scan(&pengirim.ID, &pengirim.Email, &pengirim.NoTelp, &pengirim.Nama, &pengirim.Alamat, &pengirim.PlatKendaraan, &pengirim.TypeKendaraan, &pengirim.WarnaKendaraan)
@@ -273,6 +274,10 @@ func DeletePengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !pengirimAccess(w, r, sqlDB, pengirimID, farmaccess.ManageCouriers) {
+		return
+	}
+
 	query := `DELETE FROM pengirim WHERE id = $1`
 	_, err = sqlDB.Exec(query, pengirimID)
 	if err != nil {
@@ -304,6 +309,10 @@ func UpdatePengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !pengirimAccess(w, r, sqlDB, pengirimID, farmaccess.ManageCouriers) {
+		return
+	}
+
 	var pengirim model.Pengirim
 	if err := json.NewDecoder(r.Body).Decode(&pengirim); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -326,3 +335,62 @@ func UpdatePengirim(w http.ResponseWriter, r *http.Request) {
 		"message": "Pengirim updated successfully",
 	})
 }
+
+// pengirimAccess memastikan pemanggil memiliki Permission p pada peternakan pemilik kurir pengirimID
+func pengirimAccess(w http.ResponseWriter, r *http.Request, db *sql.DB, pengirimID int, p farmaccess.Permission) bool {
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+
+	var farmID sql.NullInt64
+	err = db.QueryRow(`SELECT farm_id FROM pengirim WHERE id = $1`, pengirimID).Scan(&farmID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Pengirim not found",
+			"message": "No pengirim found for the given ID.",
+		})
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	// Kurir tanpa peternakan (farm_id NULL) tidak dapat dikelola anggota peternakan mana pun
+	if _, err := farmaccess.CheckPhone(db, payload.Id, farmID.Int64, p); err != nil {
+		farmaccess.WriteError(w, err)
+		return false
+	}
+	return true
+}
+
+// requireAdmin memastikan pemanggil adalah akun administrator
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB) bool {
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return false
+	}
+	isAdmin, err := identity.IsAdmin(db, payload.Id)
+	if err != nil {
+		log.Println("[ERROR] Failed to check admin role:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !isAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only administrators can access this resource.",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,343 @@
+package order
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/qty"
+	"farmdistribution_be/model"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// queryer dipenuhi oleh *sql.DB maupun *sql.Tx sehingga perhitungan harga
+// yang sama bisa dipakai untuk quote (tanpa transaksi) dan CreateOrder
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// checkoutError membawa status HTTP untuk kesalahan validasi keranjang
+type checkoutError struct {
+	Status  int
+	Message string
+}
+
+func (e *checkoutError) Error() string {
+	return e.Message
+}
+
+func writeCheckoutError(w http.ResponseWriter, err error, fallback string) {
+	if ce, ok := err.(*checkoutError); ok {
+		http.Error(w, ce.Message, ce.Status)
+		return
+	}
+	log.Println("[ERROR] "+fallback+":", err)
+	http.Error(w, fallback, http.StatusInternalServerError)
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// priceCart menghitung total per produk, ongkir per peternakan, diskon, pajak dan grand total.
+// Fungsi ini adalah satu-satunya sumber harga untuk quote maupun order.
+func priceCart(db queryer, req model.CheckoutRequest) (model.Quote, error) {
+	var quote model.Quote
+
+	if len(req.Products) == 0 || req.PengirimanID == 0 {
+		return quote, &checkoutError{http.StatusBadRequest, "Products and Pengiriman ID are required"}
+	}
+	// Ongkir selalu dihitung dari lokasi penerima, bukan dari jarak yang dikirim klien
+	if len(req.LocationPenerima) != 2 {
+		return quote, &checkoutError{http.StatusBadRequest, "location_penerima must be [lon, lat]"}
+	}
+
+	var fuelConsumption, fuelPrice float64
+	queryPengiriman := `SELECT fuel_consumption, fuel_price FROM pengiriman WHERE id = $1`
+	err := db.QueryRow(queryPengiriman, req.PengirimanID).Scan(&fuelConsumption, &fuelPrice)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return quote, &checkoutError{http.StatusBadRequest, "Invalid Pengiriman ID"}
+		}
+		return quote, err
+	}
+
+	// Gabungkan produk yang sama agar stok dicek terhadap jumlah total
+	quantities := make(map[int]qty.Qty)
+	var productIDs []int
+	for _, p := range req.Products {
+		if p.Quantity <= 0 {
+			return quote, &checkoutError{http.StatusBadRequest, "Quantity must be greater than zero"}
+		}
+		if _, exists := quantities[p.ProductID]; !exists {
+			productIDs = append(productIDs, p.ProductID)
+		}
+		quantities[p.ProductID] += p.Quantity
+	}
+
+	farmIndex := make(map[int64]int)
+	for _, productID := range productIDs {
+		quantity := quantities[productID]
+
+		var line model.QuoteLine
+		var stockKg float64
+		var farmName string
+		var available bool
+		var stockPerUnit, minQty, stepQty qty.Qty
+		var tolerance float64
+		// Harga per satuan jual: price_per_unit bila diisi, selain itu diturunkan dari price_per_kg
+		queryProduct := `SELECT fp.name, COALESCE(fp.image_url, ''),
+				COALESCE(fp.price_per_unit, ROUND(fp.price_per_kg * fp.stock_per_unit, 2)),
+				fp.stock_kg, fp.farm_id, f.name,
+				(sp.available_date IS NULL OR sp.available_date <= NOW()),
+				fp.sale_unit, fp.stock_per_unit, fp.min_qty, fp.step_qty,
+				fp.catch_weight, fp.weight_tolerance_percent
+			FROM farm_products fp
+			JOIN farms f ON f.id = fp.farm_id
+			LEFT JOIN status_product sp ON sp.id = fp.status_id
+			WHERE fp.id = $1`
+		err = db.QueryRow(queryProduct, productID).Scan(&line.ProductName, &line.ImageURL, &line.UnitPrice, &stockKg, &line.FarmID, &farmName, &available,
+			&line.Unit, &stockPerUnit, &minQty, &stepQty, &line.CatchWeight, &tolerance)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return quote, &checkoutError{http.StatusBadRequest, "Product not found"}
+			}
+			return quote, err
+		}
+
+		if !available {
+			return quote, &checkoutError{http.StatusBadRequest, "Product " + line.ProductName + " is not available yet"}
+		}
+
+		if err := quantity.Validate(minQty, stepQty); err != nil {
+			return quote, &checkoutError{http.StatusBadRequest, "Product " + line.ProductName + ": " + err.Error() + " " + line.Unit}
+		}
+
+		stockQty := quantity.Mul(stockPerUnit)
+		if stockKg < stockQty.Float64() {
+			log.Println("Stock tidak mencukupi untuk produk:", productID)
+			return quote, &checkoutError{http.StatusBadRequest, "Stock is insufficient"}
+		}
+
+		line.ProductID = int64(productID)
+		line.Quantity = quantity
+		line.StockQty = stockQty
+		line.LineTotal = quantity.Amount(line.UnitPrice)
+		if line.CatchWeight {
+			line.PricePerKg = roundMoney(line.UnitPrice / stockPerUnit.Float64())
+			line.WeightTolerance = tolerance
+		}
+		quote.Lines = append(quote.Lines, line)
+
+		idx, exists := farmIndex[line.FarmID]
+		if !exists {
+			idx = len(quote.Farms)
+			farmIndex[line.FarmID] = idx
+			quote.Farms = append(quote.Farms, model.QuoteFarm{FarmID: line.FarmID, FarmName: farmName})
+		}
+		quote.Farms[idx].Subtotal = roundMoney(quote.Farms[idx].Subtotal + line.LineTotal)
+	}
+
+	for i := range quote.Farms {
+		distance, err := farmDistance(db, req.LocationPenerima, quote.Farms[i])
+		if err != nil {
+			return quote, err
+		}
+		quote.Farms[i].DistanceKM = distance
+		quote.Farms[i].ShippingCost = roundMoney(distance * fuelConsumption * fuelPrice)
+	}
+
+	if req.VoucherCode != "" {
+		if err := applyVoucher(db, &quote, req.VoucherCode); err != nil {
+			return quote, err
+		}
+	}
+
+	quote.TaxRate = config.TaxRate
+	for i := range quote.Farms {
+		f := &quote.Farms[i]
+		f.Tax = roundMoney((f.Subtotal - f.Discount) * quote.TaxRate)
+		f.Total = roundMoney(f.Subtotal - f.Discount + f.Tax + f.ShippingCost)
+
+		quote.Subtotal = roundMoney(quote.Subtotal + f.Subtotal)
+		quote.ShippingTotal = roundMoney(quote.ShippingTotal + f.ShippingCost)
+		quote.DiscountTotal = roundMoney(quote.DiscountTotal + f.Discount)
+		quote.TaxTotal = roundMoney(quote.TaxTotal + f.Tax)
+		quote.GrandTotal = roundMoney(quote.GrandTotal + f.Total)
+	}
+
+	return quote, nil
+}
+
+// farmDistance menghitung jarak (km) dari lokasi peternakan ke lokasi penerima [lon, lat]
+func farmDistance(db queryer, location []float64, farm model.QuoteFarm) (float64, error) {
+	var distance sql.NullFloat64
+	query := `SELECT ST_DistanceSphere(location, ST_SetSRID(ST_MakePoint($2, $3), 4326)) / 1000 FROM farms WHERE id = $1`
+	err := db.QueryRow(query, farm.FarmID, location[0], location[1]).Scan(&distance)
+	if err != nil {
+		return 0, err
+	}
+	if !distance.Valid {
+		return 0, &checkoutError{http.StatusBadRequest, "Farm " + farm.FarmName + " has no location set"}
+	}
+	return math.Round(distance.Float64*100) / 100, nil
+}
+
+// applyVoucher membagi diskon voucher ke peternakan yang memenuhi syarat secara proporsional
+func applyVoucher(db queryer, quote *model.Quote, code string) error {
+	var (
+		voucherFarmID   sql.NullInt64
+		discountPercent float64
+		discountAmount  float64
+		maxDiscount     sql.NullFloat64
+		minPurchase     float64
+	)
+	query := `SELECT farm_id, discount_percent, discount_amount, max_discount, min_purchase
+		FROM voucher
+		WHERE UPPER(code) = UPPER($1) AND is_active = TRUE
+		AND (valid_from IS NULL OR valid_from <= NOW())
+		AND (valid_until IS NULL OR valid_until >= NOW())`
+	err := db.QueryRow(query, strings.TrimSpace(code)).Scan(&voucherFarmID, &discountPercent, &discountAmount, &maxDiscount, &minPurchase)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &checkoutError{http.StatusBadRequest, "Voucher is invalid or expired"}
+		}
+		return err
+	}
+
+	var eligible []int
+	var eligibleSubtotal float64
+	for i, f := range quote.Farms {
+		if !voucherFarmID.Valid || voucherFarmID.Int64 == f.FarmID {
+			eligible = append(eligible, i)
+			eligibleSubtotal += f.Subtotal
+		}
+	}
+	if len(eligible) == 0 {
+		return &checkoutError{http.StatusBadRequest, "Voucher does not apply to products in this cart"}
+	}
+	if eligibleSubtotal < minPurchase {
+		return &checkoutError{http.StatusBadRequest, "Cart does not meet the voucher minimum purchase"}
+	}
+
+	discount := eligibleSubtotal*discountPercent/100 + discountAmount
+	if maxDiscount.Valid && discount > maxDiscount.Float64 {
+		discount = maxDiscount.Float64
+	}
+	if discount > eligibleSubtotal {
+		discount = eligibleSubtotal
+	}
+	discount = roundMoney(discount)
+
+	remaining := discount
+	for n, i := range eligible {
+		share := remaining
+		if n < len(eligible)-1 {
+			share = roundMoney(discount * quote.Farms[i].Subtotal / eligibleSubtotal)
+		}
+		quote.Farms[i].Discount = share
+		remaining = roundMoney(remaining - share)
+	}
+	quote.VoucherCode = strings.ToUpper(strings.TrimSpace(code))
+	return nil
+}
+
+func newQuoteID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "QT-" + hex.EncodeToString(b), nil
+}
+
+// saveQuote menyimpan quote beserta payload aslinya agar CreateOrder dapat menagih jumlah yang sama persis
+func saveQuote(db *sql.DB, userID int, req model.CheckoutRequest, quote *model.Quote) error {
+	id, err := newQuoteID()
+	if err != nil {
+		return err
+	}
+	quote.ID = id
+	quote.ExpiresAt = time.Now().Add(config.QuoteTTL)
+
+	req.QuoteID = ""
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	quoteJSON, err := json.Marshal(quote)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO checkout_quote (id, user_id, request, quote, grand_total, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, NOW())`
+	_, err = db.Exec(query, quote.ID, userID, reqJSON, quoteJSON, quote.GrandTotal, quote.ExpiresAt)
+	return err
+}
+
+// consumeQuote mengambil quote milik user yang belum kedaluwarsa dan menandainya terpakai di dalam transaksi order
+func consumeQuote(tx *sql.Tx, quoteID string, userID int) (model.CheckoutRequest, model.Quote, error) {
+	var req model.CheckoutRequest
+	var quote model.Quote
+	var reqJSON, quoteJSON []byte
+
+	query := `UPDATE checkout_quote SET used_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING request, quote`
+	err := tx.QueryRow(query, quoteID, userID).Scan(&reqJSON, &quoteJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return req, quote, &checkoutError{http.StatusBadRequest, "Quote not found, already used or expired"}
+		}
+		return req, quote, err
+	}
+
+	if err := json.Unmarshal(reqJSON, &req); err != nil {
+		return req, quote, err
+	}
+	if err := json.Unmarshal(quoteJSON, &quote); err != nil {
+		return req, quote, err
+	}
+	req.QuoteID = quoteID
+	return req, quote, nil
+}
+
+// loadCartProducts mengisi produk checkout dari keranjang tersimpan milik user
+func loadCartProducts(db queryer, userID int) ([]model.Product, error) {
+	rows, err := db.Query(`SELECT product_id, quantity FROM cart_item WHERE akun_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []model.Product
+	for rows.Next() {
+		var p model.Product
+		if err := rows.Scan(&p.ProductID, &p.Quantity); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, &checkoutError{http.StatusBadRequest, "Cart is empty"}
+	}
+	return products, nil
+}
+
+// clearOrderedCartItems menghapus produk yang sudah di-checkout dari keranjang
+func clearOrderedCartItems(tx *sql.Tx, userID int, lines []model.QuoteLine) error {
+	for _, line := range lines {
+		if _, err := tx.Exec(`DELETE FROM cart_item WHERE akun_id = $1 AND product_id = $2`, userID, line.ProductID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
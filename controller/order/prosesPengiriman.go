@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/farmaccess"
 	"farmdistribution_be/helper/ghupload"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/helper/watoken"
 	"farmdistribution_be/model"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -18,7 +19,31 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// Fungsi utama GetAllProsesPengiriman
+// prosesPengirimanListSpec adalah field /proses-pengiriman yang boleh diurutkan, difilter dan dicari
+var prosesPengirimanListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"status_pengiriman": {Column: "pp.status_pengiriman", Type: listquery.Text, Sort: true, Filter: true},
+		"tanggal_dikirim":   {Column: "pp.tanggal_dikirim", Type: listquery.Time, Sort: true, Filter: true},
+		"id_invoice":        {Column: "pp.id_invoice", Type: listquery.Int, Filter: true},
+	},
+	ID:     "pp.id",
+	Search: []string{"pp.alamat_pengirim", "pp.alamat_penerima", "i.invoice_number"},
+}
+
+// prosesPengirimanColumns dan scanProsesPengiriman dipakai semua daftar proses pengiriman
+const prosesPengirimanColumns = `pp.id, pp.hari_dikirim, pp.tanggal_dikirim, pp.tanggal_diterima, pp.hari_diterima, pp.id_pengirim,
+	pp.id_invoice, pp.status_pengiriman, pp.image_pengiriman, pp.alamat_pengirim, pp.alamat_penerima`
+
+func scanProsesPengiriman(rows *sql.Rows, key ...interface{}) (model.ProsesPengiriman, error) {
+	var pp model.ProsesPengiriman
+	err := rows.Scan(append([]interface{}{&pp.ID, &pp.HariDikirim, &pp.TanggalDikirim, &pp.TanggalDiterima,
+		&pp.HariDiterima, &pp.IdPengirim, &pp.IdInvoice, &pp.StatusPengiriman, &pp.ImagePengiriman,
+		&pp.AlamatPengirim, &pp.AlamatPenerima}, key...)...)
+	return pp, err
+}
+
+// GetAllProsesPengiriman menampilkan proses pengiriman invoice milik pembeli per halaman;
+// parameter mengikuti listquery
 func GetAllProsesPengiriman(w http.ResponseWriter, r *http.Request) {
 	// Get database connection
 	sqlDB, err := config.PostgresDB.DB()
@@ -45,92 +70,29 @@ func GetAllProsesPengiriman(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
-	log.Println("[INFO] Owner ID found:", ownerID)
 
-	// Ambil semua id_invoice berdasarkan user_id
-	query = `SELECT id FROM invoice WHERE user_id = $1`
-	rows, err := sqlDB.Query(query, ownerID)
+	lq, err := listquery.Parse(r, prosesPengirimanListSpec)
 	if err != nil {
-		log.Println("[WARNING] No invoice found for user_id:", ownerID, err)
-		http.Error(w, "No invoice found", http.StatusNotFound)
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	// Menampung semua ID invoice
-	var idInvoices []int64
-	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			log.Println("[ERROR] Failed to scan invoice ID:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		idInvoices = append(idInvoices, id)
-	}
-
-	if len(idInvoices) == 0 {
-		log.Println("[INFO] No invoices found for user.")
-		http.Error(w, "No invoices found", http.StatusNotFound)
-		return
-	}
-
-	// Mengubah idInvoices menjadi format string untuk query SQL (contoh: (1,2,3))
-	invoiceIDsStr := make([]string, len(idInvoices))
-	for i, id := range idInvoices {
-		invoiceIDsStr[i] = fmt.Sprintf("%d", id)
-	}
-	idInvoicesQuery := strings.Join(invoiceIDsStr, ",")
-
-	// Query untuk mengambil semua data proses pengiriman berdasarkan id_invoice
-	query = fmt.Sprintf(`SELECT id, hari_dikirim, tanggal_dikirim, tanggal_diterima, 
-                        hari_diterima, id_pengirim, status_pengiriman, 
-                        image_pengiriman, alamat_pengirim, alamat_penerima 
-                        FROM proses_pengiriman WHERE id_invoice IN (%s)`, idInvoicesQuery)
+	lq.Where("i.user_id = ?", ownerID)
 
-	rows, err = sqlDB.Query(query)
+	page, err := listquery.Run(sqlDB, lq, prosesPengirimanColumns,
+		`proses_pengiriman pp JOIN invoice i ON i.id = pp.id_invoice`, scanProsesPengiriman)
 	if err != nil {
 		log.Println("[ERROR] Failed to get proses pengiriman:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	// Menampung hasil query ke dalam slice struct
-	var prosesPengirimanList []model.ProsesPengiriman
-	for rows.Next() {
-		var pp model.ProsesPengiriman
-		if err := rows.Scan(&pp.ID, &pp.HariDikirim, &pp.TanggalDikirim, &pp.TanggalDiterima,
-			&pp.HariDiterima, &pp.IdPengirim, &pp.StatusPengiriman, &pp.ImagePengiriman,
-			&pp.AlamatPengirim, &pp.AlamatPenerima); err != nil {
-			log.Println("[ERROR] Failed to scan proses pengiriman:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		prosesPengirimanList = append(prosesPengirimanList, pp)
-	}
-
-	if len(prosesPengirimanList) == 0 {
-		log.Println("[INFO] No proses_pengiriman found for invoices.")
-		http.Error(w, "No proses_pengiriman found", http.StatusNotFound)
+		listquery.WriteError(w, err)
 		return
 	}
 
-	// Membuat response JSON
-	response := map[string]interface{}{
-		"status":  "success",
-		"message": "Proses pengiriman retrieved successfully",
-		"data":    prosesPengirimanList,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Proses pengiriman retrieved successfully", page)
 }
 
+// GetAllProsesPengirimanPeternak menampilkan proses pengiriman sub-invoice peternakan per halaman.
+// Difilter lewat invoice.farm_id agar order yang produknya sudah dihapus tetap tampil; parameter
+// mengikuti listquery.
 func GetAllProsesPengirimanPeternak(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Handling GetAllProsesPengirimanPeternak request")
-
 	// Get database connection
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -140,145 +102,50 @@ func GetAllProsesPengirimanPeternak(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Decode token to get user details
-	log.Println("[INFO] Decoding token to get user details")
 	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
 	if err != nil {
 		log.Println("[ERROR] Invalid or expired token:", err)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	log.Println("[INFO] Token decoded successfully for user:", payload.Id)
 
 	// Retrieve owner ID from akun table
 	var ownerID int64
 	query := `SELECT id_user FROM akun WHERE no_telp = $1`
-	log.Println("[INFO] Retrieving owner ID for user:", payload.Id)
 	err = sqlDB.QueryRow(query, payload.Id).Scan(&ownerID)
 	if err != nil {
 		log.Println("[ERROR] Failed to find owner ID for no_telp:", payload.Id, err)
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
-	log.Println("[INFO] Retrieved owner ID:", ownerID)
 
 	// Retrieve farm ID associated with owner
-	var farmId int
-	queryFarm := `SELECT id FROM farms WHERE owner_id = $1`
-	log.Println("[INFO] Retrieving farm ID for owner:", ownerID)
-	err = sqlDB.QueryRow(queryFarm, ownerID).Scan(&farmId)
-	if err != nil {
-		log.Println("[ERROR] No farm found for owner:", ownerID)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Not Found",
-			"message": "No farm found for the given owner.",
-		})
-		return
-	}
-	log.Println("[INFO] Retrieved farm ID:", farmId)
-
-	// Retrieve product IDs from farm_products table
-	queryProduct := `SELECT id FROM farm_products WHERE farm_id = $1`
-	log.Println("[INFO] Retrieving product IDs for farm:", farmId)
-	rows, err := sqlDB.Query(queryProduct, farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ViewFarm)
 	if err != nil {
-		log.Println("[ERROR] Failed to retrieve products for farm:", farmId, err)
-		http.Error(w, "No invoice found", http.StatusNotFound)
-		return
-	}
-	defer rows.Close()
-
-	var productIds []int64
-	for rows.Next() {
-		var id sql.NullInt64
-		if err := rows.Scan(&id); err != nil {
-			log.Println("[ERROR] Failed to scan product ID:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if id.Valid {
-			productIds = append(productIds, id.Int64)
-		}
-	}
-	log.Println("[INFO] Retrieved product IDs:", productIds)
-
-	if len(productIds) == 0 {
-		log.Println("[INFO] No products found for farm ID:", farmId)
-		http.Error(w, "No product found", http.StatusNotFound)
+		farmaccess.WriteError(w, err)
 		return
 	}
 
-	// Retrieve id_proses_pengiriman from orders table
-	log.Println("[INFO] Retrieving id_proses_pengiriman for products")
-	productIdStr := strings.Trim(strings.Join(strings.Fields(fmt.Sprint(productIds)), ","), "[]")
-	queryIdProsesPengiriman := fmt.Sprintf(`SELECT id_proses_pengiriman FROM orders WHERE product_id IN (%s)`, productIdStr)
-	rows, err = sqlDB.Query(queryIdProsesPengiriman)
+	lq, err := listquery.Parse(r, prosesPengirimanListSpec)
 	if err != nil {
-		log.Println("[ERROR] Failed to get proses pengiriman IDs:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var idProsesPengiriman []int64
-	for rows.Next() {
-		var id sql.NullInt64
-		if err := rows.Scan(&id); err != nil {
-			log.Println("[ERROR] Failed to scan proses pengiriman ID:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if id.Valid {
-			idProsesPengiriman = append(idProsesPengiriman, id.Int64)
-		}
-	}
-	log.Println("[INFO] Retrieved proses pengiriman IDs:", idProsesPengiriman)
-
-	if len(idProsesPengiriman) == 0 {
-		log.Println("[INFO] No proses_pengiriman found for products")
-		http.Error(w, "No proses_pengiriman found", http.StatusNotFound)
+		listquery.WriteError(w, err)
 		return
 	}
+	lq.Where("i.farm_id = ?", membership.FarmID)
 
-	// Retrieve proses pengiriman details
-	log.Println("[INFO] Retrieving proses pengiriman details")
-	idProsesPengirimanStr := strings.Trim(strings.Join(strings.Fields(fmt.Sprint(idProsesPengiriman)), ","), "[]")
-	queryProsesPengiriman := fmt.Sprintf(`SELECT id, hari_dikirim, tanggal_dikirim, tanggal_diterima, 
-		hari_diterima, id_pengirim, status_pengiriman, image_pengiriman, alamat_pengirim, alamat_penerima 
-		FROM proses_pengiriman WHERE id IN (%s)`, idProsesPengirimanStr)
-	rows, err = sqlDB.Query(queryProsesPengiriman)
+	page, err := listquery.Run(sqlDB, lq, prosesPengirimanColumns,
+		`proses_pengiriman pp JOIN invoice i ON i.id = pp.id_invoice`, scanProsesPengiriman)
 	if err != nil {
 		log.Println("[ERROR] Failed to get proses pengiriman details:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var prosesPengirimanList []model.ProsesPengiriman
-	for rows.Next() {
-		var pp model.ProsesPengiriman
-		if err := rows.Scan(&pp.ID, &pp.HariDikirim, &pp.TanggalDikirim, &pp.TanggalDiterima,
-			&pp.HariDiterima, &pp.IdPengirim, &pp.StatusPengiriman,
-			&pp.ImagePengiriman, &pp.AlamatPengirim, &pp.AlamatPenerima); err != nil {
-			log.Println("[ERROR] Failed to scan proses pengiriman record:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		prosesPengirimanList = append(prosesPengirimanList, pp)
-	}
-	log.Println("[INFO] Proses pengiriman details retrieved successfully")
 
-	response := map[string]interface{}{
-		"status":  "success",
-		"message": "Proses pengiriman retrieved successfully",
-		"data":    prosesPengirimanList,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Proses pengiriman retrieved successfully", page)
 }
 
+// GetAllProsesPengirimanPengirim menampilkan proses pengiriman yang ditugaskan ke pengirim per halaman;
+// parameter mengikuti listquery
 func GetAllProsesPengirimanPengirim(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -295,7 +162,7 @@ func GetAllProsesPengirimanPengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ambil id_user dari tabel akun berdasarkan no_telp
+	// Ambil id pengirim berdasarkan nomor telepon
 	var ownerID int64
 	query := `SELECT id FROM pengirim WHERE phone = $1`
 	err = sqlDB.QueryRow(query, payload.Id).Scan(&ownerID)
@@ -305,39 +172,22 @@ func GetAllProsesPengirimanPengirim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	queryProsesPengiriman := `SELECT id, hari_dikirim, tanggal_dikirim, tanggal_diterima, hari_diterima, status_pengiriman, image_pengiriman, alamat_pengirim, alamat_penerima FROM proses_pengiriman WHERE id_pengirim = $1`
-	rows, err := sqlDB.Query(queryProsesPengiriman, ownerID)
+	lq, err := listquery.Parse(r, prosesPengirimanListSpec)
 	if err != nil {
-		log.Println("[ERROR] Failed to get proses pengiriman:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
-	var prosesPengirimanList []model.ProsesPengiriman
-	for rows.Next() {
-		var pp model.ProsesPengiriman
-		if err := rows.Scan(&pp.ID, &pp.HariDikirim, &pp.TanggalDikirim, &pp.TanggalDiterima, &pp.HariDiterima, &pp.StatusPengiriman, &pp.ImagePengiriman, &pp.AlamatPengirim, &pp.AlamatPenerima); err != nil {
-			log.Println("[ERROR] Failed to scan proses pengiriman:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		prosesPengirimanList = append(prosesPengirimanList, pp)
-	}
-	if len(prosesPengirimanList) == 0 {
-		log.Println("[INFO] No proses_pengiriman found for invoices.")
-		http.Error(w, "No proses_pengiriman found", http.StatusNotFound)
+	lq.Where("pp.id_pengirim = ?", ownerID)
+
+	page, err := listquery.Run(sqlDB, lq, prosesPengirimanColumns,
+		`proses_pengiriman pp LEFT JOIN invoice i ON i.id = pp.id_invoice`, scanProsesPengiriman)
+	if err != nil {
+		log.Println("[ERROR] Failed to get proses pengiriman:", err)
+		listquery.WriteError(w, err)
 		return
 	}
-	// Membuat response JSON
-	response := map[string]interface{}{
-		"status":  "success",
-		"message": "Proses pengiriman retrieved successfully",
-		"data":    prosesPengirimanList,
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Proses pengiriman retrieved successfully", page)
 }
 
 // GetProsesPengirimanByID mengambil data proses pengiriman berdasarkan ID
@@ -415,12 +265,18 @@ func UpdateProsesPengiriman(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Decode token untuk autentikasi pengguna
-	_, err = watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
 	if err != nil {
 		log.Println("[ERROR] Invalid or expired token:", err)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	updater, err := actorFor(sqlDB, payload.Id)
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve caller:", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
 	// Ambil ID dari URL parameter
 	vars := mux.Vars(r)
@@ -455,6 +311,21 @@ func UpdateProsesPengiriman(w http.ResponseWriter, r *http.Request) {
 	statusPengiriman := r.FormValue("status_pengiriman")
 	alamatPengirim := r.FormValue("alamat_pengirim")
 	alamatPenerima := r.FormValue("alamat_penerima")
+	reason := r.FormValue("reason")
+
+	// Produk catch-weight harus ditimbang sebelum pesanan dikirim
+	if statusPengiriman != "" && statusPengiriman != "Pending" {
+		pending, err := hasUnweighedLines(sqlDB, id)
+		if err != nil {
+			log.Println("[ERROR] Failed to check catch-weight lines:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if pending {
+			http.Error(w, "Catch-weight items must be weighed before shipping", http.StatusConflict)
+			return
+		}
+	}
 
 	// Handle file upload
 	var prosespengirimanURL string
@@ -506,7 +377,29 @@ func UpdateProsesPengiriman(w http.ResponseWriter, r *http.Request) {
               alamat_pengirim = $8, alamat_penerima = $9
               WHERE id = $10`
 
-	_, err = sqlDB.Exec(query, hariDikirim, tanggalDikirim, tanggalDiterima,
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Status lama dibaca untuk menentukan apakah ada event pengiriman baru
+	var oldStatus string
+	var invoiceID sql.NullInt64
+	err = tx.QueryRow(`SELECT COALESCE(status_pengiriman, ''), id_invoice FROM proses_pengiriman WHERE id = $1 FOR UPDATE`, id).Scan(&oldStatus, &invoiceID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Proses pengiriman not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch proses pengiriman:", err)
+		http.Error(w, "Failed to update proses pengiriman", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(query, hariDikirim, tanggalDikirim, tanggalDiterima,
 		hariDiterima, idPengirim, statusPengiriman, prosespengirimanURL, alamatPengirim, alamatPenerima, id)
 
 	if err != nil {
@@ -515,6 +408,28 @@ func UpdateProsesPengiriman(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if invoiceID.Valid {
+		if err := recordStatusChange(tx, invoiceID.Int64, entityShipment, id, oldStatus, statusPengiriman, updater, reason); err != nil {
+			log.Println("[ERROR] Failed to record shipment history:", err)
+			http.Error(w, "Failed to update proses pengiriman", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if eventType := shipmentEvent(statusPengiriman); eventType != "" && eventType != shipmentEvent(oldStatus) && invoiceID.Valid {
+		if err := publishInvoiceEvent(tx, eventType, invoiceID.Int64, ""); err != nil {
+			log.Println("[ERROR] Failed to publish shipment event:", err)
+			http.Error(w, "Failed to update proses pengiriman", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("[ERROR] Failed to commit proses pengiriman:", err)
+		http.Error(w, "Failed to update proses pengiriman", http.StatusInternalServerError)
+		return
+	}
+
 	// Membuat response JSON
 	response := map[string]interface{}{
 		"status":  "success",
@@ -0,0 +1,153 @@
+package order
+
+import (
+	"database/sql"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/inventory"
+	"farmdistribution_be/helper/qty"
+	"net/http"
+	"strings"
+)
+
+// voidInvoice membatalkan invoice di dalam transaksi: stok dikembalikan, order dan pengiriman
+// ditandai Cancelled, dan total checkout dikurangi. Nomor invoice tetap tersimpan dan tidak dipakai ulang.
+// Hanya invoice Pending yang dapat dibatalkan; refund juga mengizinkan invoice Paid yang belum dikirim
+// (dipakai peternakan dengan hak CancelOrders yang mengembalikan pembayaran pembeli).
+func voidInvoice(tx *sql.Tx, invoiceID int64, a actor, reason string, refund bool) error {
+	from := []string{"Pending"}
+	if refund {
+		from = append(from, "Paid")
+	}
+	return closeInvoice(tx, invoiceID, "Cancelled", from, a, reason)
+}
+
+// closeInvoice menutup invoice berstatus salah satu from dengan status Cancelled atau Expired;
+// status lain menghasilkan 409. a dicatat sebagai pelaku di riwayat status.
+func closeInvoice(tx *sql.Tx, invoiceID int64, status string, from []string, a actor, reason string) error {
+	var paymentStatus string
+	var checkoutID sql.NullInt64
+	var totalAmount float64
+	query := `SELECT payment_status, checkout_id, total_amount FROM invoice WHERE id = $1 FOR UPDATE`
+	err := tx.QueryRow(query, invoiceID).Scan(&paymentStatus, &checkoutID, &totalAmount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &checkoutError{http.StatusNotFound, "Invoice not found"}
+		}
+		return err
+	}
+	if paymentStatus == "Cancelled" || paymentStatus == "Expired" {
+		return &checkoutError{http.StatusConflict, "Invoice is already " + paymentStatus}
+	}
+	closable := false
+	for _, s := range from {
+		closable = closable || paymentStatus == s
+	}
+	if !closable {
+		return &checkoutError{http.StatusConflict, "A " + paymentStatus + " invoice cannot be " + strings.ToLower(status)}
+	}
+
+	// Stok dikembalikan sebesar mutasi sale yang tercatat untuk baris order invoice ini
+	if err := returnStock(tx, invoiceID, status, a, reason); err != nil {
+		return err
+	}
+
+	if err := recordStatusChanges(tx, invoiceID, entityOrder, "Cancelled", a, reason); err != nil {
+		return err
+	}
+	if err := recordStatusChanges(tx, invoiceID, entityShipment, "Cancelled", a, reason); err != nil {
+		return err
+	}
+	if err := recordStatusChange(tx, invoiceID, entityInvoice, invoiceID, paymentStatus, status, a, reason); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE orders SET status = 'Cancelled', updated_at = NOW() WHERE invoice_id = $1`, invoiceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE proses_pengiriman SET status_pengiriman = 'Cancelled', updated_at = NOW() WHERE id_invoice = $1`, invoiceID); err != nil {
+		return err
+	}
+
+	query = `UPDATE invoice SET payment_status = $3, cancelled_at = NOW(), cancel_reason = $2, updated_at = NOW() WHERE id = $1`
+	if _, err := tx.Exec(query, invoiceID, reason, status); err != nil {
+		return err
+	}
+
+	eventType := events.InvoiceCancelled
+	if status == "Expired" {
+		eventType = events.InvoiceExpired
+	}
+	if err := publishInvoiceEvent(tx, eventType, invoiceID, reason); err != nil {
+		return err
+	}
+
+	if checkoutID.Valid {
+		query = `UPDATE checkout SET total_amount = total_amount - $2,
+			payment_status = CASE WHEN NOT EXISTS (
+				SELECT 1 FROM invoice WHERE checkout_id = $1 AND payment_status NOT IN ('Cancelled', 'Expired')
+			) THEN $3 ELSE payment_status END,
+			updated_at = NOW()
+			WHERE id = $1`
+		if _, err := tx.Exec(query, checkoutID.Int64, totalAmount, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returnStock mencatat mutasi cancellation_return untuk setiap produk pada invoice yang ditutup.
+// Jumlahnya adalah stok yang benar-benar dikurangi ledger (order dan koreksi timbang, termasuk yang
+// terpotong Clamp); baris order dari sebelum ledger memakai berat timbang atau estimasinya.
+func returnStock(tx *sql.Tx, invoiceID int64, status string, a actor, reason string) error {
+	rows, err := tx.Query(`SELECT o.product_id, SUM(COALESCE(
+			-(SELECT SUM(m.quantity_kg) FROM inventory_movements m
+				WHERE m.type = 'sale' AND m.reference_type = 'order' AND m.reference_id = o.id),
+			o.actual_weight_kg, o.estimated_weight_kg, o.quantity))
+		FROM orders o
+		WHERE o.invoice_id = $1 AND o.product_id IS NOT NULL AND o.status <> 'Cancelled'
+		GROUP BY o.product_id
+		ORDER BY o.product_id`, invoiceID)
+	if err != nil {
+		return err
+	}
+	type line struct {
+		productID int64
+		restoreKg qty.Qty
+	}
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.productID, &l.restoreKg); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	note := "Invoice " + strings.ToLower(status)
+	if reason != "" {
+		note += ": " + reason
+	}
+	for _, l := range lines {
+		if l.restoreKg <= 0 {
+			continue
+		}
+		_, err := inventory.Move(tx, inventory.Movement{
+			ProductID:  l.productID,
+			Type:       inventory.CancellationReturn,
+			QuantityKg: l.restoreKg,
+			Reason:     note,
+			ActorType:  a.Type,
+			ActorID:    a.ID,
+			RefType:    "invoice",
+			RefID:      invoiceID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
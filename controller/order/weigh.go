@@ -0,0 +1,305 @@
+package order
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/inventory"
+	"farmdistribution_be/helper/qty"
+	"farmdistribution_be/helper/watoken"
+	"farmdistribution_be/model"
+	"log"
+	"math"
+	"net/http"
+)
+
+// chargedWeight membatasi berat yang ditagihkan ke dalam toleransi dari estimasi,
+// selisih di luar toleransi ditanggung peternakan
+func chargedWeight(estimated, actual qty.Qty, tolerancePercent float64) qty.Qty {
+	tolerance := qty.Qty(math.Round(float64(estimated) * tolerancePercent / 100))
+	if actual > estimated+tolerance {
+		return estimated + tolerance
+	}
+	if actual < estimated-tolerance {
+		return estimated - tolerance
+	}
+	return actual
+}
+
+// WeighOrder mencatat berat aktual baris order catch-weight dan menghitung ulang tagihan
+func WeighOrder(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		log.Println("Unauthorized: failed to decode token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	membership, err := farmaccess.ForRequestPhone(sqlDB, r, payload.Id, farmaccess.FulfilOrders)
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve farm access:", err)
+		farmaccess.WriteError(w, err)
+		return
+	}
+	farmID := membership.FarmID
+
+	var req struct {
+		OrderID        int64   `json:"order_id"`
+		ActualWeightKg qty.Qty `json:"actual_weight_kg"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if req.OrderID == 0 || req.ActualWeightKg <= 0 {
+		http.Error(w, "order_id and a positive actual_weight_kg are required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		invoiceID     int64
+		checkoutID    sql.NullInt64
+		productID     sql.NullInt64
+		catchWeight   bool
+		estimated     qty.Qty
+		actual        sql.NullString
+		pricePerKg    float64
+		tolerance     float64
+		lineTotal     float64
+		paymentStatus string
+	)
+	query := `SELECT o.invoice_id, i.checkout_id, o.product_id, o.catch_weight, o.estimated_weight_kg, o.actual_weight_kg::TEXT,
+			o.price_per_kg, o.weight_tolerance_percent, o.total_harga, i.payment_status
+		FROM orders o
+		JOIN invoice i ON i.id = o.invoice_id
+		WHERE o.id = $1 AND i.farm_id = $2
+		FOR UPDATE OF o, i`
+	err = tx.QueryRow(query, req.OrderID, farmID).Scan(&invoiceID, &checkoutID, &productID, &catchWeight, &estimated, &actual,
+		&pricePerKg, &tolerance, &lineTotal, &paymentStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		log.Println("[ERROR] Failed to fetch order:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !catchWeight {
+		http.Error(w, "Order line is not a catch-weight product", http.StatusBadRequest)
+		return
+	}
+	// Stok invoice yang sudah ditutup sudah dikembalikan, jadi barisnya tidak boleh ditimbang lagi
+	if paymentStatus != "Pending" && paymentStatus != "Sending" && paymentStatus != "Paid" {
+		http.Error(w, "Invoice is already "+paymentStatus, http.StatusConflict)
+		return
+	}
+	if actual.Valid {
+		http.Error(w, "Order line has already been weighed", http.StatusConflict)
+		return
+	}
+
+	charged := chargedWeight(estimated, req.ActualWeightKg, tolerance)
+	newTotal := charged.Amount(pricePerKg)
+	diff := roundMoney(newTotal - lineTotal)
+	taxDiff := roundMoney(diff * config.TaxRate)
+
+	query = `UPDATE orders SET actual_weight_kg = $1, total_harga = $2, weighed_at = NOW(), updated_at = NOW() WHERE id = $3`
+	if _, err := tx.Exec(query, req.ActualWeightKg, newTotal, req.OrderID); err != nil {
+		log.Println("[ERROR] Failed to update order weight:", err)
+		http.Error(w, "Failed to update order", http.StatusInternalServerError)
+		return
+	}
+
+	// Stok disesuaikan dengan berat fisik yang benar-benar keluar
+	if productID.Valid && req.ActualWeightKg != estimated {
+		_, err := inventory.Move(tx, inventory.Movement{
+			ProductID:  productID.Int64,
+			Type:       inventory.Sale,
+			QuantityKg: estimated - req.ActualWeightKg,
+			Reason:     "Catch-weight correction: estimated " + estimated.String() + " kg, weighed " + req.ActualWeightKg.String() + " kg",
+			ActorType:  identity.TypeAkun,
+			ActorID:    membership.AkunID,
+			RefType:    "order",
+			RefID:      req.OrderID,
+			Clamp:      true,
+		})
+		if err != nil {
+			log.Println("[ERROR] Failed to adjust stock:", err)
+			http.Error(w, "Failed to update stock", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	adjustment := model.InvoiceAdjustment{
+		InvoiceID:         invoiceID,
+		OrderID:           req.OrderID,
+		EstimatedWeightKg: estimated,
+		ActualWeightKg:    req.ActualWeightKg,
+		ChargedWeightKg:   charged,
+		Amount:            diff,
+		TaxAmount:         taxDiff,
+	}
+	if diff != 0 {
+		adjustment.Type = "charge"
+		if diff < 0 {
+			adjustment.Type = "credit"
+		}
+
+		// Invoice yang belum dibayar langsung dihitung ulang, selain itu selisih diselesaikan terpisah
+		adjustment.Status = "Pending"
+		if paymentStatus == "Pending" {
+			adjustment.Status = "Applied"
+			query = `UPDATE invoice SET total_harga_product = total_harga_product + $1, tax_amount = COALESCE(tax_amount, 0) + $2,
+				total_amount = total_amount + $1 + $2, updated_at = NOW() WHERE id = $3`
+			if _, err := tx.Exec(query, diff, taxDiff, invoiceID); err != nil {
+				log.Println("[ERROR] Failed to update invoice:", err)
+				http.Error(w, "Failed to update invoice", http.StatusInternalServerError)
+				return
+			}
+			if checkoutID.Valid {
+				query = `UPDATE checkout SET total_amount = total_amount + $1 + $2, updated_at = NOW() WHERE id = $3`
+				if _, err := tx.Exec(query, diff, taxDiff, checkoutID.Int64); err != nil {
+					log.Println("[ERROR] Failed to update checkout:", err)
+					http.Error(w, "Failed to update invoice", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		query = `INSERT INTO invoice_adjustment (invoice_id, order_id, estimated_weight_kg, actual_weight_kg, charged_weight_kg, amount, tax_amount, type, status, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW()) RETURNING id, created_at`
+		err = tx.QueryRow(query, invoiceID, req.OrderID, estimated, req.ActualWeightKg, charged, diff, taxDiff, adjustment.Type, adjustment.Status).
+			Scan(&adjustment.ID, &adjustment.CreatedAt)
+		if err != nil {
+			log.Println("[ERROR] Failed to record invoice adjustment:", err)
+			http.Error(w, "Failed to record adjustment", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	eventPayload, err := loadInvoicePayload(tx, invoiceID)
+	if err == nil {
+		eventPayload.Adjustment = adjustment.Amount + adjustment.TaxAmount
+		err = events.Publish(tx, events.OrderWeighed, "invoice", invoiceID, eventPayload)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to publish weighing event:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Order weight recorded successfully",
+		"data":    adjustment,
+	})
+}
+
+// SettleInvoiceAdjustment menandai selisih tagihan yang sudah dibayar atau dikembalikan ke pembeli
+func SettleInvoiceAdjustment(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		log.Println("Unauthorized: failed to decode token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	membership, err := farmaccess.ForRequestPhone(sqlDB, r, payload.Id, farmaccess.VerifyPayments)
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve farm access:", err)
+		farmaccess.WriteError(w, err)
+		return
+	}
+	farmID := membership.FarmID
+
+	var req struct {
+		AdjustmentID int64 `json:"adjustment_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdjustmentID == 0 {
+		http.Error(w, "adjustment_id is required", http.StatusBadRequest)
+		return
+	}
+
+	query := `UPDATE invoice_adjustment ia SET status = 'Settled', settled_at = NOW()
+		FROM invoice i
+		WHERE ia.id = $1 AND i.id = ia.invoice_id AND i.farm_id = $2 AND ia.status = 'Pending'`
+	result, err := sqlDB.Exec(query, req.AdjustmentID, farmID)
+	if err != nil {
+		log.Println("[ERROR] Failed to settle adjustment:", err)
+		http.Error(w, "Failed to settle adjustment", http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Pending adjustment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Adjustment settled successfully",
+	})
+}
+
+func getInvoiceAdjustments(db queryer, invoiceID interface{}) ([]model.InvoiceAdjustment, error) {
+	query := `SELECT id, invoice_id, order_id, estimated_weight_kg, actual_weight_kg, charged_weight_kg, amount, tax_amount, type, status, created_at, settled_at
+		FROM invoice_adjustment WHERE invoice_id = $1 ORDER BY created_at`
+	rows, err := db.Query(query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []model.InvoiceAdjustment{}
+	for rows.Next() {
+		var a model.InvoiceAdjustment
+		if err := rows.Scan(&a.ID, &a.InvoiceID, &a.OrderID, &a.EstimatedWeightKg, &a.ActualWeightKg, &a.ChargedWeightKg,
+			&a.Amount, &a.TaxAmount, &a.Type, &a.Status, &a.CreatedAt, &a.SettledAt); err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
+
+// hasUnweighedLines memeriksa apakah masih ada baris catch-weight yang belum ditimbang pada pengiriman
+func hasUnweighedLines(db queryer, prosesPengirimanID int64) (bool, error) {
+	var pending bool
+	query := `SELECT EXISTS (SELECT 1 FROM orders WHERE id_proses_pengiriman = $1 AND catch_weight AND actual_weight_kg IS NULL)`
+	err := db.QueryRow(query, prosesPengirimanID).Scan(&pending)
+	return pending, err
+}
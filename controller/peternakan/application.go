@@ -0,0 +1,859 @@
+package peternakan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/ghupload"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
+	"farmdistribution_be/model"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Status pengajuan peternak
+const (
+	appSubmitted   = "submitted"
+	appUnderReview = "under_review"
+	appNeedsInfo   = "needs_info"
+	appApproved    = "approved"
+	appRejected    = "rejected"
+)
+
+// Jenis dokumen pengajuan; nama field multipart sama dengan jenisnya
+const (
+	docIDCard         = "id_card"
+	docFarmPhoto      = "farm_photo"
+	docBusinessPermit = "business_permit"
+)
+
+var validStatuses = map[string]bool{
+	appSubmitted: true, appUnderReview: true, appNeedsInfo: true, appApproved: true, appRejected: true,
+}
+
+// Jumlah berkas maksimal per jenis dokumen
+var docLimits = map[string]int{docIDCard: 1, docFarmPhoto: 5, docBusinessPermit: 1}
+
+// reviewTransitions adalah status tujuan yang boleh dipilih peninjau dari tiap status.
+// needs_info → submitted hanya dilakukan pemohon lewat ResubmitFarmApplication.
+var reviewTransitions = map[string][]string{
+	appSubmitted:   {appUnderReview, appNeedsInfo, appApproved, appRejected},
+	appUnderReview: {appNeedsInfo, appApproved, appRejected},
+	appNeedsInfo:   {appRejected},
+}
+
+func canReview(from, to string) bool {
+	for _, s := range reviewTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+const applicationColumns = `fa.id, fa.user_id, a.nama, a.no_telp, fa.status, fa.keterangan, fa.name, fa.farm_type,
+	fa.phonenumber_farm, fa.email, fa.description, fa.street, fa.city, fa.state, fa.postal_code, fa.country,
+	fa.lat, fa.lon, fa.rejection_reason, fa.reviewer_id, fa.reviewed_at, fa.farm_id, fa.created_at, fa.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApplication(s rowScanner) (model.FarmApplication, error) {
+	var app model.FarmApplication
+	err := s.Scan(&app.ID, &app.UserID, &app.NamaAkun, &app.NoTelp, &app.Status, &app.Keterangan, &app.Name, &app.FarmType,
+		&app.PhonenumberFarm, &app.Email, &app.Description, &app.Street, &app.City, &app.State, &app.PostalCode, &app.Country,
+		&app.Lat, &app.Lon, &app.RejectionReason, &app.ReviewerID, &app.ReviewedAt, &app.FarmID, &app.CreatedAt, &app.UpdatedAt)
+	return app, err
+}
+
+// loadApplicationDetail melengkapi app dengan dokumen dan riwayatnya
+func loadApplicationDetail(db *sql.DB, app *model.FarmApplication) error {
+	rows, err := db.Query(`SELECT id, doc_type, url, file_name, uploaded_at FROM farm_application_document
+		WHERE application_id = $1 ORDER BY doc_type, id`, app.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	app.Documents = []model.FarmApplicationDocument{}
+	for rows.Next() {
+		var d model.FarmApplicationDocument
+		if err := rows.Scan(&d.ID, &d.DocType, &d.URL, &d.FileName, &d.UploadedAt); err != nil {
+			return err
+		}
+		app.Documents = append(app.Documents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`SELECT e.id, e.from_status, e.to_status, e.actor_id, a.nama, e.actor_role, e.comment, e.created_at
+		FROM farm_application_event e
+		LEFT JOIN akun a ON a.id_user = e.actor_id
+		WHERE e.application_id = $1 ORDER BY e.created_at, e.id`, app.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	app.History = []model.FarmApplicationEvent{}
+	for rows.Next() {
+		var e model.FarmApplicationEvent
+		if err := rows.Scan(&e.ID, &e.FromStatus, &e.ToStatus, &e.ActorID, &e.ActorName, &e.ActorRole, &e.Comment, &e.CreatedAt); err != nil {
+			return err
+		}
+		app.History = append(app.History, e)
+	}
+	return rows.Err()
+}
+
+// recordApplicationEvent mencatat perubahan status atau komentar; from kosong untuk pengajuan baru
+func recordApplicationEvent(tx *sql.Tx, appID int64, from, to string, actorID int64, actorRole, comment string) error {
+	query := `INSERT INTO farm_application_event (application_id, from_status, to_status, actor_id, actor_role, comment, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), NOW())`
+	_, err := tx.Exec(query, appID, from, to, actorID, actorRole, comment)
+	return err
+}
+
+type uploadedDoc struct {
+	DocType  string
+	URL      string
+	FileName string
+}
+
+// uploadApplicationDocs mengunggah dokumen dari form multipart ke GitHub.
+// Hanya gambar dan PDF yang diterima; jenis yang tidak dikirim dilewati.
+func uploadApplicationDocs(r *http.Request, userID int64) ([]uploadedDoc, error) {
+	var docs []uploadedDoc
+	for _, docType := range []string{docIDCard, docFarmPhoto, docBusinessPermit} {
+		files := r.MultipartForm.File[docType]
+		if len(files) > docLimits[docType] {
+			return nil, fmt.Errorf("%w: at most %d file(s) allowed for %s", errTooManyDocs, docLimits[docType], docType)
+		}
+		for _, fh := range files {
+			url, err := uploadApplicationFile(fh, userID)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, uploadedDoc{DocType: docType, URL: url, FileName: fh.Filename})
+		}
+	}
+	return docs, nil
+}
+
+var (
+	errUnsupportedDoc = errors.New("documents must be images or PDF files")
+	errTooManyDocs    = errors.New("too many documents")
+)
+
+// isDocInputError membedakan kesalahan berkas dari pengguna dengan kegagalan unggah
+func isDocInputError(err error) bool {
+	return errors.Is(err, errUnsupportedDoc) || errors.Is(err, errTooManyDocs)
+}
+
+func uploadApplicationFile(fh *multipart.FileHeader, userID int64) (string, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	fileContent, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	contentType := http.DetectContentType(fileContent)
+	if !strings.HasPrefix(contentType, "image/") && contentType != "application/pdf" {
+		return "", errUnsupportedDoc
+	}
+
+	hashedFileName := ghupload.CalculateHash(fileContent) + strings.ToLower(filepath.Ext(fh.Filename))
+	pathFile := fmt.Sprintf("FarmApplications/%d/%s", userID, hashedFileName)
+	content, _, err := ghupload.GithubUpload(config.GHAccessToken, "ayalarifki", "ayalarifki@gmail.com", fileContent,
+		"ayala-crea", config.FarmApplicationDocsRepo, pathFile, true)
+	if err != nil {
+		return "", err
+	}
+	return *content.Content.HTMLURL, nil
+}
+
+// applicationInput adalah data peternakan yang diusulkan dari form multipart
+type applicationInput struct {
+	Keterangan, Name, FarmType, PhonenumberFarm, Email, Description string
+	Street, City, State, PostalCode, Country                        string
+	Lat, Lon                                                        *float64
+}
+
+func readApplicationInput(r *http.Request) (applicationInput, error) {
+	in := applicationInput{
+		Keterangan:      strings.TrimSpace(r.FormValue("keterangan")),
+		Name:            strings.TrimSpace(r.FormValue("name")),
+		FarmType:        strings.TrimSpace(r.FormValue("farm_type")),
+		PhonenumberFarm: strings.TrimSpace(r.FormValue("phonenumber_farm")),
+		Email:           strings.TrimSpace(r.FormValue("email")),
+		Description:     strings.TrimSpace(r.FormValue("description")),
+		Street:          strings.TrimSpace(r.FormValue("street")),
+		City:            strings.TrimSpace(r.FormValue("city")),
+		State:           strings.TrimSpace(r.FormValue("state")),
+		PostalCode:      strings.TrimSpace(r.FormValue("postal_code")),
+		Country:         strings.TrimSpace(r.FormValue("country")),
+	}
+	for field, dst := range map[string]**float64{"lat": &in.Lat, "lon": &in.Lon} {
+		v := r.FormValue(field)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return in, fmt.Errorf("%s must be a valid number", field)
+		}
+		*dst = &f
+	}
+	return in, nil
+}
+
+func writeError(w http.ResponseWriter, code int, errMsg, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   errMsg,
+		"message": message,
+	})
+}
+
+// akunCaller memastikan pemanggil adalah akun (bukan pengirim) yang login
+func akunCaller(w http.ResponseWriter, r *http.Request, db *sql.DB) (identity.Caller, bool) {
+	caller, err := identity.FromRequest(db, r)
+	if err == nil && caller.Type != identity.TypeAkun {
+		err = identity.ErrNotFound
+	}
+	switch err {
+	case nil:
+		return caller, true
+	case identity.ErrUnauthorized:
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "Invalid or expired token. Please log in again.")
+	case identity.ErrNotFound:
+		writeError(w, http.StatusNotFound, "User not found", "No account found for the given phone number.")
+	default:
+		log.Println("[ERROR] Failed to resolve caller:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+	return caller, false
+}
+
+// SubmitFarmApplication membuat pengajuan menjadi peternak (multipart).
+// Field: name, farm_type, phonenumber_farm, email, description, street, city, state, postal_code, country,
+// lat, lon, keterangan; berkas: id_card, farm_photo (boleh lebih dari satu) dan business_permit.
+func SubmitFarmApplication(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
+	var roleID int
+	var open bool
+	err = sqlDB.QueryRow(`SELECT a.id_role, EXISTS (SELECT 1 FROM farm_application fa
+			WHERE fa.user_id = a.id_user AND fa.status IN ('submitted', 'under_review', 'needs_info'))
+		FROM akun a WHERE a.id_user = $1`, caller.ID).Scan(&roleID, &open)
+	if err != nil {
+		log.Println("[ERROR] Failed to check existing application:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if roleID == config.FarmerRoleID {
+		writeError(w, http.StatusConflict, "Conflict", "You are already registered as a farmer.")
+		return
+	}
+	if open {
+		writeError(w, http.StatusConflict, "Conflict", "You have already submitted a request.")
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "Failed to parse form data.")
+		return
+	}
+	in, err := readApplicationInput(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+	if in.Name == "" || in.Street == "" || in.City == "" || in.State == "" || in.PostalCode == "" || in.Country == "" || in.Lat == nil || in.Lon == nil {
+		writeError(w, http.StatusBadRequest, "Missing required fields", "name, street, city, state, postal_code, country, lat and lon are required.")
+		return
+	}
+	for _, docType := range []string{docIDCard, docFarmPhoto, docBusinessPermit} {
+		if len(r.MultipartForm.File[docType]) == 0 {
+			writeError(w, http.StatusBadRequest, "Missing required documents", "id_card, farm_photo and business_permit are required.")
+			return
+		}
+	}
+
+	docs, err := uploadApplicationDocs(r, caller.ID)
+	if err != nil {
+		log.Println("[ERROR] Failed to upload application documents:", err)
+		if isDocInputError(err) {
+			writeError(w, http.StatusBadRequest, "Invalid document", err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "File upload failed", "Failed to upload documents to GitHub.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var appID int64
+	query := `INSERT INTO farm_application (user_id, status, keterangan, name, farm_type, phonenumber_farm, email, description,
+			street, city, state, postal_code, country, lat, lon, created_at, updated_at)
+		VALUES ($1, 'submitted', $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW())
+		ON CONFLICT DO NOTHING
+		RETURNING id`
+	err = tx.QueryRow(query, caller.ID, in.Keterangan, in.Name, in.FarmType, in.PhonenumberFarm, in.Email, in.Description,
+		in.Street, in.City, in.State, in.PostalCode, in.Country, *in.Lat, *in.Lon).Scan(&appID)
+	if err == sql.ErrNoRows {
+		// Pengajuan lain dibuat bersamaan dan lolos lebih dulu
+		writeError(w, http.StatusConflict, "Conflict", "You have already submitted a request.")
+		return
+	}
+	for _, d := range docs {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec(`INSERT INTO farm_application_document (application_id, doc_type, url, file_name, uploaded_at)
+			VALUES ($1, $2, $3, $4, NOW())`, appID, d.DocType, d.URL, d.FileName)
+	}
+	if err == nil {
+		err = recordApplicationEvent(tx, appID, "", appSubmitted, caller.ID, "applicant", in.Keterangan)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to save farm application:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to save farm application.")
+		return
+	}
+
+	app, err := scanApplication(sqlDB.QueryRow(`SELECT `+applicationColumns+`
+		FROM farm_application fa JOIN akun a ON a.id_user = fa.user_id WHERE fa.id = $1`, appID))
+	if err == nil {
+		err = loadApplicationDetail(sqlDB, &app)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to load farm application:", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm application submitted",
+		"data":    app,
+	})
+}
+
+// GetMyFarmApplication menampilkan pengajuan terbaru milik pemanggil beserta dokumen dan riwayatnya
+func GetMyFarmApplication(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
+	app, err := scanApplication(sqlDB.QueryRow(`SELECT `+applicationColumns+`
+		FROM farm_application fa JOIN akun a ON a.id_user = fa.user_id
+		WHERE fa.user_id = $1 ORDER BY fa.id DESC LIMIT 1`, caller.ID))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Not Found", "You have not submitted a farm application.")
+		return
+	}
+	if err == nil {
+		err = loadApplicationDetail(sqlDB, &app)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm application:", err)
+		http.Error(w, "Failed to fetch farm application", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm application retrieved successfully",
+		"data":    app,
+	})
+}
+
+// GetFarmApplicationByID menampilkan satu pengajuan; hanya untuk pemohon dan admin
+func GetFarmApplicationByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
+	app, err := scanApplication(sqlDB.QueryRow(`SELECT `+applicationColumns+`
+		FROM farm_application fa JOIN akun a ON a.id_user = fa.user_id WHERE fa.id = $1`, mux.Vars(r)["id"]))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Not Found", "Farm application not found.")
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm application:", err)
+		http.Error(w, "Failed to fetch farm application", http.StatusInternalServerError)
+		return
+	}
+	if app.UserID != caller.ID {
+		isAdmin, err := identity.IsAdmin(sqlDB, caller.NoTelp)
+		if err != nil {
+			log.Println("[ERROR] Failed to check admin role:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !isAdmin {
+			writeError(w, http.StatusForbidden, "Forbidden", "You do not have access to this farm application.")
+			return
+		}
+	}
+	if err := loadApplicationDetail(sqlDB, &app); err != nil {
+		log.Println("[ERROR] Failed to fetch farm application detail:", err)
+		http.Error(w, "Failed to fetch farm application", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm application retrieved successfully",
+		"data":    app,
+	})
+}
+
+// ResubmitFarmApplication dipakai pemohon untuk melengkapi pengajuan berstatus needs_info (multipart).
+// Field yang dikirim menggantikan data lama; dokumen yang dikirim menggantikan dokumen sejenis.
+// Status kembali menjadi submitted dengan komentar opsional pada field "comment".
+func ResubmitFarmApplication(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	appID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	// Pemeriksaan awal sebelum mengunggah dokumen; diulang dengan kunci di dalam transaksi
+	var ownerID int64
+	var status string
+	err = sqlDB.QueryRow(`SELECT user_id, status FROM farm_application WHERE id = $1`, appID).Scan(&ownerID, &status)
+	if err == sql.ErrNoRows || (err == nil && ownerID != caller.ID) {
+		writeError(w, http.StatusNotFound, "Not Found", "Farm application not found.")
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm application:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if status != appNeedsInfo {
+		writeError(w, http.StatusConflict, "Conflict", "Only applications that need more information can be updated.")
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "Failed to parse form data.")
+		return
+	}
+	in, err := readApplicationInput(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", err.Error())
+		return
+	}
+	docs, err := uploadApplicationDocs(r, caller.ID)
+	if err != nil {
+		log.Println("[ERROR] Failed to upload application documents:", err)
+		if isDocInputError(err) {
+			writeError(w, http.StatusBadRequest, "Invalid document", err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "File upload failed", "Failed to upload documents to GitHub.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT status FROM farm_application WHERE id = $1 FOR UPDATE`, appID).Scan(&status)
+	if err == nil && status != appNeedsInfo {
+		writeError(w, http.StatusConflict, "Conflict", "Only applications that need more information can be updated.")
+		return
+	}
+	if err == nil {
+		query := `UPDATE farm_application SET
+				status = 'submitted',
+				keterangan = COALESCE(NULLIF($2, ''), keterangan),
+				name = COALESCE(NULLIF($3, ''), name),
+				farm_type = COALESCE(NULLIF($4, ''), farm_type),
+				phonenumber_farm = COALESCE(NULLIF($5, ''), phonenumber_farm),
+				email = COALESCE(NULLIF($6, ''), email),
+				description = COALESCE(NULLIF($7, ''), description),
+				street = COALESCE(NULLIF($8, ''), street),
+				city = COALESCE(NULLIF($9, ''), city),
+				state = COALESCE(NULLIF($10, ''), state),
+				postal_code = COALESCE(NULLIF($11, ''), postal_code),
+				country = COALESCE(NULLIF($12, ''), country),
+				lat = COALESCE($13, lat),
+				lon = COALESCE($14, lon),
+				updated_at = NOW()
+			WHERE id = $1`
+		_, err = tx.Exec(query, appID, in.Keterangan, in.Name, in.FarmType, in.PhonenumberFarm, in.Email, in.Description,
+			in.Street, in.City, in.State, in.PostalCode, in.Country, in.Lat, in.Lon)
+	}
+	// Dokumen baru menggantikan semua dokumen sejenis
+	replaced := map[string]bool{}
+	for _, d := range docs {
+		if err != nil {
+			break
+		}
+		if !replaced[d.DocType] {
+			replaced[d.DocType] = true
+			_, err = tx.Exec(`DELETE FROM farm_application_document WHERE application_id = $1 AND doc_type = $2`, appID, d.DocType)
+		}
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO farm_application_document (application_id, doc_type, url, file_name, uploaded_at)
+				VALUES ($1, $2, $3, $4, NOW())`, appID, d.DocType, d.URL, d.FileName)
+		}
+	}
+	if err == nil {
+		err = recordApplicationEvent(tx, appID, appNeedsInfo, appSubmitted, caller.ID, "applicant", strings.TrimSpace(r.FormValue("comment")))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to resubmit farm application:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to update farm application.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm application resubmitted",
+		"data":    map[string]interface{}{"id": appID, "status": appSubmitted},
+	})
+}
+
+// requireAdmin memastikan pemanggil adalah admin dan mengembalikan akunnya
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB) (identity.Caller, bool) {
+	caller, ok := akunCaller(w, r, db)
+	if !ok {
+		return caller, false
+	}
+	isAdmin, err := identity.IsAdmin(db, caller.NoTelp)
+	if err != nil {
+		log.Println("[ERROR] Failed to check admin role:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return caller, false
+	}
+	if !isAdmin {
+		writeError(w, http.StatusForbidden, "Forbidden", "Only administrators can access this resource.")
+		return caller, false
+	}
+	return caller, true
+}
+
+// applicationListSpec adalah field daftar pengajuan yang boleh diurutkan, difilter dan dicari
+var applicationListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"status":     {Column: "fa.status", Type: listquery.Text, Sort: true, Filter: true},
+		"name":       {Column: "fa.name", Type: listquery.Text, Sort: true, Filter: true},
+		"farm_type":  {Column: "fa.farm_type", Type: listquery.Text, Filter: true},
+		"city":       {Column: "fa.city", Type: listquery.Text, Filter: true},
+		"created_at": {Column: "fa.created_at", Type: listquery.Time, Sort: true, Filter: true},
+		"updated_at": {Column: "fa.updated_at", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:     "fa.id",
+	Search: []string{"fa.name", "a.nama"},
+}
+
+// keyedRow meneruskan kolom kunci listquery setelah kolom yang dibaca scanApplication
+type keyedRow struct {
+	rows *sql.Rows
+	key  []interface{}
+}
+
+func (k keyedRow) Scan(dest ...interface{}) error {
+	return k.rows.Scan(append(dest, k.key...)...)
+}
+
+// GetFarmApplications menampilkan pengajuan untuk admin, terbaru lebih dulu; parameter mengikuti
+// listquery, mis. status=submitted dan q (nama peternakan atau nama pemohon)
+func GetFarmApplications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := requireAdmin(w, r, sqlDB); !ok {
+		return
+	}
+
+	lq, err := listquery.Parse(r, applicationListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+
+	page, err := listquery.Run(sqlDB, lq, applicationColumns,
+		`farm_application fa JOIN akun a ON a.id_user = fa.user_id`,
+		func(rows *sql.Rows, key ...interface{}) (model.FarmApplication, error) {
+			return scanApplication(keyedRow{rows, key})
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm applications:", err)
+		listquery.WriteError(w, err)
+		return
+	}
+
+	listquery.Write(w, "Farm applications retrieved successfully", page)
+}
+
+// ReviewFarmApplication dipakai admin untuk meninjau pengajuan.
+// Body: {"status": "under_review"|"needs_info"|"approved"|"rejected", "comment": "...", "reason": "..."}.
+// Tanpa status hanya menambah komentar. needs_info wajib disertai comment, rejected wajib disertai reason.
+// Persetujuan memberi role peternak dan membuat data peternakan dari pengajuan dalam satu transaksi.
+func ReviewFarmApplication(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	reviewer, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	appID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	var req struct {
+		Status  string `json:"status"`
+		Comment string `json:"comment"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "The JSON request body could not be decoded.")
+		return
+	}
+	req.Comment, req.Reason = strings.TrimSpace(req.Comment), strings.TrimSpace(req.Reason)
+	switch {
+	case req.Status != "" && !validStatuses[req.Status]:
+		writeError(w, http.StatusBadRequest, "Invalid status", "Unknown application status.")
+		return
+	case req.Status == "" && req.Comment == "":
+		writeError(w, http.StatusBadRequest, "Missing required fields", "Provide a status or a comment.")
+		return
+	case req.Status == appNeedsInfo && req.Comment == "":
+		writeError(w, http.StatusBadRequest, "Missing required fields", "Describe the information needed in comment.")
+		return
+	case req.Status == appRejected && req.Reason == "":
+		writeError(w, http.StatusBadRequest, "Missing required fields", "A reason is required to reject an application.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	app, err := scanApplication(tx.QueryRow(`SELECT `+applicationColumns+`
+		FROM farm_application fa JOIN akun a ON a.id_user = fa.user_id WHERE fa.id = $1 FOR UPDATE OF fa`, appID))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Not Found", "Farm application not found.")
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm application:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Komentar saja tidak mengubah status
+	if req.Status == "" {
+		err = recordApplicationEvent(tx, appID, app.Status, app.Status, reviewer.ID, "reviewer", req.Comment)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Println("[ERROR] Failed to add review comment:", err)
+			writeError(w, http.StatusInternalServerError, "Database error", "Failed to add comment.")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"message": "Comment added",
+			"data":    map[string]interface{}{"id": appID, "status": app.Status},
+		})
+		return
+	}
+
+	if !canReview(app.Status, req.Status) {
+		writeError(w, http.StatusConflict, "Invalid status transition",
+			fmt.Sprintf("Cannot change application status from %s to %s.", app.Status, req.Status))
+		return
+	}
+
+	entry := audit.New(r, reviewer.Type, reviewer.ID, "farm_application."+req.Status, "farm_application", appID)
+	entry.Before, err = audit.Snapshot(tx, "farm_application", "id", appID)
+
+	var farmID int64
+	if err == nil && req.Status == appApproved {
+		farmID, err = approveApplication(tx, r, reviewer, app)
+	}
+	if err == nil {
+		query := `UPDATE farm_application SET status = $2, rejection_reason = NULLIF($3, ''), reviewer_id = $4,
+				reviewed_at = NOW(), farm_id = NULLIF($5, 0), updated_at = NOW()
+			WHERE id = $1`
+		_, err = tx.Exec(query, appID, req.Status, req.Reason, reviewer.ID, farmID)
+	}
+	if err == nil {
+		comment := req.Comment
+		if req.Status == appRejected && comment == "" {
+			comment = req.Reason
+		}
+		err = recordApplicationEvent(tx, appID, app.Status, req.Status, reviewer.ID, "reviewer", comment)
+	}
+	if err == nil {
+		entry.After, err = audit.Snapshot(tx, "farm_application", "id", appID)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		payload := events.FarmPayload{FarmID: farmID, UserID: app.UserID, ApplicationID: appID}
+		switch req.Status {
+		case appApproved:
+			err = events.Publish(tx, events.FarmApproved, "farm_application", appID, payload)
+		case appNeedsInfo:
+			payload.Reason = req.Comment
+			err = events.Publish(tx, events.FarmNeedsInfo, "farm_application", appID, payload)
+		case appRejected:
+			payload.Reason = req.Reason
+			err = events.Publish(tx, events.FarmRejected, "farm_application", appID, payload)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to review farm application:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to review farm application.")
+		return
+	}
+
+	data := map[string]interface{}{"id": appID, "status": req.Status}
+	if farmID != 0 {
+		data["farm_id"] = farmID
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm application " + strings.ReplaceAll(req.Status, "_", " "),
+		"data":    data,
+	})
+}
+
+// approveApplication memberi role peternak kepada pemohon dan membuat peternakan dari data pengajuan.
+// Foto peternakan pertama dipakai sebagai image_farm dan pemohon menjadi anggota owner.
+func approveApplication(tx *sql.Tx, r *http.Request, reviewer identity.Caller, app model.FarmApplication) (int64, error) {
+	entry := audit.New(r, reviewer.Type, reviewer.ID, "akun.promote_peternak", "akun", app.UserID)
+	var err error
+	entry.Before, err = audit.Snapshot(tx, "akun", "id_user", app.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`UPDATE akun SET id_role = $1 WHERE id_user = $2`, config.FarmerRoleID, app.UserID); err != nil {
+		return 0, err
+	}
+	if entry.After, err = audit.Snapshot(tx, "akun", "id_user", app.UserID); err != nil {
+		return 0, err
+	}
+	if err = audit.Record(tx, entry); err != nil {
+		return 0, err
+	}
+
+	var farmImageURL string
+	err = tx.QueryRow(`SELECT url FROM farm_application_document
+		WHERE application_id = $1 AND doc_type = 'farm_photo' ORDER BY id LIMIT 1`, app.ID).Scan(&farmImageURL)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	var addressFarmID int
+	queryAddressFarm := `INSERT INTO addressfarm (street, city, state, postal_code, country)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id_addressfarm`
+	err = tx.QueryRow(queryAddressFarm, app.Street, app.City, app.State, app.PostalCode, app.Country).Scan(&addressFarmID)
+	if err != nil {
+		return 0, err
+	}
+
+	var farmID int64
+	queryFarms := `INSERT INTO farms (name, owner_id, farm_type, addressfarm_id, location, image_farm, phonenumber_farm, email, description, created_at)
+		VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326), $7, $8, $9, $10, NOW())
+		RETURNING id`
+	err = tx.QueryRow(queryFarms, app.Name, app.UserID, app.FarmType, addressFarmID, app.Lat, app.Lon,
+		farmImageURL, app.PhonenumberFarm, app.Email, app.Description).Scan(&farmID)
+	if err != nil {
+		return 0, err
+	}
+	if err = assignFarmCode(tx, farmID); err != nil {
+		return 0, err
+	}
+	return farmID, farmaccess.AddMember(tx, farmID, app.UserID, farmaccess.RoleOwner, 0)
+}
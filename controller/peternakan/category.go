@@ -0,0 +1,354 @@
+package peternakan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/category"
+	"farmdistribution_be/helper/slug"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type categoryInput struct {
+	Name        *string `json:"name"`
+	Slug        *string `json:"slug"`
+	ParentID    *int64  `json:"parent_id"` // 0 berarti kategori teratas
+	Description *string `json:"description"`
+	SortOrder   *int    `json:"sort_order"`
+}
+
+// parseCategoryParam membaca parameter category_id; 0 jika kosong
+func parseCategoryParam(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("category_id"))
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// parseProductCategory membaca category_id dari form produk. set false berarti field tidak dikirim;
+// "0" menghapus kategori produk.
+func parseProductCategory(r *http.Request, db *sql.DB) (id sql.NullInt64, set bool, msg string) {
+	v := strings.TrimSpace(r.FormValue("category_id"))
+	if v == "" {
+		return id, false, ""
+	}
+	parsed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || parsed < 0 {
+		return id, true, "category_id must be a category ID."
+	}
+	if parsed == 0 {
+		return id, true, ""
+	}
+	ok, err := category.Exists(db, parsed)
+	if err != nil {
+		log.Println("[ERROR] Failed to check product category:", err)
+		return id, true, "Failed to check product category."
+	}
+	if !ok {
+		return id, true, "Product category not found."
+	}
+	return sql.NullInt64{Int64: parsed, Valid: true}, true, ""
+}
+
+// GetProductCategories menampilkan pohon kategori beserta jumlah produk tiap kategori
+// (termasuk produk pada subkategori)
+func GetProductCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	list, err := category.Counts(sqlDB, "")
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch product categories:", err)
+		http.Error(w, "Failed to fetch product categories", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product categories retrieved successfully",
+		"data":    category.Tree(list),
+	})
+}
+
+// checkCategoryInput memvalidasi induk dan slug kategori; pesan kosong berarti valid
+func checkCategoryInput(tx *sql.Tx, id int64, parentID int64, slugValue string) (int, string, error) {
+	if parentID != 0 {
+		ok, err := category.Exists(tx, parentID)
+		if err != nil {
+			return 0, "", err
+		}
+		if !ok {
+			return http.StatusBadRequest, "Parent category not found.", nil
+		}
+		if id != 0 {
+			cycle, err := category.WouldCycle(tx, id, parentID)
+			if err != nil {
+				return 0, "", err
+			}
+			if cycle {
+				return http.StatusBadRequest, "A category cannot be moved under itself or one of its subcategories.", nil
+			}
+		}
+	}
+	var taken bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_category WHERE slug = $1 AND id <> $2)`, slugValue, id).Scan(&taken)
+	if err != nil {
+		return 0, "", err
+	}
+	if taken {
+		return http.StatusConflict, "Another category already uses this slug.", nil
+	}
+	return 0, "", nil
+}
+
+// CreateProductCategory menambahkan kategori (admin).
+// Body: {"name", "slug", "parent_id", "description", "sort_order"}; slug dibuat dari nama jika kosong.
+func CreateProductCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	admin, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
+	var req categoryInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "The JSON request body could not be decoded.")
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields", "name is required.")
+		return
+	}
+	name := strings.TrimSpace(*req.Name)
+	slugValue := slug.GenerateSlug(name)
+	if req.Slug != nil && strings.TrimSpace(*req.Slug) != "" {
+		slugValue = slug.GenerateSlug(strings.TrimSpace(*req.Slug))
+	}
+	var parentID int64
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+	sortOrder := 0
+	if req.SortOrder != nil {
+		sortOrder = *req.SortOrder
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	code, msg, err := checkCategoryInput(tx, 0, parentID, slugValue)
+	if err == nil && msg != "" {
+		writeError(w, code, http.StatusText(code), msg)
+		return
+	}
+
+	var id int64
+	if err == nil {
+		err = tx.QueryRow(`INSERT INTO product_category (parent_id, name, slug, description, sort_order, created_at, updated_at)
+			VALUES (NULLIF($1, 0), $2, $3, $4, $5, NOW(), NOW()) RETURNING id`,
+			parentID, name, slugValue, req.Description, sortOrder).Scan(&id)
+	}
+	var entry audit.Entry
+	if err == nil {
+		entry = audit.New(r, admin.Type, admin.ID, "product_category.create", "product_category", id)
+		entry.After, err = audit.Snapshot(tx, "product_category", "id", id)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to create product category:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to create product category.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product category created",
+		"data":    entry.After,
+	})
+}
+
+// UpdateProductCategory mengubah kategori (admin). Field yang tidak dikirim tidak diubah;
+// parent_id 0 memindahkan kategori ke tingkat teratas.
+func UpdateProductCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	admin, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	var req categoryInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "The JSON request body could not be decoded.")
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Invalid name", "name cannot be empty.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := audit.New(r, admin.Type, admin.ID, "product_category.update", "product_category", id)
+	entry.Before, err = audit.Snapshot(tx, "product_category", "id", id)
+	if err == nil && entry.Before == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "Product category not found.")
+		return
+	}
+
+	var current struct {
+		ParentID sql.NullInt64
+		Name     string
+		Slug     string
+	}
+	if err == nil {
+		err = tx.QueryRow(`SELECT parent_id, name, slug FROM product_category WHERE id = $1 FOR UPDATE`, id).
+			Scan(&current.ParentID, &current.Name, &current.Slug)
+	}
+	name, slugValue, parentID := current.Name, current.Slug, current.ParentID.Int64
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+	}
+	if req.Slug != nil && strings.TrimSpace(*req.Slug) != "" {
+		slugValue = slug.GenerateSlug(strings.TrimSpace(*req.Slug))
+	}
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+
+	if err == nil {
+		var code int
+		var msg string
+		code, msg, err = checkCategoryInput(tx, id, parentID, slugValue)
+		if err == nil && msg != "" {
+			writeError(w, code, http.StatusText(code), msg)
+			return
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE product_category
+			SET parent_id = NULLIF($2, 0), name = $3, slug = $4,
+				description = COALESCE($5, description), sort_order = COALESCE($6, sort_order), updated_at = NOW()
+			WHERE id = $1`, id, parentID, name, slugValue, req.Description, req.SortOrder)
+	}
+	if err == nil {
+		entry.After, err = audit.Snapshot(tx, "product_category", "id", id)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to update product category:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to update product category.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product category updated",
+		"data":    entry.After,
+	})
+}
+
+// DeleteProductCategory menghapus kategori tanpa subkategori (admin). Produk pada kategori
+// tersebut menjadi tanpa kategori.
+func DeleteProductCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	admin, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := audit.New(r, admin.Type, admin.ID, "product_category.delete", "product_category", id)
+	entry.Before, err = audit.Snapshot(tx, "product_category", "id", id)
+	if err == nil && entry.Before == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "Product category not found.")
+		return
+	}
+	var hasChildren bool
+	if err == nil {
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_category WHERE parent_id = $1)`, id).Scan(&hasChildren)
+	}
+	if err == nil && hasChildren {
+		writeError(w, http.StatusConflict, "Conflict", "Delete or move the subcategories first.")
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM product_category WHERE id = $1`, id)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to delete product category:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to delete product category.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Product category deleted",
+	})
+}
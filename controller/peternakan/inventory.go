@@ -0,0 +1,301 @@
+package peternakan
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/inventory"
+	"farmdistribution_be/helper/jobs"
+	"farmdistribution_be/helper/listquery"
+	"farmdistribution_be/helper/qty"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// JobReconcileInventory adalah jenis job untuk inventory.ReconcileAll
+const JobReconcileInventory = "inventory.reconcile"
+
+// RegisterJobs mendaftarkan job latar belakang milik modul peternakan
+func RegisterJobs() error {
+	// Rekonsiliasi hanya melaporkan selisih ke log; koreksinya dicatat peternakan atau admin
+	// setelah sebabnya diketahui (lihat /admin/inventory/drift)
+	jobs.Register(JobReconcileInventory, func(ctx context.Context, _ json.RawMessage) error {
+		sqlDB, err := config.PostgresDB.DB()
+		if err != nil {
+			return err
+		}
+		drifts, err := inventory.ReconcileAll(sqlDB)
+		if err != nil {
+			return err
+		}
+		for _, d := range drifts {
+			log.Printf("[WARNING] Inventory drift: product %d (farm %d) stock_kg %s, ledger %s",
+				d.ProductID, d.FarmID, d.StockKg, d.LedgerKg)
+		}
+		return nil
+	})
+	return jobs.Schedule("inventory-reconcile", config.InventoryReconcileSchedule, JobReconcileInventory)
+}
+
+// manualMovements adalah jenis mutasi yang boleh dicatat langsung oleh peternakan;
+// sale dan cancellation_return hanya dibuat oleh order
+var manualMovements = map[string]bool{
+	inventory.Restock:    true,
+	inventory.Spoilage:   true,
+	inventory.Adjustment: true,
+}
+
+// stockChange adalah perubahan stok dari form produk
+type stockChange struct {
+	set      bool
+	targetKg qty.Qty
+	typ      string // Kosong berarti restock jika bertambah dan adjustment jika berkurang
+	reason   string
+}
+
+// parseStockChange membaca stock_kg, stock_movement dan stock_reason; pesan kosong berarti valid
+func parseStockChange(r *http.Request) (stockChange, string) {
+	var c stockChange
+	v := strings.TrimSpace(r.FormValue("stock_kg"))
+	if v == "" {
+		return c, ""
+	}
+	target, err := qty.Parse(v)
+	if err != nil || target < 0 {
+		return c, "stock_kg must be a non-negative number with at most three decimals."
+	}
+	c.set, c.targetKg = true, target
+	c.typ = strings.TrimSpace(r.FormValue("stock_movement"))
+	if c.typ != "" && !manualMovements[c.typ] {
+		return c, "stock_movement must be restock, spoilage or adjustment."
+	}
+	c.reason = strings.TrimSpace(r.FormValue("stock_reason"))
+	return c, ""
+}
+
+// apply mencatat selisih stok saat ini dengan stok tujuan sebagai mutasi ledger.
+// Pesan tidak kosong berarti jenis mutasi tidak sesuai arah perubahan.
+func (c stockChange) apply(tx *sql.Tx, productID, akunID int64) (string, error) {
+	var current qty.Qty
+	err := tx.QueryRow(`SELECT stock_kg FROM farm_products WHERE id = $1 FOR UPDATE`, productID).Scan(&current)
+	if err != nil {
+		return "", err
+	}
+	delta := c.targetKg - current
+	if delta == 0 {
+		return "", nil
+	}
+	typ := c.typ
+	if typ == "" {
+		typ = inventory.Restock
+		if delta < 0 {
+			typ = inventory.Adjustment
+		}
+	}
+	if err := inventory.CheckQuantity(typ, delta); err != nil {
+		return "stock_kg " + c.targetKg.String() + " does not match stock_movement " + typ + ": " + err.Error() + ".", nil
+	}
+	_, err = inventory.Move(tx, inventory.Movement{
+		ProductID:  productID,
+		Type:       typ,
+		QuantityKg: delta,
+		Reason:     c.reason,
+		ActorType:  identity.TypeAkun,
+		ActorID:    akunID,
+	})
+	return "", err
+}
+
+type stockMovement struct {
+	ID            int64     `json:"id"`
+	Type          string    `json:"type"`
+	QuantityKg    qty.Qty   `json:"quantity_kg"`
+	BalanceKg     qty.Qty   `json:"balance_kg"`
+	Reason        *string   `json:"reason"`
+	ActorType     string    `json:"actor_type"`
+	ActorID       *int64    `json:"actor_id"`
+	ActorName     *string   `json:"actor_name"`
+	ReferenceType *string   `json:"reference_type"`
+	ReferenceID   *int64    `json:"reference_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// stockMovementListSpec adalah field riwayat stok yang boleh diurutkan, difilter dan dicari
+var stockMovementListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"type":           {Column: "m.type", Type: listquery.Text, Filter: true},
+		"quantity_kg":    {Column: "m.quantity_kg", Type: listquery.Float, Sort: true, Filter: true},
+		"actor_type":     {Column: "m.actor_type", Type: listquery.Text, Filter: true},
+		"reference_type": {Column: "m.reference_type", Type: listquery.Text, Filter: true},
+		"reference_id":   {Column: "m.reference_id", Type: listquery.Int, Filter: true},
+		"created_at":     {Column: "m.created_at", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:     "m.id",
+	Search: []string{"m.reason"},
+}
+
+// productOfFarm memastikan produk pada URL milik peternakan; false berarti respons error sudah ditulis
+func productOfFarm(w http.ResponseWriter, db *sql.DB, productID, farmID int64) bool {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM farm_products WHERE id = $1 AND farm_id = $2)`, productID, farmID).Scan(&exists)
+	if err != nil {
+		log.Println("[ERROR] Failed to check product:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "Product not found", "No product found with the given ID for this farm.")
+		return false
+	}
+	return true
+}
+
+// GetStockMovements menampilkan riwayat mutasi stok produk peternakan (terbaru dahulu) beserta
+// hasil rekonsiliasi stok dengan ledger; parameter mengikuti listquery
+func GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	_, m, ok := memberFarm(w, r, sqlDB, farmaccess.ViewFarm)
+	if !ok {
+		return
+	}
+	productID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if !productOfFarm(w, sqlDB, productID, m.FarmID) {
+		return
+	}
+
+	lq, err := listquery.Parse(r, stockMovementListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
+	lq.Where("m.product_id = ?", productID)
+
+	page, err := listquery.Run(sqlDB, lq,
+		`m.id, m.type, m.quantity_kg, m.balance_kg, m.reason, m.actor_type, m.actor_id,
+			CASE m.actor_type WHEN 'akun' THEN a.nama WHEN 'pengirim' THEN p.name END,
+			m.reference_type, m.reference_id, m.created_at`,
+		`inventory_movements m
+			LEFT JOIN akun a ON m.actor_type = 'akun' AND a.id_user = m.actor_id
+			LEFT JOIN pengirim p ON m.actor_type = 'pengirim' AND p.id = m.actor_id`,
+		func(rows *sql.Rows, key ...interface{}) (stockMovement, error) {
+			var mv stockMovement
+			err := rows.Scan(append([]interface{}{&mv.ID, &mv.Type, &mv.QuantityKg, &mv.BalanceKg, &mv.Reason, &mv.ActorType,
+				&mv.ActorID, &mv.ActorName, &mv.ReferenceType, &mv.ReferenceID, &mv.CreatedAt}, key...)...)
+			return mv, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch stock movements:", err)
+		listquery.WriteError(w, err)
+		return
+	}
+
+	rec, err := inventory.Reconcile(sqlDB, productID)
+	if err != nil {
+		log.Println("[ERROR] Failed to reconcile stock:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	listquery.Write(w, "Stock movements retrieved successfully", struct {
+		listquery.Page[stockMovement]
+		Reconciliation inventory.Reconciliation `json:"reconciliation"`
+	}{page, rec})
+}
+
+// CreateStockMovement mencatat mutasi stok manual (restock, spoilage atau adjustment).
+// Body: {"type", "quantity_kg", "reason"}; quantity_kg bertanda, mis. -2.5 untuk spoilage.
+func CreateStockMovement(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, m, ok := memberFarm(w, r, sqlDB, farmaccess.ManageProducts)
+	if !ok {
+		return
+	}
+	productID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if !productOfFarm(w, sqlDB, productID, m.FarmID) {
+		return
+	}
+
+	var req struct {
+		Type       string  `json:"type"`
+		QuantityKg qty.Qty `json:"quantity_kg"`
+		Reason     string  `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "The JSON request body could not be decoded.")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !manualMovements[req.Type] {
+		writeError(w, http.StatusBadRequest, "Invalid movement type", "type must be restock, spoilage or adjustment.")
+		return
+	}
+	if err := inventory.CheckQuantity(req.Type, req.QuantityKg); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid quantity", err.Error()+".")
+		return
+	}
+	if req.Type != inventory.Restock && req.Reason == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields", "reason is required for spoilage and adjustment.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := inventory.Move(tx, inventory.Movement{
+		ProductID:  productID,
+		Type:       req.Type,
+		QuantityKg: req.QuantityKg,
+		Reason:     req.Reason,
+		ActorType:  identity.TypeAkun,
+		ActorID:    caller.ID,
+	})
+	if err == inventory.ErrInsufficient {
+		writeError(w, http.StatusConflict, "Insufficient stock", "The movement would make stock negative.")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to record stock movement:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to record stock movement.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Stock movement recorded",
+		"data": map[string]interface{}{
+			"product_id":  productID,
+			"type":        req.Type,
+			"quantity_kg": res.AppliedKg,
+			"stock_kg":    res.BalanceKg,
+		},
+	})
+}
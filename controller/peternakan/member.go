@@ -0,0 +1,600 @@
+package peternakan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/identity"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type farmMember struct {
+	AkunID    int64     `json:"akun_id"`
+	Nama      string    `json:"nama"`
+	NoTelp    string    `json:"no_telp"`
+	Email     *string   `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy *int64    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type farmInvitation struct {
+	ID          int64      `json:"id"`
+	FarmID      int64      `json:"farm_id"`
+	FarmName    string     `json:"farm_name"`
+	Role        string     `json:"role"`
+	Email       *string    `json:"email"`
+	NoTelp      *string    `json:"no_telp"`
+	Status      string     `json:"status"`
+	InvitedBy   *int64     `json:"invited_by"`
+	InviterName *string    `json:"inviter_name"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+const invitationColumns = `fi.id, fi.farm_id, f.name, fi.role, fi.email, fi.no_telp,
+	CASE WHEN fi.status = 'pending' AND fi.expires_at <= NOW() THEN 'expired' ELSE fi.status END,
+	fi.invited_by, a.nama, fi.expires_at, fi.created_at, fi.responded_at`
+
+func scanInvitation(s rowScanner) (farmInvitation, error) {
+	var inv farmInvitation
+	err := s.Scan(&inv.ID, &inv.FarmID, &inv.FarmName, &inv.Role, &inv.Email, &inv.NoTelp, &inv.Status,
+		&inv.InvitedBy, &inv.InviterName, &inv.ExpiresAt, &inv.CreatedAt, &inv.RespondedAt)
+	return inv, err
+}
+
+// memberFarm mengembalikan pemanggil dan peternakan tempat ia memiliki Permission p;
+// false berarti respons error sudah ditulis
+func memberFarm(w http.ResponseWriter, r *http.Request, db *sql.DB, p farmaccess.Permission) (identity.Caller, farmaccess.Membership, bool) {
+	caller, ok := akunCaller(w, r, db)
+	if !ok {
+		return caller, farmaccess.Membership{}, false
+	}
+	m, err := farmaccess.ForRequest(db, r, caller.ID, p)
+	if err != nil {
+		if err != farmaccess.ErrNoFarm && err != farmaccess.ErrForbidden {
+			log.Println("[ERROR] Failed to resolve farm access:", err)
+		}
+		farmaccess.WriteError(w, err)
+		return caller, m, false
+	}
+	return caller, m, true
+}
+
+// memberRole memvalidasi role yang boleh diberikan lewat undangan atau perubahan role
+func memberRole(role string) bool {
+	return farmaccess.ValidRole(role) && role != farmaccess.RoleOwner
+}
+
+// GetFarmMembers menampilkan anggota peternakan pemanggil beserta role-nya
+func GetFarmMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	_, m, ok := memberFarm(w, r, sqlDB, farmaccess.ViewFarm)
+	if !ok {
+		return
+	}
+
+	query := `SELECT m.akun_id, a.nama, a.no_telp, a.email, m.role, m.invited_by, m.created_at
+		FROM farm_member m JOIN akun a ON a.id_user = m.akun_id
+		WHERE m.farm_id = $1
+		ORDER BY (m.role = 'owner') DESC, m.created_at, m.akun_id`
+	rows, err := sqlDB.Query(query, m.FarmID)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm members:", err)
+		http.Error(w, "Failed to fetch farm members", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	members := []farmMember{}
+	for rows.Next() {
+		var fm farmMember
+		if err := rows.Scan(&fm.AkunID, &fm.Nama, &fm.NoTelp, &fm.Email, &fm.Role, &fm.InvitedBy, &fm.CreatedAt); err != nil {
+			log.Println("[ERROR] Failed to scan farm member:", err)
+			http.Error(w, "Failed to fetch farm members", http.StatusInternalServerError)
+			return
+		}
+		members = append(members, fm)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm members retrieved successfully",
+		"data": map[string]interface{}{
+			"farm_id": m.FarmID,
+			"role":    m.Role,
+			"members": members,
+		},
+	})
+}
+
+// UpdateFarmMember mengubah role anggota (manager, packer atau cashier). Role owner tidak dapat diubah.
+func UpdateFarmMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, m, ok := memberFarm(w, r, sqlDB, farmaccess.ManageMembers)
+	if !ok {
+		return
+	}
+	akunID, _ := strconv.ParseInt(mux.Vars(r)["akun_id"], 10, 64)
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !memberRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "Invalid role", "role must be manager, packer or cashier.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := audit.New(r, caller.Type, caller.ID, "farm_member.update", "farm_member", strconv.FormatInt(m.FarmID, 10)+":"+strconv.FormatInt(akunID, 10))
+	entry.Before, err = audit.SnapshotWhere(tx, "farm_member", "farm_id = $1 AND akun_id = $2", m.FarmID, akunID)
+	if err == nil && entry.Before == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "Member not found.")
+		return
+	}
+	if err == nil && entry.Before["role"] == farmaccess.RoleOwner {
+		writeError(w, http.StatusConflict, "Conflict", "The owner's role cannot be changed.")
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE farm_member SET role = $3 WHERE farm_id = $1 AND akun_id = $2`, m.FarmID, akunID, req.Role)
+	}
+	if err == nil {
+		entry.After, err = audit.SnapshotWhere(tx, "farm_member", "farm_id = $1 AND akun_id = $2", m.FarmID, akunID)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to update farm member:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to update member.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Member role updated",
+		"data":    map[string]interface{}{"farm_id": m.FarmID, "akun_id": akunID, "role": req.Role},
+	})
+}
+
+// RemoveFarmMember mengeluarkan anggota dari peternakan. Anggota selain owner juga dapat
+// mengeluarkan dirinya sendiri.
+func RemoveFarmMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	akunID, _ := strconv.ParseInt(mux.Vars(r)["akun_id"], 10, 64)
+
+	var m farmaccess.Membership
+	if akunID == caller.ID {
+		m, err = farmaccess.ForRequest(sqlDB, r, caller.ID, farmaccess.ViewFarm)
+	} else {
+		m, err = farmaccess.ForRequest(sqlDB, r, caller.ID, farmaccess.ManageMembers)
+	}
+	if err != nil {
+		farmaccess.WriteError(w, err)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := audit.New(r, caller.Type, caller.ID, "farm_member.remove", "farm_member", strconv.FormatInt(m.FarmID, 10)+":"+strconv.FormatInt(akunID, 10))
+	entry.Before, err = audit.SnapshotWhere(tx, "farm_member", "farm_id = $1 AND akun_id = $2", m.FarmID, akunID)
+	if err == nil && entry.Before == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "Member not found.")
+		return
+	}
+	if err == nil && entry.Before["role"] == farmaccess.RoleOwner {
+		writeError(w, http.StatusConflict, "Conflict", "The owner cannot be removed from the farm.")
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM farm_member WHERE farm_id = $1 AND akun_id = $2`, m.FarmID, akunID)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to remove farm member:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to remove member.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Member removed",
+	})
+}
+
+// CreateFarmInvitation mengundang akun lewat nomor telepon login; email opsional dan hanya
+// dipakai untuk pemberitahuan karena email akun tidak diverifikasi.
+// Body: {"no_telp": "...", "email": "...", "role": "manager"|"packer"|"cashier"}.
+// Jika akun dengan nomor tersebut sudah terdaftar, akun itu langsung diberi notifikasi.
+func CreateFarmInvitation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, m, ok := memberFarm(w, r, sqlDB, farmaccess.ManageMembers)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Email  string `json:"email"`
+		NoTelp string `json:"no_telp"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", "The JSON request body could not be decoded.")
+		return
+	}
+	req.Email, req.NoTelp = strings.ToLower(strings.TrimSpace(req.Email)), strings.TrimSpace(req.NoTelp)
+	if req.NoTelp == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields", "Provide the phone number to invite.")
+		return
+	}
+	if !memberRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "Invalid role", "role must be manager, packer or cashier.")
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Akun terdaftar dengan nomor telepon undangan, jika ada
+	var inviteeID sql.NullInt64
+	var alreadyMember, pending bool
+	err = tx.QueryRow(`SELECT a.id_user,
+			EXISTS (SELECT 1 FROM farm_member m WHERE m.farm_id = $2 AND m.akun_id = a.id_user)
+		FROM akun a
+		WHERE a.no_telp = $1`, req.NoTelp, m.FarmID).Scan(&inviteeID, &alreadyMember)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	if err == nil {
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM farm_invitation
+			WHERE farm_id = $1 AND status = 'pending' AND expires_at > NOW()
+				AND no_telp = $2)`, m.FarmID, req.NoTelp).Scan(&pending)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to check invitee:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if alreadyMember {
+		writeError(w, http.StatusConflict, "Conflict", "This account is already a member of the farm.")
+		return
+	}
+	if pending {
+		writeError(w, http.StatusConflict, "Conflict", "An invitation for this contact is still pending.")
+		return
+	}
+
+	var inv farmInvitation
+	err = tx.QueryRow(`INSERT INTO farm_invitation (farm_id, role, email, no_telp, status, invited_by, expires_at, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), 'pending', $5, $6, NOW())
+		RETURNING id`, m.FarmID, req.Role, req.Email, req.NoTelp, caller.ID, time.Now().Add(config.FarmInvitationTTL)).Scan(&inv.ID)
+	if err == nil {
+		inv, err = scanInvitation(tx.QueryRow(`SELECT `+invitationColumns+`
+			FROM farm_invitation fi JOIN farms f ON f.id = fi.farm_id LEFT JOIN akun a ON a.id_user = fi.invited_by
+			WHERE fi.id = $1`, inv.ID))
+	}
+	if err == nil {
+		entry := audit.New(r, caller.Type, caller.ID, "farm_invitation.create", "farm_invitation", inv.ID)
+		if entry.After, err = audit.Snapshot(tx, "farm_invitation", "id", inv.ID); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	// Kontak tanpa akun tetap diberi tahu lewat email atau WhatsApp
+	if err == nil {
+		payload := events.FarmPayload{FarmID: m.FarmID, FarmName: inv.FarmName, Role: inv.Role}
+		if inviteeID.Valid {
+			payload.UserID = inviteeID.Int64
+		} else {
+			payload.InvitationID, payload.Email, payload.NoTelp = inv.ID, req.Email, req.NoTelp
+		}
+		err = events.Publish(tx, events.FarmInvited, "farm", m.FarmID, payload)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to create farm invitation:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to create invitation.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Invitation created",
+		"data":    inv,
+	})
+}
+
+// GetFarmInvitations menampilkan undangan peternakan pemanggil, terbaru lebih dulu
+func GetFarmInvitations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	_, m, ok := memberFarm(w, r, sqlDB, farmaccess.ManageMembers)
+	if !ok {
+		return
+	}
+
+	rows, err := sqlDB.Query(`SELECT `+invitationColumns+`
+		FROM farm_invitation fi JOIN farms f ON f.id = fi.farm_id LEFT JOIN akun a ON a.id_user = fi.invited_by
+		WHERE fi.farm_id = $1
+		ORDER BY fi.id DESC
+		LIMIT 100`, m.FarmID)
+	writeInvitations(w, rows, err)
+}
+
+// GetMyFarmInvitations menampilkan undangan yang masih berlaku untuk nomor telepon login pemanggil
+func GetMyFarmInvitations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
+	rows, err := sqlDB.Query(`SELECT `+invitationColumns+`
+		FROM farm_invitation fi
+		JOIN farms f ON f.id = fi.farm_id
+		LEFT JOIN akun a ON a.id_user = fi.invited_by
+		JOIN akun me ON me.id_user = $1
+		WHERE fi.status = 'pending' AND fi.expires_at > NOW()
+			AND fi.no_telp = me.no_telp
+		ORDER BY fi.id DESC`, caller.ID)
+	writeInvitations(w, rows, err)
+}
+
+func writeInvitations(w http.ResponseWriter, rows *sql.Rows, err error) {
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch farm invitations:", err)
+		http.Error(w, "Failed to fetch farm invitations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invitations := []farmInvitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			log.Println("[ERROR] Failed to scan farm invitation:", err)
+			http.Error(w, "Failed to fetch farm invitations", http.StatusInternalServerError)
+			return
+		}
+		invitations = append(invitations, inv)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Farm invitations retrieved successfully",
+		"data":    invitations,
+	})
+}
+
+// RevokeFarmInvitation membatalkan undangan yang belum dijawab
+func RevokeFarmInvitation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, m, ok := memberFarm(w, r, sqlDB, farmaccess.ManageMembers)
+	if !ok {
+		return
+	}
+	invID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := audit.New(r, caller.Type, caller.ID, "farm_invitation.revoke", "farm_invitation", invID)
+	entry.Before, err = audit.SnapshotWhere(tx, "farm_invitation", "id = $1 AND farm_id = $2 AND status = 'pending'", invID, m.FarmID)
+	if err == nil && entry.Before == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "No pending invitation found.")
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE farm_invitation SET status = 'revoked', responded_at = NOW() WHERE id = $1`, invID)
+	}
+	if err == nil {
+		entry.After, err = audit.Snapshot(tx, "farm_invitation", "id", invID)
+	}
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to revoke farm invitation:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to revoke invitation.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Invitation revoked",
+	})
+}
+
+// AcceptFarmInvitation menerima undangan dan menjadikan pemanggil anggota peternakan
+func AcceptFarmInvitation(w http.ResponseWriter, r *http.Request) {
+	respondFarmInvitation(w, r, true)
+}
+
+// DeclineFarmInvitation menolak undangan
+func DeclineFarmInvitation(w http.ResponseWriter, r *http.Request) {
+	respondFarmInvitation(w, r, false)
+}
+
+func respondFarmInvitation(w http.ResponseWriter, r *http.Request, accept bool) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	caller, ok := akunCaller(w, r, sqlDB)
+	if !ok {
+		return
+	}
+	invID, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Undangan hanya bisa dijawab oleh akun dengan nomor telepon yang diundang; email akun
+	// tidak diverifikasi sehingga tidak bisa dipakai sebagai bukti kepemilikan kontak
+	var farmID int64
+	var role, status string
+	var invitedBy sql.NullInt64
+	err = tx.QueryRow(`SELECT fi.farm_id, fi.role, fi.invited_by,
+			CASE WHEN fi.status = 'pending' AND fi.expires_at <= NOW() THEN 'expired' ELSE fi.status END
+		FROM farm_invitation fi JOIN akun me ON me.id_user = $2
+		WHERE fi.id = $1 AND fi.no_telp = me.no_telp
+		FOR UPDATE OF fi`, invID, caller.ID).Scan(&farmID, &role, &invitedBy, &status)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "Not Found", "Invitation not found.")
+		return
+	}
+	if err == nil && status != "pending" {
+		writeError(w, http.StatusConflict, "Conflict", "This invitation is already "+status+".")
+		return
+	}
+
+	newStatus := "declined"
+	if err == nil && accept {
+		newStatus = "accepted"
+		var current string
+		err = tx.QueryRow(`SELECT role FROM farm_member WHERE farm_id = $1 AND akun_id = $2`, farmID, caller.ID).Scan(&current)
+		if err == nil && current == farmaccess.RoleOwner {
+			writeError(w, http.StatusConflict, "Conflict", "You already own this farm.")
+			return
+		}
+		if err == nil || err == sql.ErrNoRows {
+			entry := audit.New(r, caller.Type, caller.ID, "farm_member.add", "farm_member", strconv.FormatInt(farmID, 10)+":"+strconv.FormatInt(caller.ID, 10))
+			entry.Before, err = audit.SnapshotWhere(tx, "farm_member", "farm_id = $1 AND akun_id = $2", farmID, caller.ID)
+			if err == nil {
+				err = farmaccess.AddMember(tx, farmID, caller.ID, role, invitedBy.Int64)
+			}
+			if err == nil {
+				entry.After, err = audit.SnapshotWhere(tx, "farm_member", "farm_id = $1 AND akun_id = $2", farmID, caller.ID)
+			}
+			if err == nil {
+				err = audit.Record(tx, entry)
+			}
+		}
+	}
+	if err == nil {
+		var acceptedBy interface{}
+		if accept {
+			acceptedBy = caller.ID
+		}
+		_, err = tx.Exec(`UPDATE farm_invitation SET status = $2, accepted_by = $3, responded_at = NOW() WHERE id = $1`,
+			invID, newStatus, acceptedBy)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to respond to farm invitation:", err)
+		writeError(w, http.StatusInternalServerError, "Database error", "Failed to respond to invitation.")
+		return
+	}
+
+	data := map[string]interface{}{"id": invID, "status": newStatus}
+	if accept {
+		data["farm_id"], data["role"] = farmID, role
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Invitation " + newStatus,
+		"data":    data,
+	})
+}
@@ -1,19 +1,37 @@
 package peternakan
 
 import (
+	"database/sql"
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/farmaccess"
 	"farmdistribution_be/helper/ghupload"
 	"farmdistribution_be/helper/watoken"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// defaultFarmCode adalah kode bawaan peternakan: F0001, F0002, dst. sesuai id-nya
+func defaultFarmCode(farmID int64) string {
+	return fmt.Sprintf("F%04d", farmID)
+}
+
+// reservedFarmCode cocok dengan bentuk kode bawaan; bentuk ini tidak boleh dipilih manual
+// agar tidak bentrok dengan kode peternakan yang dibuat kemudian
+var reservedFarmCode = regexp.MustCompile(`^F[0-9]+$`)
+
+// assignFarmCode memberi peternakan baru kode bawaan. Kode dapat diganti pemilik lewat UpdatePeternakan.
+func assignFarmCode(tx *sql.Tx, farmID int64) error {
+	_, err := tx.Exec(`UPDATE farms SET code = $2 WHERE id = $1 AND code IS NULL`, farmID, defaultFarmCode(farmID))
+	return err
+}
+
 func CreatePeternakan(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -32,9 +50,14 @@ func CreatePeternakan(w http.ResponseWriter, r *http.Request) {
 	}
 	noTelp := payload.Id
 
+	// Hanya peternak (role hasil persetujuan pengajuan) atau owner peternakan lain yang boleh
+	// membuat peternakan langsung; akun lain harus lewat SubmitFarmApplication
 	var ownerID int64
-	query := `SELECT id_user FROM akun WHERE no_telp = $1`
-	err = sqlDB.QueryRow(query, noTelp).Scan(&ownerID)
+	var roleID int
+	var isOwner bool
+	query := `SELECT a.id_user, COALESCE(a.id_role, 0), EXISTS (SELECT 1 FROM farm_member fm WHERE fm.akun_id = a.id_user AND fm.role = $2)
+		FROM akun a WHERE a.no_telp = $1`
+	err = sqlDB.QueryRow(query, noTelp, farmaccess.RoleOwner).Scan(&ownerID, &roleID, &isOwner)
 	if err != nil {
 		log.Println("[ERROR] Failed to find owner ID:", err)
 		w.WriteHeader(http.StatusNotFound)
@@ -44,6 +67,14 @@ func CreatePeternakan(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if roleID != config.FarmerRoleID && !isOwner {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only farmers can create a farm. Submit a farm application first.",
+		})
+		return
+	}
 	log.Println("[INFO] Owner ID found:", ownerID)
 
 	err = r.ParseMultipartForm(10 << 20)
@@ -148,8 +179,20 @@ INSERT INTO addressfarm (street, city, state, postal_code, country)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING id_addressfarm;`
 
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to insert farm data into the database.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	var addressFarmID int
-	err = sqlDB.QueryRow(queryAddressFarm, street, city, state, postalCode, country).Scan(&addressFarmID)
+	err = tx.QueryRow(queryAddressFarm, street, city, state, postalCode, country).Scan(&addressFarmID)
 	if err != nil {
 		log.Println("[ERROR] Failed to insert data into addressfarm:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -167,7 +210,7 @@ VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326), $7, $8, $9, $10,
 RETURNING id;`
 
 	var farmID int
-	err = sqlDB.QueryRow(queryFarms,
+	err = tx.QueryRow(queryFarms,
 		name,
 		ownerID,
 		farmType,
@@ -180,6 +223,16 @@ RETURNING id;`
 		description,
 	).Scan(&farmID)
 
+	if err == nil {
+		err = assignFarmCode(tx, int64(farmID))
+	}
+	// Pembuat peternakan menjadi anggota dengan role owner
+	if err == nil {
+		err = farmaccess.AddMember(tx, int64(farmID), ownerID, farmaccess.RoleOwner, 0)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Println("[ERROR] Failed to insert data into farms:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -194,6 +247,7 @@ RETURNING id;`
 	response := map[string]interface{}{
 		"message": "Farm successfully created.",
 		"data": map[string]interface{}{
+			"id":          farmID,
 			"owner_id":    ownerID,
 			"name":        name,
 			"farm_type":   farmType,
@@ -248,13 +302,15 @@ func GetPeternakan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query untuk mendapatkan data peternakan
+	// Query untuk mendapatkan data peternakan tempat pemanggil menjadi anggota
 	queryFarms := `
 	SELECT f.id, f.name, f.farm_type, af.street, af.city, af.state, af.postal_code, af.country,
-	       ST_X(f.location) AS latitude, ST_Y(f.location) AS longitude, f.image_farm, f.phonenumber_farm, f.email, f.description
+	       ST_X(f.location) AS latitude, ST_Y(f.location) AS longitude, f.image_farm, f.phonenumber_farm, f.email, f.description, m.role
 	FROM farms f
+	JOIN farm_member m ON m.farm_id = f.id
 	LEFT JOIN addressfarm af ON f.addressfarm_id = af.id_addressfarm
-	WHERE f.owner_id = $1`
+	WHERE m.akun_id = $1
+	ORDER BY (m.role = 'owner') DESC, m.created_at, f.id`
 
 	rows, err := sqlDB.Query(queryFarms, ownerID)
 	if err != nil {
@@ -270,13 +326,13 @@ func GetPeternakan(w http.ResponseWriter, r *http.Request) {
 	var farms []map[string]interface{}
 	for rows.Next() {
 		farm := make(map[string]interface{})
-		var id, name, farmType, street, city, state, postalCode, country, imageFarm, phonenumberFarm, email, description string
+		var id, name, farmType, street, city, state, postalCode, country, imageFarm, phonenumberFarm, email, description, role string
 		var latitude, longitude float64
 
 		err = rows.Scan(
 			&id, &name, &farmType, &street, &city,
 			&state, &postalCode, &country, &latitude, &longitude,
-			&imageFarm, &phonenumberFarm, &email, &description,
+			&imageFarm, &phonenumberFarm, &email, &description, &role,
 		)
 
 		farm["id"] = id
@@ -291,6 +347,7 @@ func GetPeternakan(w http.ResponseWriter, r *http.Request) {
 		farm["phonenumber_farm"] = phonenumberFarm
 		farm["email"] = email
 		farm["description"] = description
+		farm["role"] = role
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -344,18 +401,6 @@ func UpdatePeternakan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var farmId int
-	queryFarm := `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(queryFarm, ownerID).Scan(&farmId)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Not Found",
-			"message": "No farm found for the given owner.",
-		})
-		return
-	}
-
 	// Parse body JSON
 	var updateData struct {
 		ID          int     `json:"id"`
@@ -371,6 +416,9 @@ func UpdatePeternakan(w http.ResponseWriter, r *http.Request) {
 		Country     string  `json:"country"`
 		Latitude    float64 `json:"lat"`
 		Longitude   float64 `json:"lon"`
+		Code        string  `json:"code"` // Kode peternakan pada nomor invoice
+		// Batas waktu pembayaran invoice dalam jam, 0 berarti tidak diubah
+		PaymentWindowHours int `json:"payment_window_hours"`
 	}
 
 	err = json.NewDecoder(r.Body).Decode(&updateData)
@@ -384,20 +432,61 @@ func UpdatePeternakan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validasi kepemilikan peternakan
-	var farmExists bool
-	queryFarm = `SELECT EXISTS (SELECT 1 FROM farms WHERE id = $1 AND owner_id = $2)`
-	err = sqlDB.QueryRow(queryFarm, farmId, ownerID).Scan(&farmExists)
-	if err != nil || !farmExists {
-		w.WriteHeader(http.StatusNotFound)
+	if updateData.PaymentWindowHours < 0 || updateData.PaymentWindowHours > 24*30 {
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Unauthorized",
-			"message": "You are not authorized to update this farm.",
+			"error":   "Invalid payment window",
+			"message": "payment_window_hours must be between 1 and 720.",
 		})
-		fmt.Printf("Failed to find farm with ID %d for owner %d: %v\n", farmId, ownerID, err)
 		return
 	}
 
+	// Validasi keanggotaan pada peternakan yang diubah; tanpa id berarti peternakan yang dipilih
+	// lewat X-Farm-ID atau farm_id, atau satu-satunya peternakan pemanggil
+	if updateData.ID == 0 {
+		membership, err := farmaccess.ForRequest(sqlDB, r, int64(ownerID), farmaccess.ManageFarm)
+		if err != nil {
+			farmaccess.WriteError(w, err)
+			return
+		}
+		updateData.ID = int(membership.FarmID)
+	} else if _, err := farmaccess.Check(sqlDB, int64(ownerID), int64(updateData.ID), farmaccess.ManageFarm); err != nil {
+		farmaccess.WriteError(w, err)
+		return
+	}
+
+	// Kode dipakai pada nomor invoice; harus unik dan bukan bentuk kode bawaan peternakan lain
+	updateData.Code = strings.ToUpper(strings.TrimSpace(updateData.Code))
+	if updateData.Code != "" {
+		if reservedFarmCode.MatchString(updateData.Code) && updateData.Code != defaultFarmCode(int64(updateData.ID)) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Invalid code",
+				"message": "Codes of the form F0001 are reserved for generated farm codes.",
+			})
+			return
+		}
+		var taken bool
+		err = sqlDB.QueryRow(`SELECT EXISTS (SELECT 1 FROM farms WHERE code = $1 AND id <> $2)`, updateData.Code, updateData.ID).Scan(&taken)
+		if err != nil {
+			log.Printf("[ERROR] Failed to check farm code: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Database error",
+				"message": "Failed to check farm code.",
+			})
+			return
+		}
+		if taken {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Code already used",
+				"message": "Another farm already uses this code.",
+			})
+			return
+		}
+	}
+
 	// Update address data
 	queryUpdateAddress := `
 		UPDATE addressfarm
@@ -418,10 +507,13 @@ func UpdatePeternakan(w http.ResponseWriter, r *http.Request) {
 	// Update farm data
 	queryUpdateFarm := `
 		UPDATE farms
-		SET name = $1, farm_type = $2, phonenumber_farm = $3, email = $4, description = $5, location = ST_SetSRID(ST_MakePoint($6, $7), 4326)
+		SET name = $1, farm_type = $2, phonenumber_farm = $3, email = $4, description = $5, location = ST_SetSRID(ST_MakePoint($6, $7), 4326),
+			code = COALESCE(NULLIF($9, ''), code),
+			payment_window_hours = COALESCE(NULLIF($10, 0), payment_window_hours)
 		WHERE id = $8`
 
-	_, err = sqlDB.Exec(queryUpdateFarm, updateData.Name, updateData.FarmType, updateData.Phonenumber, updateData.Email, updateData.Description, updateData.Longitude, updateData.Latitude, updateData.ID)
+	_, err = sqlDB.Exec(queryUpdateFarm, updateData.Name, updateData.FarmType, updateData.Phonenumber, updateData.Email, updateData.Description, updateData.Longitude, updateData.Latitude, updateData.ID,
+		updateData.Code, updateData.PaymentWindowHours)
 	if err != nil {
 		log.Printf("[ERROR] Failed to update farm: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -465,19 +557,19 @@ func DeletePeternakan(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
-	// payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
-	// if err != nil {
-	// 	w.WriteHeader(http.StatusUnauthorized)
-	// 	json.NewEncoder(w).Encode(map[string]string{
-	// 		"error":   "Unauthorized",
-	// 		"message": "Invalid or expired token. Please log in again.",
-	// 	})
-	// 	return
-	// }
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return
+	}
 
 	// Extract farm ID from query parameters
-	farmID := r.URL.Query().Get("id")
-	if farmID == "" {
+	farmID, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if farmID == 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Missing farm ID",
@@ -486,6 +578,12 @@ func DeletePeternakan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hanya owner yang boleh menghapus peternakan
+	if _, err := farmaccess.CheckPhone(sqlDB, payload.Id, farmID, farmaccess.ManageFarm); err != nil {
+		farmaccess.WriteError(w, err)
+		return
+	}
+
 	// Delete farm data
 	queryDeleteFarm := `DELETE FROM farms WHERE id = $1`
 	_, err = sqlDB.Exec(queryDeleteFarm, farmID)
@@ -5,8 +5,12 @@ import (
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
 	"farmdistribution_be/helper/atdb"
+	"farmdistribution_be/helper/farmaccess"
 	"farmdistribution_be/helper/format"
 	"farmdistribution_be/helper/ghupload"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/inventory"
+	"farmdistribution_be/helper/qty"
 	"farmdistribution_be/helper/watoken"
 	"fmt"
 	"io"
@@ -49,17 +53,12 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Cari Farm ID
-	var farmId int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ManageProducts)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given owner ID.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmId := int(membership.FarmID)
 
 	// Parse Form Data
 	err = r.ParseMultipartForm(10 << 20)
@@ -148,9 +147,42 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	description := r.FormValue("description")
 	pricePerKg, _ := strconv.ParseFloat(r.FormValue("price_per_kg"), 64)
 	weightPerKg, _ := strconv.ParseFloat(r.FormValue("weight_per_kg"), 64)
-	stockKg, _ := strconv.ParseFloat(r.FormValue("stock_kg"), 64)
 	statusName := r.FormValue("status_name")
 
+	// Stok awal dicatat sebagai restock di ledger persediaan
+	var stockKg qty.Qty
+	if v := r.FormValue("stock_kg"); v != "" {
+		stockKg, err = qty.Parse(v)
+		if err != nil || stockKg < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "Invalid stock",
+				"message": "stock_kg must be a non-negative number with at most three decimals.",
+			})
+			return
+		}
+	}
+
+	unit, err := parseSaleUnit(r, defaultSaleUnit)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid sale unit",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	categoryID, _, msg := parseProductCategory(r, sqlDB)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid category",
+			"message": msg,
+		})
+		return
+	}
+
 	inputDate := r.FormValue("available_date")
 	var formattedDate *string
 	if inputDate != "" {
@@ -180,9 +212,32 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert Farm Product
-	query = `INSERT INTO farm_products (name, description, price_per_kg, weight_per_unit, farm_id, status_id, image_url, stock_kg) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	productID, err := atdb.InsertOne(sqlDB, query, productName, description, pricePerKg, weightPerKg, farmId, statusID, imageURL, stockKg)
+	tx, err := sqlDB.Begin()
 	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var productID int64
+	query = `INSERT INTO farm_products (name, description, price_per_kg, weight_per_unit, farm_id, status_id, image_url, stock_kg, sale_unit, stock_per_unit, price_per_unit, min_qty, step_qty, catch_weight, weight_tolerance_percent, low_stock_threshold_kg, category_id) VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`
+	err = tx.QueryRow(query, productName, description, pricePerKg, weightPerKg, farmId, statusID, imageURL, unit.SaleUnit, unit.StockPerUnit, unit.PricePerUnit, unit.MinQty, unit.StepQty, unit.CatchWeight, unit.WeightTolerance, unit.LowStockKg, categoryID).Scan(&productID)
+	if err == nil && stockKg > 0 {
+		_, err = inventory.Move(tx, inventory.Movement{
+			ProductID:  productID,
+			Type:       inventory.Restock,
+			QuantityKg: stockKg,
+			Reason:     "Initial stock",
+			ActorType:  identity.TypeAkun,
+			ActorID:    ownerID,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to insert product:", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Database error",
@@ -202,136 +257,6 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func GetAllProduct(w http.ResponseWriter, r *http.Request) {
-	sqlDB, err := config.PostgresDB.DB()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Query untuk mengambil semua produk
-	query := `
-		SELECT 
-			fp.id, 
-			fp.name, 
-			fp.description, 
-			fp.price_per_kg, 
-			fp.weight_per_unit, 
-			fp.image_url, 
-			fp.stock_kg, 
-			fp.created_at, 
-			fp.updated_at, 
-			fp.farm_id, 
-			sp.name AS status_name, 
-			sp.available_date
-		FROM 
-			farm_products fp
-		LEFT JOIN 
-			status_product sp 
-		ON 
-			fp.status_id = sp.id
-	`
-
-	// Eksekusi query
-	rows, err := sqlDB.Query(query)
-	if err != nil {
-		log.Printf("[ERROR] Failed to fetch products: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to fetch products.",
-		})
-		return
-	}
-	defer rows.Close()
-
-	// Struct untuk menyimpan produk
-	type Product struct {
-		ID            int64      `json:"id"`
-		Name          string     `json:"name"`
-		Description   string     `json:"description"`
-		PricePerKg    string     `json:"price_per_kg"`
-		WeightPerUnit float64    `json:"weight_per_unit"`
-		ImageURL      string     `json:"image_url"`
-		StockKg       float64    `json:"stock_kg"`
-		CreatedAt     time.Time  `json:"created_at"`
-		UpdatedAt     time.Time  `json:"updated_at"`
-		FarmID        int64      `json:"farm_id"`
-		StatusName    string     `json:"status_name"`
-		AvailableDate *time.Time `json:"available_date"`
-	}
-
-	// Menampung semua produk
-	var products []Product
-
-	// Iterasi hasil query
-	for rows.Next() {
-		var product Product
-		err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.PricePerKg,
-			&product.WeightPerUnit,
-			&product.ImageURL,
-			&product.StockKg,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-			&product.FarmID,
-			&product.StatusName,
-			&product.AvailableDate,
-		)
-		if err != nil {
-			log.Printf("[ERROR] Failed to scan product row: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "Database error",
-				"message": "Failed to process products.",
-			})
-			return
-		}
-
-		pricePerKgFloat, err := strconv.ParseFloat(product.PricePerKg, 64)
-		if err != nil {
-			log.Printf("[ERROR] Failed to parse price_per_kg: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "Data error",
-				"message": "Failed to parse product price.",
-			})
-			return
-		}
-		product.PricePerKg = format.FormatCurrency(pricePerKgFloat)
-
-		// Konversi URL gambar menjadi format raw jika diperlukan
-		if strings.Contains(product.ImageURL, "https://github.com/") {
-			rawBaseURL := "https://raw.githubusercontent.com"
-			repoPath := "Ayala-crea/productImages/refs/heads/"
-			imagePath := strings.TrimPrefix(product.ImageURL, "https://github.com/Ayala-crea/productImages/blob/")
-			product.ImageURL = fmt.Sprintf("%s/%s%s", rawBaseURL, repoPath, imagePath)
-		}
-
-		products = append(products, product)
-	}
-
-	// Cek jika tidak ada produk
-	if len(products) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "No products found",
-			"message": "There are no products available.",
-		})
-		return
-	}
-
-	// Response JSON
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "success",
-		"message": "Products retrieved successfully.",
-		"data":    products,
-	})
-}
-
 func GetAllProdcutPeternak(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
@@ -360,17 +285,12 @@ func GetAllProdcutPeternak(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var farmID int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmID)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ViewFarm)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given user.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmID := int(membership.FarmID)
 
 	query = `SELECT 
     fp.id AS product_id,
@@ -511,17 +431,12 @@ func EditProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Cari Farm ID
-	var farmID int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmID)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ManageProducts)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given user.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmID := int(membership.FarmID)
 
 	// Ambil ID produk dari URL
 	id := r.URL.Query().Get("id")
@@ -540,12 +455,14 @@ func EditProduct(w http.ResponseWriter, r *http.Request) {
 		Description string
 		PricePerKg  float64
 		WeightPerKg float64
-		StockKg     float64
 		StatusID    int
 		ImageURL    string
+		Unit        saleUnit
 	}
-	query = `SELECT name, description, price_per_kg, weight_per_unit, stock_kg, status_id, image_url FROM farm_products WHERE id = $1 AND farm_id = $2`
-	err = sqlDB.QueryRow(query, id, farmID).Scan(&currentProduct.Name, &currentProduct.Description, &currentProduct.PricePerKg, &currentProduct.WeightPerKg, &currentProduct.StockKg, &currentProduct.StatusID, &currentProduct.ImageURL)
+	query = `SELECT name, description, price_per_kg, weight_per_unit, status_id, image_url, sale_unit, stock_per_unit, price_per_unit, min_qty, step_qty, catch_weight, weight_tolerance_percent, low_stock_threshold_kg FROM farm_products WHERE id = $1 AND farm_id = $2`
+	err = sqlDB.QueryRow(query, id, farmID).Scan(&currentProduct.Name, &currentProduct.Description, &currentProduct.PricePerKg, &currentProduct.WeightPerKg, &currentProduct.StatusID, &currentProduct.ImageURL,
+		&currentProduct.Unit.SaleUnit, &currentProduct.Unit.StockPerUnit, &currentProduct.Unit.PricePerUnit, &currentProduct.Unit.MinQty, &currentProduct.Unit.StepQty,
+		&currentProduct.Unit.CatchWeight, &currentProduct.Unit.WeightTolerance, &currentProduct.Unit.LowStockKg)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -606,9 +523,16 @@ func EditProduct(w http.ResponseWriter, r *http.Request) {
 		weightPerKg = currentProduct.WeightPerKg
 	}
 
-	stockKg, err := strconv.ParseFloat(r.FormValue("stock_kg"), 64)
-	if err != nil || r.FormValue("stock_kg") == "" {
-		stockKg = currentProduct.StockKg
+	// stock_kg adalah stok hasil hitung fisik; selisihnya dicatat di ledger dengan jenis
+	// stock_movement (restock, spoilage atau adjustment) dan alasan stock_reason
+	stock, msg := parseStockChange(r)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid stock",
+			"message": msg,
+		})
+		return
 	}
 
 	nameStatus := r.FormValue("status_name")
@@ -616,6 +540,27 @@ func EditProduct(w http.ResponseWriter, r *http.Request) {
 		nameStatus = Status.Name
 	}
 
+	unit, err := parseSaleUnit(r, currentProduct.Unit)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid sale unit",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// category_id kosong berarti kategori tidak diubah, 0 menghapus kategori
+	categoryID, categorySet, msg := parseProductCategory(r, sqlDB)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid category",
+			"message": msg,
+		})
+		return
+	}
+
 	inputDate := r.FormValue("available_date")
 	var formattedDate *string
 	if inputDate != "" {
@@ -711,14 +656,44 @@ func EditProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update produk di database
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("[ERROR] Failed to start transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Update produk di database; stok hanya berubah melalui ledger
 	query = `
         UPDATE farm_products
-        SET name = $1, description = $2, price_per_kg = $3, weight_per_unit = $4, status_id = $5, image_url = $6, stock_kg = $7, updated_at = NOW()
-        WHERE id = $8 AND farm_id = $9
+        SET name = $1, description = $2, price_per_kg = $3, weight_per_unit = $4, status_id = $5, image_url = $6,
+            sale_unit = $9, stock_per_unit = $10, price_per_unit = $11, min_qty = $12, step_qty = $13,
+            catch_weight = $14, weight_tolerance_percent = $15, low_stock_threshold_kg = $16,
+            category_id = CASE WHEN $17 THEN $18 ELSE category_id END, updated_at = NOW()
+        WHERE id = $7 AND farm_id = $8
     `
-	_, err = sqlDB.Exec(query, productName, description, pricePerKg, weightPerKg, currentProduct.StatusID, imageURL, stockKg, id, farmID)
+	_, err = tx.Exec(query, productName, description, pricePerKg, weightPerKg, currentProduct.StatusID, imageURL, id, farmID,
+		unit.SaleUnit, unit.StockPerUnit, unit.PricePerUnit, unit.MinQty, unit.StepQty, unit.CatchWeight, unit.WeightTolerance, unit.LowStockKg,
+		categorySet, categoryID)
+	var stockMsg string
+	if err == nil && stock.set {
+		productID, _ := strconv.ParseInt(id, 10, 64)
+		stockMsg, err = stock.apply(tx, productID, ownerID)
+	}
+	if err == nil && stockMsg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid stock",
+			"message": stockMsg,
+		})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
+		log.Println("[ERROR] Failed to update product:", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Database error",
@@ -765,17 +740,12 @@ func GetProductById(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Cari Farm ID
-	var farmID int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmID)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ViewFarm)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given user.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmID := int(membership.FarmID)
 
 	// Ambil Product ID dari URL
 	id := r.URL.Query().Get("id")
@@ -802,7 +772,12 @@ func GetProductById(w http.ResponseWriter, r *http.Request) {
 			fp.updated_at, 
 			fp.farm_id, 
 			sp.name AS status_name, 
-			sp.available_date
+			sp.available_date,
+			fp.sale_unit,
+			fp.stock_per_unit,
+			fp.price_per_unit,
+			fp.min_qty,
+			fp.step_qty
 		FROM 
 			farm_products fp
 		LEFT JOIN 
@@ -830,6 +805,7 @@ func GetProductById(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var product Product
+	var unit saleUnit
 
 	// Eksekusi query
 	err = sqlDB.QueryRow(query, id, farmID).Scan(
@@ -845,6 +821,11 @@ func GetProductById(w http.ResponseWriter, r *http.Request) {
 		&product.FarmID,
 		&product.StatusName,
 		&product.AvailableDate,
+		&unit.SaleUnit,
+		&unit.StockPerUnit,
+		&unit.PricePerUnit,
+		&unit.MinQty,
+		&unit.StepQty,
 	)
 
 	if err != nil {
@@ -897,6 +878,11 @@ func GetProductById(w http.ResponseWriter, r *http.Request) {
 			"farm_id":         product.FarmID,
 			"status_name":     product.StatusName,
 			"available_date":  formattedDate,
+			"sale_unit":       unit.SaleUnit,
+			"stock_per_unit":  unit.StockPerUnit,
+			"price_per_unit":  format.FormatCurrency(unit.unitPrice(product.PricePerKg)) + "0",
+			"min_qty":         unit.MinQty,
+			"step_qty":        unit.StepQty,
 		},
 	}
 	w.WriteHeader(http.StatusOK)
@@ -935,17 +921,12 @@ func DeleteProduk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Cari Farm ID
-	var farmId int
-	query = `SELECT id FROM farms WHERE owner_id = $1`
-	err = sqlDB.QueryRow(query, ownerID).Scan(&farmId)
+	membership, err := farmaccess.ForRequest(sqlDB, r, ownerID, farmaccess.ManageProducts)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Farm not found",
-			"message": "No farm found for the given owner ID.",
-		})
+		farmaccess.WriteError(w, err)
 		return
 	}
+	farmId := int(membership.FarmID)
 
 	// Ambil Product ID dari URL
 	id := r.URL.Query().Get("id")
@@ -1052,7 +1033,8 @@ func GetAllProductsByFarm(w http.ResponseWriter, r *http.Request) {
 		SELECT 
 			fp.id, fp.name, fp.description, fp.price_per_kg, 
 			fp.weight_per_unit, fp.image_url, fp.stock_kg, 
-			sp.name AS status_name, sp.available_date
+			sp.name AS status_name, sp.available_date,
+			fp.sale_unit, fp.stock_per_unit, fp.price_per_unit, fp.min_qty, fp.step_qty
 		FROM 
 			farm_products fp
 		JOIN 
@@ -1085,6 +1067,7 @@ func GetAllProductsByFarm(w http.ResponseWriter, r *http.Request) {
 			stockKg       float64
 			statusName    string
 			availableDate *string
+			unit          saleUnit
 		)
 
 		err = rows.Scan(
@@ -1097,6 +1080,11 @@ func GetAllProductsByFarm(w http.ResponseWriter, r *http.Request) {
 			&stockKg,
 			&statusName,
 			&availableDate,
+			&unit.SaleUnit,
+			&unit.StockPerUnit,
+			&unit.PricePerUnit,
+			&unit.MinQty,
+			&unit.StepQty,
 		)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -1117,6 +1105,11 @@ func GetAllProductsByFarm(w http.ResponseWriter, r *http.Request) {
 			"stock_kg":        stockKg,
 			"status_name":     statusName,
 			"available_date":  availableDate,
+			"sale_unit":       unit.SaleUnit,
+			"stock_per_unit":  unit.StockPerUnit,
+			"price_per_unit":  format.FormatCurrency(unit.unitPrice(pricePerKg)) + "0",
+			"min_qty":         unit.MinQty,
+			"step_qty":        unit.StepQty,
 		}
 		products = append(products, product)
 	}
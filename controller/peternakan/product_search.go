@@ -0,0 +1,462 @@
+package peternakan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/category"
+	"farmdistribution_be/helper/format"
+	"farmdistribution_be/helper/listquery"
+	"farmdistribution_be/helper/qty"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unitPriceSQL adalah harga per satuan jual, sama dengan saleUnit.unitPrice
+const unitPriceSQL = `COALESCE(fp.price_per_unit, fp.price_per_kg * fp.stock_per_unit)`
+
+// productSort adalah urutan yang didukung GetAllProduct. key tidak boleh NULL dan berupa angka
+// yang bisa di-cast kembali dari teks dengan cast agar kursor dapat dibandingkan.
+type productSort struct {
+	key  string
+	cast string
+	desc bool
+}
+
+// productFilter adalah satu kondisi atas farm_products fp dengan placeholder "?"
+type productFilter struct {
+	name string
+	sql  string
+	args []interface{}
+}
+
+type productSearch struct {
+	filters   []productFilter
+	query     string // teks pencarian, kosong jika tidak ada
+	hasPoint  bool
+	lat, lon  float64
+	sort      string
+	limit     int
+	hasCursor bool
+	cursorKey string
+	cursorID  int64
+}
+
+// where menyusun kondisi filter kecuali filter bernama except, melanjutkan nomor placeholder dari args
+func (ps *productSearch) where(except string, args []interface{}) (string, []interface{}) {
+	conds := []string{"TRUE"}
+	for _, f := range ps.filters {
+		if f.name == except {
+			continue
+		}
+		cond := f.sql
+		for _, a := range f.args {
+			args = append(args, a)
+			cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1)
+		}
+		conds = append(conds, cond)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (ps *productSearch) add(name, cond string, args ...interface{}) {
+	ps.filters = append(ps.filters, productFilter{name: name, sql: cond, args: args})
+}
+
+// pointSQL adalah titik lokasi pembeli sebagai geography dengan placeholder "?" untuk lon dan lat
+const pointSQL = `ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography`
+
+func (ps *productSearch) sorts() map[string]productSort {
+	sorts := map[string]productSort{
+		// Seperti listquery untuk field Time: produk tanpa created_at dianggap paling lama
+		"newest":     {key: `COALESCE(EXTRACT(EPOCH FROM fp.created_at), 0)::float8`, cast: "float8", desc: true},
+		"price_asc":  {key: `COALESCE(` + unitPriceSQL + `, 0)`, cast: "numeric"},
+		"price_desc": {key: `COALESCE(` + unitPriceSQL + `, 0)`, cast: "numeric", desc: true},
+	}
+	if ps.hasPoint {
+		// Peternakan tanpa lokasi diletakkan paling akhir
+		sorts["distance"] = productSort{key: `COALESCE(ST_Distance(f.location::geography, ` + pointSQL + `), 'Infinity')`, cast: "float8"}
+	}
+	if ps.query != "" {
+		sorts["relevance"] = productSort{key: `ts_rank(fp.search_vector, websearch_to_tsquery('indonesian', ?))::float8`, cast: "float8", desc: true}
+	}
+	return sorts
+}
+
+// sortArgs mengembalikan nilai placeholder pada key urutan
+func (ps *productSearch) sortArgs() []interface{} {
+	switch ps.sort {
+	case "distance":
+		return []interface{}{ps.lon, ps.lat}
+	case "relevance":
+		return []interface{}{ps.query}
+	}
+	return nil
+}
+
+// parseProductSearch membaca parameter pencarian produk; error berisi pesan untuk klien
+func parseProductSearch(r *http.Request) (*productSearch, error) {
+	q := r.URL.Query()
+	ps := &productSearch{limit: 20}
+
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		ps.query = v
+		ps.add("q", `fp.search_vector @@ websearch_to_tsquery('indonesian', ?)`, v)
+	}
+
+	for _, p := range []struct{ name, op string }{{"min_price", ">="}, {"max_price", "<="}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number", p.name)
+		}
+		ps.add("price", unitPriceSQL+" "+p.op+" ?", price)
+	}
+
+	categoryID, err := parseCategoryParam(r)
+	if err != nil {
+		return nil, errors.New("category_id must be a category ID")
+	}
+	if categoryID != 0 {
+		ps.add("category", `fp.category_id IN `+category.Subtree("?"), categoryID)
+	}
+
+	if v := q.Get("farm_id"); v != "" {
+		farmID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("farm_id must be a farm ID")
+		}
+		ps.add("farm", `fp.farm_id = ?`, farmID)
+	}
+
+	if v := strings.TrimSpace(q.Get("status")); v != "" {
+		ps.add("status", `EXISTS (SELECT 1 FROM status_product sp2 WHERE sp2.id = fp.status_id AND LOWER(sp2.name) = LOWER(?))`, v)
+	}
+
+	// Stok cukup untuk pembelian minimum
+	if v := q.Get("in_stock"); v == "true" || v == "1" {
+		ps.add("in_stock", `fp.stock_kg >= fp.min_qty * fp.stock_per_unit`)
+	}
+
+	if q.Get("lat") != "" || q.Get("lon") != "" {
+		ps.lat, err = strconv.ParseFloat(q.Get("lat"), 64)
+		if err != nil || ps.lat < -90 || ps.lat > 90 {
+			return nil, errors.New("lat must be between -90 and 90")
+		}
+		ps.lon, err = strconv.ParseFloat(q.Get("lon"), 64)
+		if err != nil || ps.lon < -180 || ps.lon > 180 {
+			return nil, errors.New("lon must be between -180 and 180")
+		}
+		ps.hasPoint = true
+	}
+	if v := q.Get("radius"); v != "" {
+		radiusKm, err := strconv.ParseFloat(v, 64)
+		if err != nil || radiusKm <= 0 {
+			return nil, errors.New("radius must be a positive number of kilometers")
+		}
+		if !ps.hasPoint {
+			return nil, errors.New("radius requires lat and lon")
+		}
+		ps.add("radius", `fp.farm_id IN (SELECT id FROM farms WHERE ST_DWithin(location::geography, `+pointSQL+`, ?))`,
+			ps.lon, ps.lat, radiusKm*1000)
+	}
+
+	ps.sort = q.Get("sort")
+	if ps.sort == "" {
+		ps.sort = "newest"
+		if ps.query != "" {
+			ps.sort = "relevance"
+		}
+	}
+	if _, ok := ps.sorts()[ps.sort]; !ok {
+		switch ps.sort {
+		case "distance":
+			return nil, errors.New("sort=distance requires lat and lon")
+		case "relevance":
+			return nil, errors.New("sort=relevance requires q")
+		}
+		return nil, errors.New("sort must be newest, price_asc, price_desc, distance or relevance")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		ps.limit, err = strconv.Atoi(v)
+		if err != nil || ps.limit < 1 || ps.limit > 100 {
+			return nil, errors.New("limit must be between 1 and 100")
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		var sortName string
+		sortName, ps.cursorKey, ps.cursorID, err = listquery.DecodeCursor(v)
+		if err == nil && sortName != ps.sort {
+			err = errors.New("cursor belongs to another sort")
+		}
+		if err == nil {
+			// Semua key urutan berupa angka
+			_, err = strconv.ParseFloat(ps.cursorKey, 64)
+		}
+		if err != nil {
+			return nil, errors.New("cursor is invalid")
+		}
+		ps.hasCursor = true
+	}
+	return ps, nil
+}
+
+type productItem struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	PricePerKg    string     `json:"price_per_kg"`
+	WeightPerUnit float64    `json:"weight_per_unit"`
+	ImageURL      string     `json:"image_url"`
+	StockKg       float64    `json:"stock_kg"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+	FarmID        int64      `json:"farm_id"`
+	FarmName      string     `json:"farm_name"`
+	StatusName    string     `json:"status_name"`
+	AvailableDate *time.Time `json:"available_date"`
+	SaleUnit      string     `json:"sale_unit"`
+	StockPerUnit  qty.Qty    `json:"stock_per_unit"`
+	PricePerUnit  string     `json:"price_per_unit"`
+	MinQty        qty.Qty    `json:"min_qty"`
+	StepQty       qty.Qty    `json:"step_qty"`
+	CategoryID    *int64     `json:"category_id"`
+	CategoryName  *string    `json:"category_name"`
+	DistanceKm    *float64   `json:"distance_km,omitempty"`
+}
+
+type facetCount struct {
+	ID    *int64 `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type productFacets struct {
+	Categories []*category.Category `json:"categories"`
+	Statuses   []facetCount         `json:"statuses"`
+	Farms      []facetCount         `json:"farms"`
+	InStock    int64                `json:"in_stock"`
+	MinPrice   *float64             `json:"min_price"`
+	MaxPrice   *float64             `json:"max_price"`
+}
+
+// GetAllProduct menampilkan katalog produk dengan pencarian, filter, urutan dan paginasi kursor.
+// Parameter: q (teks pada nama dan deskripsi), min_price/max_price (harga per satuan jual), category_id
+// (termasuk subkategori), farm_id, status (nama status produk), in_stock=true, lat/lon dan radius (km),
+// sort (newest, price_asc, price_desc, distance, relevance), limit (maks 100) dan cursor.
+// facets menghitung produk per kategori, status dan peternakan dengan filter lain tetap berlaku.
+func GetAllProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	ps, err := parseProductSearch(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Invalid query",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	items, nextCursor, err := searchProducts(sqlDB, ps)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch products: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to fetch products.",
+		})
+		return
+	}
+
+	total, facets, err := productSearchFacets(sqlDB, ps)
+	if err != nil {
+		log.Printf("[ERROR] Failed to count product facets: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to count products.",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Products retrieved successfully.",
+		"data": map[string]interface{}{
+			"items":       items,
+			"next_cursor": nextCursor,
+			"total":       total,
+			"facets":      facets,
+		},
+	})
+}
+
+func searchProducts(db *sql.DB, ps *productSearch) ([]productItem, *string, error) {
+	sort := ps.sorts()[ps.sort]
+	key, args := listquery.Bind(sort.key, nil, ps.sortArgs()...)
+
+	distance := `NULL::float8`
+	if ps.hasPoint {
+		distance, args = listquery.Bind(`ST_Distance(f.location::geography, `+pointSQL+`)`, args, ps.lon, ps.lat)
+	}
+
+	where, args := ps.where("", args)
+	dir, cmp := "ASC", ">"
+	if sort.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if ps.hasCursor {
+		var cond string
+		cond, args = listquery.Bind(`((`+key+`) `+cmp+` ?::`+sort.cast+` OR ((`+key+`) = ?::`+sort.cast+` AND fp.id `+cmp+` ?))`,
+			args, ps.cursorKey, ps.cursorKey, ps.cursorID)
+		where += " AND " + cond
+	}
+	args = append(args, ps.limit+1)
+
+	query := `SELECT fp.id, fp.name, fp.description, fp.price_per_kg, fp.weight_per_unit, fp.image_url, fp.stock_kg,
+			fp.created_at, fp.updated_at, fp.farm_id, f.name, COALESCE(sp.name, ''), sp.available_date,
+			fp.sale_unit, fp.stock_per_unit, fp.price_per_unit, fp.min_qty, fp.step_qty,
+			fp.category_id, pc.name, ` + distance + `, (` + key + `)::text
+		FROM farm_products fp
+		JOIN farms f ON f.id = fp.farm_id
+		LEFT JOIN status_product sp ON sp.id = fp.status_id
+		LEFT JOIN product_category pc ON pc.id = fp.category_id
+		WHERE ` + where + `
+		ORDER BY (` + key + `) ` + dir + `, fp.id ` + dir + `
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	items := []productItem{}
+	var keys []string
+	for rows.Next() {
+		var p productItem
+		var unit saleUnit
+		var pricePerKg float64
+		var distanceM sql.NullFloat64
+		var sortKey sql.NullString
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &pricePerKg, &p.WeightPerUnit, &p.ImageURL, &p.StockKg,
+			&p.CreatedAt, &p.UpdatedAt, &p.FarmID, &p.FarmName, &p.StatusName, &p.AvailableDate,
+			&unit.SaleUnit, &unit.StockPerUnit, &unit.PricePerUnit, &unit.MinQty, &unit.StepQty,
+			&p.CategoryID, &p.CategoryName, &distanceM, &sortKey); err != nil {
+			return nil, nil, err
+		}
+		p.PricePerKg = format.FormatCurrency(pricePerKg)
+		p.SaleUnit = unit.SaleUnit
+		p.StockPerUnit = unit.StockPerUnit
+		p.PricePerUnit = format.FormatCurrency(unit.unitPrice(pricePerKg))
+		p.MinQty = unit.MinQty
+		p.StepQty = unit.StepQty
+		if distanceM.Valid {
+			km := distanceM.Float64 / 1000
+			p.DistanceKm = &km
+		}
+
+		// Konversi URL gambar menjadi format raw jika diperlukan
+		if strings.Contains(p.ImageURL, "https://github.com/") {
+			rawBaseURL := "https://raw.githubusercontent.com"
+			repoPath := "Ayala-crea/productImages/refs/heads/"
+			imagePath := strings.TrimPrefix(p.ImageURL, "https://github.com/Ayala-crea/productImages/blob/")
+			p.ImageURL = fmt.Sprintf("%s/%s%s", rawBaseURL, repoPath, imagePath)
+		}
+
+		items = append(items, p)
+		keys = append(keys, sortKey.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(items) > ps.limit {
+		items = items[:ps.limit]
+		c := listquery.EncodeCursor(ps.sort, keys[ps.limit-1], items[ps.limit-1].ID)
+		nextCursor = &c
+	}
+	return items, nextCursor, nil
+}
+
+// productSearchFacets menghitung total hasil dan facet; tiap facet mengabaikan filternya sendiri
+// agar pilihan lain pada facet yang sama tetap terlihat
+func productSearchFacets(db *sql.DB, ps *productSearch) (int64, productFacets, error) {
+	var facets productFacets
+	var total int64
+
+	where, args := ps.where("", nil)
+	err := db.QueryRow(`SELECT COUNT(*) FROM farm_products fp WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return 0, facets, err
+	}
+
+	where, args = ps.where("category", nil)
+	list, err := category.Counts(db, where, args...)
+	if err != nil {
+		return 0, facets, err
+	}
+	facets.Categories = category.Tree(list)
+
+	where, args = ps.where("status", nil)
+	facets.Statuses, err = facetCounts(db, `SELECT NULL::bigint, COALESCE(sp.name, ''), COUNT(*)
+		FROM farm_products fp LEFT JOIN status_product sp ON sp.id = fp.status_id
+		WHERE `+where+` GROUP BY 2 ORDER BY 3 DESC, 2`, args)
+	if err != nil {
+		return 0, facets, err
+	}
+
+	where, args = ps.where("farm", nil)
+	facets.Farms, err = facetCounts(db, `SELECT f.id::bigint, f.name, COUNT(*)
+		FROM farm_products fp JOIN farms f ON f.id = fp.farm_id
+		WHERE `+where+` GROUP BY f.id, f.name ORDER BY 3 DESC, f.name LIMIT 50`, args)
+	if err != nil {
+		return 0, facets, err
+	}
+
+	where, args = ps.where("in_stock", nil)
+	err = db.QueryRow(`SELECT COUNT(*) FROM farm_products fp
+		WHERE fp.stock_kg >= fp.min_qty * fp.stock_per_unit AND `+where, args...).Scan(&facets.InStock)
+	if err != nil {
+		return 0, facets, err
+	}
+
+	where, args = ps.where("price", nil)
+	err = db.QueryRow(`SELECT MIN(`+unitPriceSQL+`)::float8, MAX(`+unitPriceSQL+`)::float8
+		FROM farm_products fp WHERE `+where, args...).Scan(&facets.MinPrice, &facets.MaxPrice)
+	return total, facets, err
+}
+
+func facetCounts(db *sql.DB, query string, args []interface{}) ([]facetCount, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []facetCount{}
+	for rows.Next() {
+		var c facetCount
+		if err := rows.Scan(&c.ID, &c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
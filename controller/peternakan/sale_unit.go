@@ -0,0 +1,116 @@
+package peternakan
+
+import (
+	"database/sql"
+	"errors"
+	"farmdistribution_be/helper/qty"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// saleUnit adalah pengaturan satuan jual produk. StockPerUnit mengonversi satu satuan jual
+// ke stok (kg), misalnya satu tray telur = 1.8 kg stok.
+type saleUnit struct {
+	SaleUnit     string
+	StockPerUnit qty.Qty
+	PricePerUnit sql.NullFloat64
+	MinQty       qty.Qty
+	StepQty      qty.Qty
+	// CatchWeight menandai produk yang harga akhirnya dihitung dari berat timbang
+	CatchWeight     bool
+	WeightTolerance float64
+	// LowStockKg adalah batas stok (kg) untuk event product.low_stock; kosong berarti tidak dipantau
+	LowStockKg sql.NullFloat64
+}
+
+// defaultSaleUnit sama dengan perilaku lama: dijual per kg dengan kelipatan satu
+var defaultSaleUnit = saleUnit{
+	SaleUnit:        "kg",
+	StockPerUnit:    qty.FromInt(1),
+	MinQty:          qty.FromInt(1),
+	StepQty:         qty.FromInt(1),
+	WeightTolerance: 10,
+}
+
+// parseSaleUnit membaca pengaturan satuan jual dan catch-weight dari form.
+// Field yang kosong memakai nilai dari current.
+func parseSaleUnit(r *http.Request, current saleUnit) (saleUnit, error) {
+	result := current
+
+	if v := strings.ToLower(strings.TrimSpace(r.FormValue("sale_unit"))); v != "" {
+		if !qty.Units[v] {
+			return result, errors.New("sale_unit must be one of kg, piece, tray or liter")
+		}
+		result.SaleUnit = v
+	}
+
+	fields := []struct {
+		name string
+		dest *qty.Qty
+	}{
+		{"stock_per_unit", &result.StockPerUnit},
+		{"min_qty", &result.MinQty},
+		{"step_qty", &result.StepQty},
+	}
+	for _, f := range fields {
+		v := r.FormValue(f.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := qty.Parse(v)
+		if err != nil || parsed <= 0 {
+			return result, errors.New(f.name + " must be a positive number with at most 3 decimals")
+		}
+		*f.dest = parsed
+	}
+
+	if v := r.FormValue("price_per_unit"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			return result, errors.New("price_per_unit must be a non-negative number")
+		}
+		result.PricePerUnit = sql.NullFloat64{Float64: price, Valid: true}
+	}
+
+	if v := r.FormValue("catch_weight"); v != "" {
+		catchWeight, err := strconv.ParseBool(v)
+		if err != nil {
+			return result, errors.New("catch_weight must be true or false")
+		}
+		result.CatchWeight = catchWeight
+	}
+
+	if v := r.FormValue("weight_tolerance_percent"); v != "" {
+		tolerance, err := strconv.ParseFloat(v, 64)
+		if err != nil || tolerance < 0 || tolerance > 100 {
+			return result, errors.New("weight_tolerance_percent must be between 0 and 100")
+		}
+		result.WeightTolerance = tolerance
+	}
+
+	if v := r.FormValue("low_stock_threshold_kg"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold < 0 {
+			return result, errors.New("low_stock_threshold_kg must be a non-negative number")
+		}
+		result.LowStockKg = sql.NullFloat64{Float64: threshold, Valid: threshold > 0}
+	}
+
+	// Produk per kg selalu memakai konversi 1:1 ke stok
+	if result.SaleUnit == "kg" {
+		result.StockPerUnit = qty.FromInt(1)
+	}
+	if !result.MinQty.IsStepOf(result.StepQty) {
+		return result, errors.New("min_qty must be a multiple of step_qty")
+	}
+	return result, nil
+}
+
+// unitPrice menghitung harga per satuan jual; tanpa price_per_unit harga diturunkan dari price_per_kg
+func (s saleUnit) unitPrice(pricePerKg float64) float64 {
+	if s.PricePerUnit.Valid {
+		return s.PricePerUnit.Float64
+	}
+	return s.StockPerUnit.Amount(pricePerKg)
+}
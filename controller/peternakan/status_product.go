@@ -1,10 +1,12 @@
 package peternakan
 
 import (
+	"database/sql"
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
 	"farmdistribution_be/helper/atdb"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/helper/watoken"
 	"farmdistribution_be/model"
 	"log"
@@ -71,41 +73,42 @@ func CreateStatusProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// statusProductListSpec adalah field /status-product/get yang boleh diurutkan, difilter dan dicari
+var statusProductListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name":           {Column: "name", Type: listquery.Text, Sort: true, Filter: true},
+		"available_date": {Column: "available_date", Type: listquery.Time, Sort: true, Filter: true},
+	},
+	ID:     "id",
+	Search: []string{"name", "description"},
+}
+
+// GetAllStatusProducts menampilkan status produk per halaman; parameter mengikuti listquery
 func GetAllStatusProducts(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	query := `SELECT id, name, available_date FROM status_product`
-	rows, err := sqlDB.Query(query)
+	lq, err := listquery.Parse(r, statusProductListSpec)
 	if err != nil {
-		log.Printf("Error retrieving status products: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to retrieve status products.",
-		})
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	var statusProducts []model.StatusProduct
-	for rows.Next() {
-		var sp model.StatusProduct
-		if err := rows.Scan(&sp.ID, &sp.Name, &sp.AvailableDate); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
-		}
-		statusProducts = append(statusProducts, sp)
+	page, err := listquery.Run(sqlDB, lq, `id, name, description, available_date`, `status_product`,
+		func(rows *sql.Rows, key ...interface{}) (model.StatusProduct, error) {
+			var sp model.StatusProduct
+			err := rows.Scan(append([]interface{}{&sp.ID, &sp.Name, &sp.Description, &sp.AvailableDate}, key...)...)
+			return sp, err
+		})
+	if err != nil {
+		log.Printf("Error retrieving status products: %v", err)
+		listquery.WriteError(w, err)
+		return
 	}
 
-	response := map[string]interface{}{
-		"status": "success",
-		"data":   statusProducts,
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Status products retrieved successfully", page)
 }
 
 func GetStatusProductByID(w http.ResponseWriter, r *http.Request) {
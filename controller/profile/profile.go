@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/helper/watoken"
 	"farmdistribution_be/model"
 	"fmt"
@@ -317,84 +318,70 @@ func DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// profileListItem adalah profil pada /profile/all
+type profileListItem struct {
+	ID       int    `json:"id_user"`
+	Nama     string `json:"nama"`
+	NoTelp   string `json:"no_telp"`
+	Email    string `json:"email"`
+	RoleID   int    `json:"id_role"`
+	RoleName string `json:"role_name"`
+	Address  struct {
+		Street     string `json:"street"`
+		City       string `json:"city"`
+		State      string `json:"state"`
+		PostalCode string `json:"postal_code"`
+		Country    string `json:"country"`
+	} `json:"address"`
+}
+
+// profileListSpec adalah field /profile/all yang boleh diurutkan, difilter dan dicari
+var profileListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"nama":      {Column: "a.nama", Type: listquery.Text, Sort: true, Filter: true},
+		"email":     {Column: "a.email", Type: listquery.Text, Sort: true, Filter: true},
+		"id_role":   {Column: "a.id_role", Type: listquery.Int, Filter: true},
+		"role_name": {Column: "r.name_role", Type: listquery.Text, Sort: true, Filter: true},
+		"city":      {Column: "ad.city", Type: listquery.Text, Sort: true, Filter: true},
+	},
+	ID:     "a.id_user",
+	Search: []string{"a.nama", "a.email", "a.no_telp"},
+}
+
+// GetAllProfiles menampilkan profil per halaman; parameter mengikuti listquery
 func GetAllProfiles(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	lq, err := listquery.Parse(r, profileListSpec)
+	if err != nil {
+		listquery.WriteError(w, err)
+		return
+	}
 
-	query := `
-		SELECT 
-			a.id_user, a.nama, a.no_telp, a.email, a.id_role, r.name_role AS role_name, 
-			COALESCE(ad.street, '') AS street, COALESCE(ad.city, '') AS city, 
-			COALESCE(ad.state, '') AS state, COALESCE(ad.postal_code, '') AS postal_code, 
-			COALESCE(ad.country, '') AS country
-		FROM akun a
+	columns := `a.id_user, a.nama, a.no_telp, a.email, a.id_role, COALESCE(r.name_role, ''),
+		COALESCE(ad.street, ''), COALESCE(ad.city, ''), COALESCE(ad.state, ''),
+		COALESCE(ad.postal_code, ''), COALESCE(ad.country, '')`
+	from := `akun a
 		LEFT JOIN role r ON a.id_role = r.id_role
 		LEFT JOIN address ad ON a.address_id = ad.id_address`
 
-	rows, err := sqlDB.Query(query)
+	page, err := listquery.Run(sqlDB, lq, columns, from,
+		func(rows *sql.Rows, key ...interface{}) (profileListItem, error) {
+			var p profileListItem
+			err := rows.Scan(append([]interface{}{&p.ID, &p.Nama, &p.NoTelp, &p.Email, &p.RoleID, &p.RoleName,
+				&p.Address.Street, &p.Address.City, &p.Address.State, &p.Address.PostalCode, &p.Address.Country}, key...)...)
+			return p, err
+		})
 	if err != nil {
 		log.Printf("Error fetching profiles: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Internal server error",
-			"message": "Failed to fetch profiles.",
-		})
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	var profiles []struct {
-		ID       int    `json:"id_user"`
-		Nama     string `json:"nama"`
-		NoTelp   string `json:"no_telp"`
-		Email    string `json:"email"`
-		RoleID   int    `json:"id_role"`
-		RoleName string `json:"role_name"`
-		Address  struct {
-			Street     string `json:"street"`
-			City       string `json:"city"`
-			State      string `json:"state"`
-			PostalCode string `json:"postal_code"`
-			Country    string `json:"country"`
-		} `json:"address"`
-	}
-
-	for rows.Next() {
-		var profile struct {
-			ID       int    `json:"id_user"`
-			Nama     string `json:"nama"`
-			NoTelp   string `json:"no_telp"`
-			Email    string `json:"email"`
-			RoleID   int    `json:"id_role"`
-			RoleName string `json:"role_name"`
-			Address  struct {
-				Street     string `json:"street"`
-				City       string `json:"city"`
-				State      string `json:"state"`
-				PostalCode string `json:"postal_code"`
-				Country    string `json:"country"`
-			} `json:"address"`
-		}
-
-		err := rows.Scan(&profile.ID, &profile.Nama, &profile.NoTelp, &profile.Email, &profile.RoleID, &profile.RoleName,
-			&profile.Address.Street, &profile.Address.City, &profile.Address.State, &profile.Address.PostalCode, &profile.Address.Country)
-		if err != nil {
-			log.Printf("Error scanning profile: %v", err)
-			continue
-		}
-		profiles = append(profiles, profile)
-	}
-
-	response := map[string]interface{}{
-		"message": "Profiles fetched successfully",
-		"data":    profiles,
-	}
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	listquery.Write(w, "Profiles fetched successfully", page)
 }
 
 func GetProfileByID(w http.ResponseWriter, r *http.Request) {
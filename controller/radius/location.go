@@ -6,6 +6,7 @@ import (
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
 	"farmdistribution_be/helper/atdb"
+	"farmdistribution_be/helper/category"
 	"farmdistribution_be/model"
 	"fmt"
 	"log"
@@ -49,6 +50,16 @@ func GetAllTokoByRadius(w http.ResponseWriter, r *http.Request) {
 
 	radius := radiusInKm * 1000
 
+	// Filter kategori opsional: hanya toko yang memiliki produk pada kategori itu atau subkategorinya
+	var categoryID int64
+	if v := r.URL.Query().Get("category_id"); v != "" {
+		categoryID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			atdb.SendErrorResponse(w, http.StatusBadRequest, "Invalid category", err.Error())
+			return
+		}
+	}
+
 	// Query untuk toko berdasarkan radius
 	query := `
 	SELECT 
@@ -66,10 +77,14 @@ WHERE ST_DWithin(
     location, 
     ST_SetSRID(ST_MakePoint($1, $2), 4326), 
     $3
-);
+)
+AND ($4 = 0 OR EXISTS (
+    SELECT 1 FROM farm_products fp
+    WHERE fp.farm_id = farms.id AND fp.category_id IN ` + category.Subtree("$4") + `
+));
 	`
 
-	rows, err := sqlDB.Query(query, longitude, latitude, radius)
+	rows, err := sqlDB.Query(query, longitude, latitude, radius, categoryID)
 	if err != nil {
 		log.Println("Error executing query:", err)
 		atdb.SendErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
@@ -116,10 +131,21 @@ WHERE ST_DWithin(
 		return
 	}
 
+	// Jumlah produk per kategori dari toko di dalam radius
+	categories, err := category.Counts(sqlDB, `fp.farm_id IN (
+		SELECT id FROM farms WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326), $3))`,
+		longitude, latitude, radius)
+	if err != nil {
+		log.Println("Error counting products per category:", err)
+		atdb.SendErrorResponse(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
 	response := map[string]interface{}{
-		"status":  "success",
-		"message": "Stores found within radius",
-		"data":    allMarkets,
+		"status":     "success",
+		"message":    "Stores found within radius",
+		"data":       allMarkets,
+		"categories": category.Tree(categories),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,246 @@
+// Package realtime mengirim perubahan status order, pembayaran dan pengiriman ke dashboard
+// peternakan, halaman order pembeli dan aplikasi pengirim melalui Server-Sent Events.
+//
+// Alurnya: dispatcher outbox memanggil subscriber "stream" yang menentukan penerima lalu
+// menjalankan pg_notify. Setiap instance server mendengarkan channel tersebut (Listen) dan
+// memasukkan pesan ke broker lokal, sehingga koneksi di instance mana pun ikut menerima.
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/stream"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Channel LISTEN/NOTIFY yang dipakai bersama semua instance
+const notifyChannel = "farm_stream"
+
+var broker = stream.NewBroker(1024)
+
+// Kategori event SSE untuk setiap domain event
+var streamEvents = map[string]string{
+	events.OrderPlaced:        "order",
+	events.OrderWeighed:       "order",
+	events.InvoiceCancelled:   "order",
+	events.InvoiceExpired:     "order",
+	events.PaymentSubmitted:   "payment",
+	events.PaymentApproved:    "payment",
+	events.PaymentRejected:    "payment",
+	events.ShipmentDispatched: "shipment",
+	events.ShipmentDelivered:  "shipment",
+}
+
+// RegisterSubscribers meneruskan domain event invoice ke channel NOTIFY
+func RegisterSubscribers() {
+	for eventType := range streamEvents {
+		events.Subscribe("stream", eventType, handleInvoiceEvent)
+	}
+}
+
+func handleInvoiceEvent(ctx context.Context, e events.Event) error {
+	var p events.InvoicePayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return err
+	}
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+
+	recipients := []string{stream.Recipient(identity.TypeAkun, p.UserID)}
+	members, err := farmaccess.Members(ctx, sqlDB, p.FarmID, farmaccess.ViewFarm)
+	if err != nil {
+		return err
+	}
+	for _, akunID := range members {
+		if akunID != p.UserID {
+			recipients = append(recipients, stream.Recipient(identity.TypeAkun, akunID))
+		}
+	}
+	var pengirimID sql.NullInt64
+	query := `SELECT id_pengirim FROM proses_pengiriman WHERE id_invoice = $1 AND id_pengirim IS NOT NULL AND id_pengirim <> 0 ORDER BY id LIMIT 1`
+	if err := sqlDB.QueryRowContext(ctx, query, p.InvoiceID).Scan(&pengirimID); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if pengirimID.Valid {
+		recipients = append(recipients, stream.Recipient(identity.TypePengirim, pengirimID.Int64))
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":            e.Type,
+		"invoice_id":      p.InvoiceID,
+		"invoice_number":  p.InvoiceNumber,
+		"farm_id":         p.FarmID,
+		"total_amount":    p.TotalAmount,
+		"payment_status":  p.PaymentStatus,
+		"shipment_status": p.ShipmentStatus,
+		"occurred_at":     e.OccurredAt,
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(stream.Message{ID: e.ID, Event: streamEvents[e.Type], Data: data, Recipients: recipients})
+	if err != nil {
+		return err
+	}
+	_, err = sqlDB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(msg))
+	return err
+}
+
+// Listen mendengarkan channel NOTIFY dan memasukkan pesan ke broker sampai ctx selesai.
+// Koneksi yang terputus disambung ulang; pesan selama terputus dapat hilang, klien
+// menerimanya lewat event "reset" saat resume.
+func Listen(ctx context.Context) {
+	for {
+		if err := listen(ctx); err != nil && ctx.Err() == nil {
+			log.Println("[ERROR] realtime: listener stopped:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func listen(ctx context.Context) error {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		pgConn := c.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+			return err
+		}
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				log.Println("[ERROR] realtime:", err)
+				// Koneksi masih LISTEN, jangan dikembalikan ke pool
+				return driver.ErrBadConn
+			}
+			var m stream.Message
+			if err := json.Unmarshal([]byte(n.Payload), &m); err != nil {
+				log.Println("[ERROR] realtime: invalid notification:", err)
+				continue
+			}
+			broker.Publish(m)
+		}
+	})
+}
+
+// Stream membuka koneksi SSE untuk pemanggil. Token login dibaca dari header "login" atau
+// query ?login= (EventSource di browser tidak bisa mengirim header). Saat menyambung ulang,
+// browser mengirim Last-Event-ID dan event yang terlewat dikirim lebih dulu; jika sebagian
+// sudah tidak tersimpan, event "reset" dikirim agar klien memuat ulang datanya.
+func Stream(w http.ResponseWriter, r *http.Request) {
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	token := at.GetLoginFromHeader(r)
+	if token == "" {
+		token = r.URL.Query().Get("login")
+	}
+	c, err := identity.FromToken(sqlDB, token)
+	switch err {
+	case nil:
+	case identity.ErrUnauthorized:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return
+	case identity.ErrNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	default:
+		log.Println("[ERROR] Failed to resolve caller:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	since, _ := strconv.ParseInt(lastID, 10, 64)
+
+	backlog, sub, complete := broker.Subscribe(stream.Recipient(c.Type, c.ID), since)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, m := range backlog {
+		stream.WriteEvent(w, m)
+	}
+	flusher.Flush()
+
+	// Komentar berkala menjaga koneksi tetap terbuka melewati proxy
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-sub.C:
+			if !ok {
+				// Terlalu lambat membaca; klien menyambung ulang dengan Last-Event-ID
+				return
+			}
+			if err := stream.WriteEvent(w, m); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"farmdistribution_be/config"
-	"farmdistribution_be/helper/atdb"
+	"farmdistribution_be/helper/audit"
 	"farmdistribution_be/model"
 	"log"
 	"net/http"
@@ -16,6 +16,11 @@ func CreateMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var menu model.MenuAccess
 	w.Header().Set("Content-Type", "application/json")
 
@@ -93,7 +98,28 @@ func CreateMenu(w http.ResponseWriter, r *http.Request) {
 	query := `
         INSERT INTO menuaccess (menu_id, parent_id, nama_menu, routes_page, icon, sequence, status, parent_sequence)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	id, err := atdb.InsertOne(sqlDB, query, menu.MenuID, menu.ParentID, menu.NamaMenu, menu.RoutesPage, menu.Icon, menu.Sequence, menu.Status, menu.ParentSequence)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to insert menu into the database.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(query, menu.MenuID, menu.ParentID, menu.NamaMenu, menu.RoutesPage, menu.Icon, menu.Sequence, menu.Status, menu.ParentSequence).Scan(&id)
+	if err == nil {
+		entry := audit.New(r, caller.Type, caller.ID, "menu.create", "menuaccess", id)
+		if entry.After, err = audit.Snapshot(tx, "menuaccess", "id", id); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -185,6 +211,11 @@ func UpdateMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var menu model.MenuAccess
 	w.Header().Set("Content-Type", "application/json")
 
@@ -224,7 +255,34 @@ func UpdateMenu(w http.ResponseWriter, r *http.Request) {
         UPDATE menuaccess
         SET menu_id = $1, parent_id = $2, nama_menu = $3, routes_page = $4, icon = $5, sequence = $6, status = $7, parent_sequence = $8
         WHERE id = $9`
-	rowsAffected, err := atdb.UpdateOne(sqlDB, query, menu.MenuID, menu.ParentID, menu.NamaMenu, menu.RoutesPage, menu.Icon, menu.Sequence, menu.Status, menu.ParentSequence, id)
+	entry := audit.New(r, caller.Type, caller.ID, "menu.update", "menuaccess", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to update menu.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var rowsAffected int64
+	entry.Before, err = audit.Snapshot(tx, "menuaccess", "id", id)
+	if err == nil {
+		var res sql.Result
+		if res, err = tx.Exec(query, menu.MenuID, menu.ParentID, menu.NamaMenu, menu.RoutesPage, menu.Icon, menu.Sequence, menu.Status, menu.ParentSequence, id); err == nil {
+			rowsAffected, _ = res.RowsAffected()
+		}
+	}
+	if err == nil && rowsAffected > 0 {
+		if entry.After, err = audit.Snapshot(tx, "menuaccess", "id", id); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -261,6 +319,11 @@ func DeleteMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	// Extract the ID from the query parameters
@@ -276,7 +339,32 @@ func DeleteMenu(w http.ResponseWriter, r *http.Request) {
 
 	// Delete the menu using helper
 	query := "DELETE FROM menuaccess WHERE id = $1"
-	rowsAffected, err := atdb.DeleteOne(sqlDB, query, id)
+	entry := audit.New(r, caller.Type, caller.ID, "menu.delete", "menuaccess", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete menu.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	var rowsAffected int64
+	entry.Before, err = audit.Snapshot(tx, "menuaccess", "id", id)
+	if err == nil {
+		var res sql.Result
+		if res, err = tx.Exec(query, id); err == nil {
+			rowsAffected, _ = res.RowsAffected()
+		}
+	}
+	if err == nil && rowsAffected > 0 {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"farmdistribution_be/config"
+	"farmdistribution_be/helper/audit"
+	"farmdistribution_be/helper/identity"
+	"farmdistribution_be/helper/listquery"
 	"farmdistribution_be/model"
 	"log"
 	"net/http"
@@ -15,6 +18,11 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var role model.Role
 	w.Header().Set("Content-Type", "application/json")
 
@@ -42,9 +50,29 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 	role.Status = true
 
 	// Insert role ke database
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Internal server error",
+			"message": "Failed to insert role into the database.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	var newRoleID int
 	query := `INSERT INTO role (name_role, deskripsi) VALUES ($1, $2) RETURNING id_role`
-	err = sqlDB.QueryRow(query, role.Rolename, role.Desc).Scan(&newRoleID)
+	err = tx.QueryRow(query, role.Rolename, role.Desc).Scan(&newRoleID)
+	if err == nil {
+		entry := audit.New(r, caller.Type, caller.ID, "role.create", "role", newRoleID)
+		if entry.After, err = audit.Snapshot(tx, "role", "id_role", newRoleID); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("Error inserting role: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -68,47 +96,43 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// roleListSpec adalah field /role yang boleh diurutkan, difilter dan dicari
+var roleListSpec = listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name_role": {Column: "name_role", Type: listquery.Text, Sort: true, Filter: true},
+		"status":    {Column: "status", Type: listquery.Bool, Filter: true},
+	},
+	ID:          "id_role",
+	DefaultSort: "name_role",
+	Search:      []string{"name_role", "deskripsi"},
+}
+
+// GetAllRoles menampilkan role per halaman; parameter mengikuti listquery
 func GetAllRoles(w http.ResponseWriter, r *http.Request) {
 	sqlDB, err := config.PostgresDB.DB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var roles []model.Role
-	w.Header().Set("Content-Type", "application/json")
-
-	// Fetch all roles from the database
-	query := `SELECT id_role, name_role, deskripsi, status FROM role`
-	rows, err := sqlDB.Query(query)
+	lq, err := listquery.Parse(r, roleListSpec)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error":   "Database error",
-			"message": "Failed to fetch roles.",
-		})
+		listquery.WriteError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var role model.Role
-		if err := rows.Scan(&role.ID, &role.Rolename, &role.Desc, &role.Status); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":   "Data processing error",
-				"message": "Failed to process role data.",
-			})
-			return
-		}
-		roles = append(roles, role)
+	page, err := listquery.Run(sqlDB, lq, `id_role, name_role, deskripsi, status`, `role`,
+		func(rows *sql.Rows, key ...interface{}) (model.Role, error) {
+			var role model.Role
+			err := rows.Scan(append([]interface{}{&role.ID, &role.Rolename, &role.Desc, &role.Status}, key...)...)
+			return role, err
+		})
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch roles:", err)
+		listquery.WriteError(w, err)
+		return
 	}
 
-	// Return the list of roles
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Roles fetched successfully",
-		"roles":   roles,
-	})
+	listquery.Write(w, "Roles fetched successfully", page)
 }
 
 func GetRoleByID(w http.ResponseWriter, r *http.Request) {
@@ -165,6 +189,11 @@ func UpdateRole(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var role model.Role
 	w.Header().Set("Content-Type", "application/json")
 
@@ -189,9 +218,32 @@ func UpdateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entry := audit.New(r, caller.Type, caller.ID, "role.update", "role", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to update role.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	entry.Before, err = audit.Snapshot(tx, "role", "id_role", id)
+	if err != nil {
+		log.Println("[ERROR] Failed to snapshot role:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to update role.",
+		})
+		return
+	}
+
 	// Update role in the database
 	query := `UPDATE role SET name_role = $1, deskripsi = $2, status = $3 WHERE id_role = $4`
-	res, err := sqlDB.Exec(query, role.Rolename, role.Desc, role.Status, id)
+	res, err := tx.Exec(query, role.Rolename, role.Desc, role.Status, id)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -211,6 +263,23 @@ func UpdateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entry.After, err = audit.Snapshot(tx, "role", "id_role", id)
+	if err == nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to update role:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to update role.",
+		})
+		return
+	}
+
 	// Return response
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -225,6 +294,11 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	// Ambil ID dari query parameter
@@ -238,9 +312,32 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entry := audit.New(r, caller.Type, caller.ID, "role.delete", "role", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete role.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	entry.Before, err = audit.Snapshot(tx, "role", "id_role", id)
+	if err != nil {
+		log.Println("[ERROR] Failed to snapshot role:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete role.",
+		})
+		return
+	}
+
 	// Delete role from the database
 	query := `DELETE FROM role WHERE id_role = $1`
-	res, err := sqlDB.Exec(query, id)
+	res, err := tx.Exec(query, id)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -260,6 +357,20 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = audit.Record(tx, entry)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to delete role:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Database error",
+			"message": "Failed to delete role.",
+		})
+		return
+	}
+
 	// Return response
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -267,3 +378,38 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 		"rows_affected": rowsAffected,
 	})
 }
+
+// requireAdmin memastikan pemanggil adalah akun administrator. Caller yang dikembalikan
+// dicatat sebagai pelaku di audit_log.
+func requireAdmin(w http.ResponseWriter, r *http.Request, db *sql.DB) (identity.Caller, bool) {
+	caller, err := identity.FromRequest(db, r)
+	if err == nil && caller.Type != identity.TypeAkun {
+		err = identity.ErrNotFound
+	}
+	if err == identity.ErrUnauthorized || err == identity.ErrNotFound {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return caller, false
+	}
+	isAdmin := false
+	if err == nil {
+		isAdmin, err = identity.IsAdmin(db, caller.NoTelp)
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to check admin role:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return caller, false
+	}
+	if !isAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Forbidden",
+			"message": "Only administrators can access this resource.",
+		})
+		return caller, false
+	}
+	return caller, true
+}
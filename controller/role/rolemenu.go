@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"farmdistribution_be/config"
 	"farmdistribution_be/helper/at"
-	"farmdistribution_be/helper/atdb"
+	"farmdistribution_be/helper/audit"
 	"farmdistribution_be/model"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -18,6 +19,11 @@ func CreateRoleMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var rolemenu model.RoleMenu
 	w.Header().Set("Content-Type", "application/json")
 
@@ -85,13 +91,33 @@ func CreateRoleMenu(w http.ResponseWriter, r *http.Request) {
 	rolemenu.Status = 1
 
 	// Decision logic untuk parent_id
+	entry := audit.New(r, caller.Type, caller.ID, "rolemenu.create", "rolemenus", fmt.Sprintf("%d:%d", rolemenu.RoleID, rolemenu.MenuID))
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Internal server error",
+			"message": "Failed to insert role menu into the database.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	var query string
 	if rolemenu.ParentID == nil {
 		query = `INSERT INTO rolemenus (role_id, menu_id, status) VALUES ($1, $2, $3)`
-		_, err = atdb.InsertOne(sqlDB, query, rolemenu.RoleID, rolemenu.MenuID, rolemenu.Status)
+		_, err = tx.Exec(query, rolemenu.RoleID, rolemenu.MenuID, rolemenu.Status)
 	} else {
 		query = `INSERT INTO rolemenus (role_id, menu_id, parent_id, status) VALUES ($1, $2, $3, $4)`
-		_, err = atdb.InsertOne(sqlDB, query, rolemenu.RoleID, rolemenu.MenuID, rolemenu.ParentID, rolemenu.Status)
+		_, err = tx.Exec(query, rolemenu.RoleID, rolemenu.MenuID, rolemenu.ParentID, rolemenu.Status)
+	}
+	if err == nil {
+		if entry.After, err = audit.SnapshotWhere(tx, "rolemenus", "role_id = $1 AND menu_id = $2", rolemenu.RoleID, rolemenu.MenuID); err == nil {
+			err = audit.Record(tx, entry)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
 	}
 
 	if err != nil {
@@ -210,6 +236,11 @@ func UpdateRoleMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	var rolemenu model.RoleMenu
 	w.Header().Set("Content-Type", "application/json")
 
@@ -238,7 +269,32 @@ func UpdateRoleMenu(w http.ResponseWriter, r *http.Request) {
         UPDATE rolemenus
         SET parent_id = $1, status = $2, updated_at = NOW()
         WHERE role_id = $3 AND menu_id = $4`
-	_, err = atdb.UpdateOne(sqlDB, query, rolemenu.ParentID, rolemenu.Status, rolemenu.RoleID, rolemenu.MenuID)
+	entry := audit.New(r, caller.Type, caller.ID, "rolemenu.update", "rolemenus", fmt.Sprintf("%d:%d", rolemenu.RoleID, rolemenu.MenuID))
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Internal server error",
+			"message": "Failed to update role menu.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	where := "role_id = $1 AND menu_id = $2"
+	entry.Before, err = audit.SnapshotWhere(tx, "rolemenus", where, rolemenu.RoleID, rolemenu.MenuID)
+	if err == nil {
+		_, err = tx.Exec(query, rolemenu.ParentID, rolemenu.Status, rolemenu.RoleID, rolemenu.MenuID)
+	}
+	if err == nil {
+		entry.After, err = audit.SnapshotWhere(tx, "rolemenus", where, rolemenu.RoleID, rolemenu.MenuID)
+	}
+	if err == nil && entry.Before != nil {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("Error updating role menu: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -263,6 +319,11 @@ func DeleteRoleMenu(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	caller, ok := requireAdmin(w, r, sqlDB)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	// Extract ID from query parameters
@@ -278,7 +339,33 @@ func DeleteRoleMenu(w http.ResponseWriter, r *http.Request) {
 
 	// Delete role menu
 	query := "DELETE FROM rolemenus WHERE role_id = $1"
-	rowsAffected, err := atdb.DeleteOne(sqlDB, query, id)
+	entry := audit.New(r, caller.Type, caller.ID, "rolemenu.delete", "rolemenus", id)
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Internal server error",
+			"message": "Failed to delete role menu.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// Semua menu milik role dihapus, jadi seluruh barisnya disimpan di audit_log
+	var rowsAffected int64
+	entry.Before, err = audit.SnapshotRows(tx, "rolemenus", "role_id = $1", id)
+	if err == nil {
+		var res sql.Result
+		if res, err = tx.Exec(query, id); err == nil {
+			rowsAffected, _ = res.RowsAffected()
+		}
+	}
+	if err == nil && rowsAffected > 0 {
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("Error deleting role menu: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
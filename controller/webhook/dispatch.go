@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/jobs"
+	"farmdistribution_be/helper/webhook"
+	"fmt"
+	"log"
+	"time"
+)
+
+// JobDeliver adalah jenis job untuk mengirim satu webhook_delivery
+const JobDeliver = "webhook.deliver"
+
+// webhookTypes memetakan domain event ke jenis event webhook
+var webhookTypes = map[string]string{
+	events.OrderPlaced:        webhook.OrderCreated,
+	events.PaymentApproved:    webhook.PaymentApproved,
+	events.ShipmentDispatched: webhook.ShipmentUpdated,
+	events.ShipmentDelivered:  webhook.ShipmentUpdated,
+	events.ProductLowStock:    webhook.ProductLowStock,
+}
+
+// RegisterSubscribers meneruskan domain event ke endpoint webhook peternakan
+func RegisterSubscribers() {
+	for eventType := range webhookTypes {
+		events.Subscribe("webhook", eventType, enqueueDeliveries)
+	}
+}
+
+// RegisterJobs mendaftarkan job pengiriman webhook
+func RegisterJobs() {
+	jobs.Register(JobDeliver, deliverJob)
+}
+
+type deliveryBody struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// enqueueDeliveries membuat webhook_delivery untuk setiap endpoint peternakan yang berlangganan.
+// Delivery unik per endpoint dan event, jadi event yang dikirim ulang dispatcher tidak menggandakan webhook.
+func enqueueDeliveries(ctx context.Context, e events.Event) error {
+	webhookType := webhookTypes[e.Type]
+	var target struct {
+		FarmID int64 `json:"farm_id"`
+	}
+	if err := json.Unmarshal(e.Payload, &target); err != nil {
+		return err
+	}
+	if target.FarmID == 0 {
+		return nil
+	}
+
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(deliveryBody{
+		ID:         fmt.Sprintf("evt_%d", e.ID),
+		Type:       webhookType,
+		OccurredAt: e.OccurredAt,
+		Data:       e.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id FROM webhook_endpoint
+		WHERE farm_id = $1 AND active AND ',' || event_types || ',' LIKE '%,' || $2 || ',%'`
+	rows, err := tx.Query(query, target.FarmID, webhookType)
+	if err != nil {
+		return err
+	}
+	var endpointIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		endpointIDs = append(endpointIDs, id)
+	}
+	rows.Close()
+
+	for _, endpointID := range endpointIDs {
+		var deliveryID int64
+		query := `INSERT INTO webhook_delivery (endpoint_id, event_id, event_type, payload, status, max_attempts, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, 'pending', $5, NOW(), NOW())
+			ON CONFLICT (endpoint_id, event_id) DO NOTHING
+			RETURNING id`
+		err := tx.QueryRow(query, endpointID, e.ID, webhookType, string(body), jobs.DefaultMaxAttempts).Scan(&deliveryID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := jobs.Enqueue(tx, JobDeliver, map[string]int64{"delivery_id": deliveryID}, time.Now()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// deliverJob mengirim satu delivery. Error dikembalikan agar job diulang dengan backoff
+// sampai max_attempts delivery tercapai; setelah itu delivery ditandai failed.
+func deliverJob(ctx context.Context, payload json.RawMessage) error {
+	var p struct {
+		DeliveryID int64 `json:"delivery_id"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+
+	var eventType, status, url, secret string
+	var body []byte
+	var attempts, maxAttempts int
+	var active bool
+	query := `SELECT d.event_type, d.payload, d.status, d.attempts, d.max_attempts, e.url, e.secret, e.active
+		FROM webhook_delivery d JOIN webhook_endpoint e ON e.id = d.endpoint_id
+		WHERE d.id = $1`
+	err = sqlDB.QueryRowContext(ctx, query, p.DeliveryID).Scan(&eventType, &body, &status, &attempts, &maxAttempts, &url, &secret, &active)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status != "pending" {
+		return nil
+	}
+	if !active {
+		_, err = sqlDB.ExecContext(ctx, `UPDATE webhook_delivery SET status = 'failed', last_error = 'endpoint disabled', updated_at = NOW() WHERE id = $1`, p.DeliveryID)
+		return err
+	}
+
+	attempts++
+	// Endpoint lama dapat memakai http atau alamat internal yang kini ditolak ValidateURL
+	var result webhook.Result
+	sendErr := webhook.ValidateURL(url)
+	if sendErr == nil {
+		result, sendErr = webhook.Deliver(ctx, nil, url, secret, eventType, fmt.Sprint(p.DeliveryID), body)
+	}
+	errText := ""
+	if sendErr != nil {
+		errText = sendErr.Error()
+	}
+
+	query = `INSERT INTO webhook_attempt (delivery_id, attempt, status_code, response_body, error, duration_ms, created_at)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, ''), $6, NOW())`
+	if _, err := sqlDB.ExecContext(ctx, query, p.DeliveryID, attempts, result.StatusCode, result.Body, errText, result.Duration.Milliseconds()); err != nil {
+		return err
+	}
+
+	newStatus := "succeeded"
+	if sendErr != nil {
+		newStatus = "pending"
+		if attempts >= maxAttempts {
+			newStatus = "failed"
+		}
+	}
+	query = `UPDATE webhook_delivery SET status = $2, attempts = $3, last_status_code = NULLIF($4, 0), last_error = NULLIF($5, ''),
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE delivered_at END, updated_at = NOW()
+		WHERE id = $1`
+	if _, err := sqlDB.ExecContext(ctx, query, p.DeliveryID, newStatus, attempts, result.StatusCode, errText); err != nil {
+		return err
+	}
+
+	if newStatus == "pending" {
+		return sendErr
+	}
+	if newStatus == "failed" {
+		log.Printf("[ERROR] webhook delivery %d failed after %d attempts: %v", p.DeliveryID, attempts, sendErr)
+	}
+	return nil
+}
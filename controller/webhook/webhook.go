@@ -0,0 +1,412 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/farmaccess"
+	"farmdistribution_be/helper/jobs"
+	"farmdistribution_be/helper/watoken"
+	"farmdistribution_be/helper/webhook"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type endpoint struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	EventTypes  []string  `json:"event_types"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"` // Hanya ditampilkan saat endpoint dibuat
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type attempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   *int      `json:"status_code"`
+	ResponseBody *string   `json:"response_body"`
+	Error        *string   `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type delivery struct {
+	ID             int64           `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	AttemptLog     []attempt       `json:"attempt_log"`
+}
+
+// ownerFarm mengembalikan peternakan yang webhook-nya boleh dikelola pemanggil (owner); false berarti respons error sudah ditulis
+func ownerFarm(w http.ResponseWriter, r *http.Request) (*sql.DB, int64, bool) {
+	w.Header().Set("Content-Type", "application/json")
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		log.Println("Database connection error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	payload, err := watoken.Decode(config.PUBLICKEY, at.GetLoginFromHeader(r))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Unauthorized",
+			"message": "Invalid or expired token. Please log in again.",
+		})
+		return nil, 0, false
+	}
+
+	membership, err := farmaccess.ForRequestPhone(sqlDB, r, payload.Id, farmaccess.ManageWebhooks)
+	if err != nil {
+		log.Println("[ERROR] Failed to resolve farm access:", err)
+		farmaccess.WriteError(w, err)
+		return nil, 0, false
+	}
+	return sqlDB, membership.FarmID, true
+}
+
+// parseEventTypes memvalidasi daftar event dan menyimpannya sebagai teks dipisah koma
+func parseEventTypes(types []string) (string, bool) {
+	if len(types) == 0 {
+		return "", false
+	}
+	seen := map[string]bool{}
+	var list []string
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if !webhook.ValidEventType(t) {
+			return "", false
+		}
+		if !seen[t] {
+			seen[t] = true
+			list = append(list, t)
+		}
+	}
+	return strings.Join(list, ","), true
+}
+
+// CreateWebhook mendaftarkan endpoint webhook baru untuk peternakan pemanggil
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		EventTypes  []string `json:"event_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	eventTypes, valid := parseEventTypes(req.EventTypes)
+	if !valid {
+		http.Error(w, "event_types must contain one or more of "+strings.Join(webhook.EventTypes, ", "), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		log.Println("[ERROR] Failed to generate webhook secret:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	e := endpoint{URL: req.URL, Description: req.Description, EventTypes: strings.Split(eventTypes, ","), Active: true, Secret: secret}
+	query := `INSERT INTO webhook_endpoint (farm_id, url, description, event_types, secret, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, NOW(), NOW()) RETURNING id, created_at`
+	if err := sqlDB.QueryRow(query, farmID, req.URL, req.Description, eventTypes, secret).Scan(&e.ID, &e.CreatedAt); err != nil {
+		log.Println("[ERROR] Failed to create webhook:", err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhook created. Store the secret now, it will not be shown again.",
+		"data":    e,
+	})
+}
+
+// GetWebhooks menampilkan endpoint webhook milik peternakan pemanggil
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+
+	query := `SELECT id, url, COALESCE(description, ''), event_types, active, created_at FROM webhook_endpoint WHERE farm_id = $1 ORDER BY id`
+	rows, err := sqlDB.Query(query, farmID)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch webhooks:", err)
+		http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := []endpoint{}
+	for rows.Next() {
+		var e endpoint
+		var eventTypes string
+		if err := rows.Scan(&e.ID, &e.URL, &e.Description, &eventTypes, &e.Active, &e.CreatedAt); err != nil {
+			log.Println("[ERROR] Failed to scan webhook:", err)
+			http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+			return
+		}
+		e.EventTypes = strings.Split(eventTypes, ",")
+		list = append(list, e)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhooks retrieved successfully",
+		"data":    list,
+	})
+}
+
+// UpdateWebhook mengubah URL, deskripsi, langganan event atau status aktif endpoint
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		URL         string   `json:"url"`
+		Description *string  `json:"description"`
+		EventTypes  []string `json:"event_types"`
+		Active      *bool    `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if req.URL != "" {
+		if err := webhook.ValidateURL(req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	eventTypes := ""
+	if req.EventTypes != nil {
+		var valid bool
+		if eventTypes, valid = parseEventTypes(req.EventTypes); !valid {
+			http.Error(w, "event_types must contain one or more of "+strings.Join(webhook.EventTypes, ", "), http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := `UPDATE webhook_endpoint SET
+			url = COALESCE(NULLIF($3, ''), url),
+			description = COALESCE($4, description),
+			event_types = COALESCE(NULLIF($5, ''), event_types),
+			active = COALESCE($6, active),
+			updated_at = NOW()
+		WHERE id = $1 AND farm_id = $2`
+	result, err := sqlDB.Exec(query, id, farmID, req.URL, req.Description, eventTypes, req.Active)
+	if err != nil {
+		log.Println("[ERROR] Failed to update webhook:", err)
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhook updated successfully",
+	})
+}
+
+// DeleteWebhook menghapus endpoint beserta riwayat pengirimannya
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := sqlDB.Exec(`DELETE FROM webhook_endpoint WHERE id = $1 AND farm_id = $2`, id, farmID)
+	if err != nil {
+		log.Println("[ERROR] Failed to delete webhook:", err)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries menampilkan pengiriman terbaru sebuah endpoint beserta setiap percobaannya
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	var endpointURL string
+	if err := sqlDB.QueryRow(`SELECT url FROM webhook_endpoint WHERE id = $1 AND farm_id = $2`, id, farmID).Scan(&endpointURL); err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	// Isi respons dari endpoint yang tidak lolos ValidateURL (mis. alamat internal yang
+	// didaftarkan sebelum validasi diperketat) tidak ditampilkan
+	showBody := webhook.ValidateURL(endpointURL) == nil
+
+	query := `SELECT id, event_type, payload, status, attempts, last_status_code, last_error, delivered_at, created_at
+		FROM webhook_delivery WHERE endpoint_id = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := sqlDB.Query(query, id, limit)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch webhook deliveries:", err)
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := []*delivery{}
+	byID := map[int64]*delivery{}
+	for rows.Next() {
+		d := &delivery{AttemptLog: []attempt{}}
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			log.Println("[ERROR] Failed to scan webhook delivery:", err)
+			http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+			return
+		}
+		d.Payload = payload
+		list = append(list, d)
+		byID[d.ID] = d
+	}
+
+	query = `SELECT delivery_id, attempt, status_code, response_body, error, duration_ms, created_at
+		FROM webhook_attempt
+		WHERE delivery_id IN (SELECT id FROM webhook_delivery WHERE endpoint_id = $1 ORDER BY id DESC LIMIT $2)
+		ORDER BY delivery_id, attempt`
+	attemptRows, err := sqlDB.Query(query, id, limit)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch webhook attempts:", err)
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer attemptRows.Close()
+	for attemptRows.Next() {
+		var deliveryID int64
+		var a attempt
+		if err := attemptRows.Scan(&deliveryID, &a.Attempt, &a.StatusCode, &a.ResponseBody, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+			log.Println("[ERROR] Failed to scan webhook attempt:", err)
+			http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+			return
+		}
+		if !showBody {
+			a.ResponseBody = nil
+		}
+		if d := byID[deliveryID]; d != nil {
+			d.AttemptLog = append(d.AttemptLog, a)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Webhook deliveries retrieved successfully",
+		"data":    list,
+	})
+}
+
+// ReplayWebhookDelivery mengirim ulang sebuah delivery dengan isi yang sama
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	sqlDB, farmID, ok := ownerFarm(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Replay memberi jatah percobaan baru tanpa menghapus riwayat percobaan sebelumnya
+	query := `UPDATE webhook_delivery d SET status = 'pending', max_attempts = d.attempts + $3, updated_at = NOW()
+		FROM webhook_endpoint e
+		WHERE d.id = $1 AND e.id = d.endpoint_id AND e.farm_id = $2 AND d.status <> 'pending'`
+	result, err := tx.Exec(query, id, farmID, jobs.DefaultMaxAttempts)
+	if err != nil {
+		log.Println("[ERROR] Failed to replay webhook delivery:", err)
+		http.Error(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Delivery not found or still pending", http.StatusNotFound)
+		return
+	}
+	if _, err := jobs.Enqueue(tx, JobDeliver, map[string]int64{"delivery_id": id}, time.Now()); err != nil {
+		log.Println("[ERROR] Failed to enqueue webhook replay:", err)
+		http.Error(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing transaction:", err)
+		http.Error(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Delivery queued for replay",
+	})
+}
@@ -0,0 +1,221 @@
+// Package audit mencatat operasi istimewa (perubahan akun, role, menu, pembatalan invoice)
+// ke tabel audit_log yang hanya bisa ditambah. Record dipanggil dengan transaksi yang sama
+// dengan perubahannya sehingga catatan dan perubahan selalu ter-commit bersama.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Kolom yang tidak pernah disimpan di audit_log
+var redacted = map[string]bool{"password": true, "secret": true}
+
+// Entry adalah satu catatan audit
+type Entry struct {
+	ActorType  string
+	ActorID    int64
+	Action     string // mis. "akun.update", "role.delete"
+	TargetType string // Nama tabel/objek yang diubah
+	TargetID   string
+	Before     map[string]interface{} // nil untuk data baru
+	After      map[string]interface{} // nil untuk data yang dihapus
+	IP         string
+}
+
+// Change adalah nilai satu kolom sebelum dan sesudah perubahan
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Execer dipenuhi oleh *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Queryer dipenuhi oleh *sql.Tx dan *sql.DB
+type Queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// New menyiapkan Entry untuk pelaku actorType/actorID dengan IP klien dari request
+func New(r *http.Request, actorType string, actorID int64, action, targetType string, targetID interface{}) Entry {
+	return Entry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		IP:         ClientIP(r),
+	}
+}
+
+// Snapshot membaca satu baris sebagai map kolom → nilai; nil jika baris tidak ada.
+// table dan keyColumn harus konstanta dari kode, bukan input pengguna.
+func Snapshot(q Queryer, table, keyColumn string, key interface{}) (map[string]interface{}, error) {
+	return SnapshotWhere(q, table, keyColumn+" = $1", key)
+}
+
+// SnapshotWhere seperti Snapshot dengan kondisi WHERE bebas, mis. untuk primary key gabungan
+func SnapshotWhere(q Queryer, table, where string, args ...interface{}) (map[string]interface{}, error) {
+	return scanJSON(q, fmt.Sprintf(`SELECT row_to_json(t) FROM %s t WHERE %s LIMIT 1`, table, where), args...)
+}
+
+// SnapshotRows membaca semua baris yang cocok sebagai {"rows": [...]}; nil jika tidak ada
+func SnapshotRows(q Queryer, table, where string, args ...interface{}) (map[string]interface{}, error) {
+	return scanJSON(q, fmt.Sprintf(`SELECT json_build_object('rows', json_agg(row_to_json(t))) FROM %s t WHERE %s HAVING COUNT(*) > 0`, table, where), args...)
+}
+
+func scanJSON(q Queryer, query string, args ...interface{}) (map[string]interface{}, error) {
+	var raw []byte
+	err := q.QueryRow(query, args...).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// Diff mengembalikan kolom yang nilainya berbeda antara before dan after
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := map[string]Change{}
+	for k, v := range before {
+		if w, ok := after[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = Change{From: v, To: after[k]}
+		}
+	}
+	for k, w := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = Change{To: w}
+		}
+	}
+	return changes
+}
+
+func redact(row map[string]interface{}) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		if !redacted[strings.ToLower(k)] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func jsonOrNil(v interface{}) (interface{}, error) {
+	if reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Record menulis e ke audit_log. Kolom rahasia (password, secret) dibuang sebelum disimpan.
+func Record(tx Execer, e Entry) error {
+	before, after := redact(e.Before), redact(e.After)
+	beforeJSON, err := jsonOrNil(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := jsonOrNil(after)
+	if err != nil {
+		return err
+	}
+	changesJSON, err := jsonOrNil(Diff(before, after))
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO audit_log (actor_type, actor_id, action, target_type, target_id, before, after, changes, ip, created_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NOW())`
+	_, err = tx.Exec(query, e.ActorType, e.ActorID, e.Action, e.TargetType, e.TargetID, beforeJSON, afterJSON, changesJSON, e.IP)
+	return err
+}
+
+// trustedProxies adalah jaringan reverse proxy yang header X-Forwarded-For/X-Real-IP-nya dipercaya
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies mengatur daftar IP/CIDR reverse proxy (dipisah koma). Tanpa daftar ini
+// header dari klien diabaikan karena bisa dipalsukan.
+func SetTrustedProxies(list string) error {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP mengambil IP klien dari RemoteAddr. Header reverse proxy hanya dibaca jika
+// RemoteAddr adalah proxy tepercaya; X-Forwarded-For ditelusuri dari kanan dan alamat
+// pertama yang bukan proxy tepercaya dianggap sebagai klien.
+func ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+	if !trusted(remote) {
+		return remote
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !trusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remote
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{"nama": "Budi", "id_role": float64(2), "email": "b@x.id"}
+	after := map[string]interface{}{"nama": "Budi", "id_role": float64(11), "no_telp": "62812"}
+
+	got := Diff(before, after)
+	want := map[string]Change{
+		"id_role": {From: float64(2), To: float64(11)},
+		"email":   {From: "b@x.id", To: nil},
+		"no_telp": {From: nil, To: "62812"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %+v", got)
+	}
+
+	if created := Diff(nil, map[string]interface{}{"name_role": "kurir"}); created["name_role"].To != "kurir" {
+		t.Errorf("Diff for created row = %+v", created)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	row := redact(map[string]interface{}{"nama": "Budi", "Password": "$2a$10$hash"})
+	if _, ok := row["Password"]; ok || row["nama"] != "Budi" {
+		t.Errorf("redact = %+v", row)
+	}
+	if redact(nil) != nil {
+		t.Error("redact(nil) should stay nil")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer SetTrustedProxies("")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.5:5123"
+	r.Header.Set("X-Real-IP", "203.0.113.9")
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 10.0.0.1")
+	if ip := ClientIP(r); ip != "10.0.0.5" {
+		t.Errorf("untrusted proxy headers should be ignored: %q", ip)
+	}
+
+	if err := SetTrustedProxies("10.0.0.0/8, 127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if ip := ClientIP(r); ip != "198.51.100.7" {
+		t.Errorf("X-Forwarded-For: %q", ip)
+	}
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.7, 10.0.0.1")
+	if ip := ClientIP(r); ip != "198.51.100.7" {
+		t.Errorf("forged X-Forwarded-For prefix should be skipped: %q", ip)
+	}
+	r.Header.Del("X-Forwarded-For")
+	if ip := ClientIP(r); ip != "203.0.113.9" {
+		t.Errorf("X-Real-IP: %q", ip)
+	}
+
+	if err := SetTrustedProxies("not-an-ip"); err == nil {
+		t.Error("SetTrustedProxies should reject invalid entries")
+	}
+}
@@ -0,0 +1,112 @@
+// Package category menyusun pohon kategori produk (product_category) dan menyediakan potongan
+// SQL untuk menyaring produk berdasarkan kategori beserta seluruh turunannya.
+package category
+
+import (
+	"database/sql"
+)
+
+// Category adalah satu simpul pohon kategori. ProductCount menghitung produk pada kategori
+// itu dan semua turunannya.
+type Category struct {
+	ID           int64       `json:"id"`
+	ParentID     *int64      `json:"parent_id"`
+	Name         string      `json:"name"`
+	Slug         string      `json:"slug"`
+	Description  *string     `json:"description,omitempty"`
+	SortOrder    int         `json:"sort_order"`
+	ProductCount int64       `json:"product_count"`
+	Children     []*Category `json:"children,omitempty"`
+}
+
+// Queryer dipenuhi oleh *sql.DB dan *sql.Tx
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Subtree mengembalikan subquery berisi id kategori param (mis. "$1") dan semua turunannya,
+// untuk dipakai sebagai fp.category_id IN <Subtree>
+func Subtree(param string) string {
+	return `(WITH RECURSIVE sub AS (
+			SELECT id FROM product_category WHERE id = ` + param + `
+			UNION ALL
+			SELECT c.id FROM product_category c JOIN sub ON c.parent_id = sub.id
+		) SELECT id FROM sub)`
+}
+
+// Counts mengembalikan semua kategori, urut sort_order lalu nama, dengan jumlah produk farm_products fp
+// yang memenuhi scope (kondisi SQL atas fp dengan parameter args; kosong berarti semua produk)
+func Counts(q Queryer, scope string, args ...interface{}) ([]Category, error) {
+	if scope == "" {
+		scope = "TRUE"
+	}
+	query := `WITH RECURSIVE tree AS (
+			SELECT id, id AS root FROM product_category
+			UNION ALL
+			SELECT c.id, t.root FROM product_category c JOIN tree t ON c.parent_id = t.id
+		), counted AS (
+			SELECT fp.category_id, COUNT(*) AS n FROM farm_products fp
+			WHERE fp.category_id IS NOT NULL AND (` + scope + `)
+			GROUP BY fp.category_id
+		)
+		SELECT pc.id, pc.parent_id, pc.name, pc.slug, pc.description, pc.sort_order, COALESCE(SUM(counted.n), 0)
+		FROM product_category pc
+		JOIN tree t ON t.root = pc.id
+		LEFT JOIN counted ON counted.category_id = t.id
+		GROUP BY pc.id
+		ORDER BY pc.sort_order, pc.name, pc.id`
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Category
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.Slug, &c.Description, &c.SortOrder, &c.ProductCount); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Tree menyusun daftar datar menjadi pohon dengan urutan daftar tetap terjaga.
+// Kategori yang induknya tidak ada di daftar menjadi akar.
+func Tree(list []Category) []*Category {
+	nodes := make(map[int64]*Category, len(list))
+	for i := range list {
+		c := list[i]
+		c.Children = nil
+		nodes[c.ID] = &c
+	}
+	roots := []*Category{}
+	for _, c := range list {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// Exists melaporkan apakah kategori id ada
+func Exists(q Queryer, id int64) (bool, error) {
+	var ok bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_category WHERE id = $1)`, id).Scan(&ok)
+	return ok, err
+}
+
+// WouldCycle melaporkan apakah memindahkan kategori id ke bawah parentID membentuk siklus,
+// yaitu parentID adalah id itu sendiri atau salah satu turunannya
+func WouldCycle(q Queryer, id, parentID int64) (bool, error) {
+	var cycle bool
+	err := q.QueryRow(`SELECT $2::int IN `+Subtree("$1::int"), id, parentID).Scan(&cycle)
+	return cycle, err
+}
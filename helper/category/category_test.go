@@ -0,0 +1,35 @@
+package category
+
+import "testing"
+
+func TestTree(t *testing.T) {
+	id := func(v int64) *int64 { return &v }
+	list := []Category{
+		{ID: 1, Name: "Ternak"},
+		{ID: 2, ParentID: id(1), Name: "Sapi"},
+		{ID: 3, ParentID: id(2), Name: "Daging sapi"},
+		{ID: 4, Name: "Sayur"},
+		{ID: 5, ParentID: id(1), Name: "Unggas"},
+		{ID: 6, ParentID: id(99), Name: "Yatim"},
+	}
+	roots := Tree(list)
+	if len(roots) != 3 || roots[0].ID != 1 || roots[1].ID != 4 || roots[2].ID != 6 {
+		t.Fatalf("roots = %+v", roots)
+	}
+	ternak := roots[0]
+	if len(ternak.Children) != 2 || ternak.Children[0].ID != 2 || ternak.Children[1].ID != 5 {
+		t.Fatalf("Ternak children = %+v", ternak.Children)
+	}
+	if c := ternak.Children[0].Children; len(c) != 1 || c[0].Name != "Daging sapi" {
+		t.Fatalf("Sapi children = %+v", c)
+	}
+	if list[0].Children != nil {
+		t.Error("Tree must not modify the input list")
+	}
+}
+
+func TestTreeEmpty(t *testing.T) {
+	if roots := Tree(nil); roots == nil || len(roots) != 0 {
+		t.Errorf("Tree(nil) = %#v, want empty slice", roots)
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// MaxAttempts adalah batas percobaan sebelum event ditandai gagal
+const MaxAttempts = 10
+
+// Dispatcher mengambil event dari outbox dan mengirimkannya ke subscriber
+type Dispatcher struct {
+	DB           *sql.DB
+	PollInterval time.Duration // Jeda saat outbox kosong, default 2 detik
+}
+
+// Run mengirim event terus-menerus sampai ctx selesai
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	for {
+		ok, err := d.DispatchNext(ctx)
+		if err != nil {
+			log.Println("[ERROR] events:", err)
+		}
+		if ok && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// DispatchNext mengirim satu event yang jatuh tempo; false berarti outbox kosong.
+// Baris event dikunci (SKIP LOCKED) selama dikirim sehingga dispatcher lain melewatinya.
+func (d *Dispatcher) DispatchNext(ctx context.Context) (bool, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var e Event
+	var payload []byte
+	var attempts int
+	query := `SELECT id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts
+		FROM outbox
+		WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	err = tx.QueryRow(query).Scan(&e.ID, &e.Type, &e.AggregateType, &e.AggregateID, &payload, &e.OccurredAt, &attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	e.Payload = payload
+
+	var failures []string
+	for _, s := range subscribersFor(e.Type) {
+		var done bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM outbox_delivery WHERE event_id = $1 AND subscriber = $2)`, e.ID, s.name).Scan(&done)
+		if err != nil {
+			return false, err
+		}
+		if done {
+			continue
+		}
+		if err := call(ctx, s, e); err != nil {
+			failures = append(failures, s.name+": "+err.Error())
+			continue
+		}
+		// Dicatat langsung (bukan di tx) agar keberhasilan tetap tersimpan meskipun subscriber lain gagal
+		_, err = d.DB.Exec(`INSERT INTO outbox_delivery (event_id, subscriber, delivered_at) VALUES ($1, $2, NOW()) ON CONFLICT DO NOTHING`, e.ID, s.name)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(failures) == 0 {
+		_, err = tx.Exec(`UPDATE outbox SET dispatched_at = NOW(), last_error = NULL WHERE id = $1`, e.ID)
+	} else {
+		attempts++
+		lastError := strings.Join(failures, "; ")
+		log.Printf("[ERROR] events: %s #%d attempt %d failed: %s", e.Type, e.ID, attempts, lastError)
+		if attempts >= MaxAttempts {
+			_, err = tx.Exec(`UPDATE outbox SET attempts = $2, last_error = $3, failed_at = NOW() WHERE id = $1`, e.ID, attempts, lastError)
+		} else {
+			_, err = tx.Exec(`UPDATE outbox SET attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1`,
+				e.ID, attempts, lastError, time.Now().Add(backoff(attempts)))
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func call(ctx context.Context, s subscriber, e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return s.handler(ctx, e)
+}
+
+// backoff: 10 detik dikali dua tiap percobaan, maksimal 30 menit
+func backoff(attempt int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempt && d < 30*time.Minute; i++ {
+		d *= 2
+	}
+	if d > 30*time.Minute {
+		d = 30 * time.Minute
+	}
+	return d
+}
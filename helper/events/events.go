@@ -0,0 +1,135 @@
+// Package events menyimpan domain event ke tabel outbox di dalam transaksi yang sama dengan
+// perubahan datanya, lalu dispatcher mengirimkannya ke subscriber di dalam proses.
+// Pengiriman bersifat at-least-once: subscriber yang gagal dicoba lagi dengan backoff,
+// subscriber yang sudah berhasil dicatat di outbox_delivery dan tidak dipanggil ulang.
+// Subscriber tetap harus idempoten karena proses dapat berhenti sebelum pencatatan selesai.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Jenis domain event
+const (
+	OrderPlaced        = "OrderPlaced"
+	PaymentSubmitted   = "PaymentSubmitted"
+	PaymentApproved    = "PaymentApproved"
+	PaymentRejected    = "PaymentRejected"
+	InvoiceCancelled   = "InvoiceCancelled"
+	InvoiceExpired     = "InvoiceExpired"
+	OrderWeighed       = "OrderWeighed"
+	ShipmentDispatched = "ShipmentDispatched"
+	ShipmentDelivered  = "ShipmentDelivered"
+	FarmApproved       = "FarmApproved"
+	FarmNeedsInfo      = "FarmNeedsInfo"
+	FarmRejected       = "FarmRejected"
+	FarmInvited        = "FarmInvited"
+	ProductLowStock    = "ProductLowStock"
+)
+
+// Event adalah satu baris outbox
+type Event struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"` // mis. "invoice", "farm"
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// Handler memproses event; error menyebabkan event dikirim ulang ke subscriber ini
+type Handler func(ctx context.Context, e Event) error
+
+// Execer dipenuhi oleh *sql.Tx (dan *sql.DB untuk perubahan satu statement)
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[string][]subscriber{}
+)
+
+// Publish menulis event ke outbox. Panggil dengan transaksi yang sama dengan perubahan
+// datanya agar event hanya terbit jika perubahan tersebut ter-commit.
+func Publish(tx Execer, eventType, aggregateType string, aggregateID interface{}, payload interface{}) error {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("events: encode %s payload: %w", eventType, err)
+		}
+	}
+	query := `INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload, occurred_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())`
+	_, err := tx.Exec(query, eventType, aggregateType, fmt.Sprint(aggregateID), string(data))
+	return err
+}
+
+// Subscribe mendaftarkan handler bernama name untuk eventType. Nama dipakai untuk mencatat
+// pengiriman, jadi harus unik dan tetap sama antar deploy.
+func Subscribe(name, eventType string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	list := subscribers[eventType]
+	for i, s := range list {
+		if s.name == name {
+			list[i].handler = h
+			return
+		}
+	}
+	subscribers[eventType] = append(list, subscriber{name, h})
+}
+
+func subscribersFor(eventType string) []subscriber {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]subscriber(nil), subscribers[eventType]...)
+}
+
+// InvoicePayload adalah isi event yang berkaitan dengan satu invoice
+type InvoicePayload struct {
+	InvoiceID      int64   `json:"invoice_id"`
+	InvoiceNumber  string  `json:"invoice_number"`
+	CheckoutID     int64   `json:"checkout_id,omitempty"`
+	UserID         int64   `json:"user_id"` // Pembeli
+	FarmID         int64   `json:"farm_id,omitempty"`
+	TotalAmount    float64 `json:"total_amount"`
+	PaymentStatus  string  `json:"payment_status"`
+	ShipmentStatus string  `json:"shipment_status,omitempty"`
+	Adjustment     float64 `json:"adjustment_amount,omitempty"` // Selisih tagihan hasil penimbangan
+	Reason         string  `json:"reason,omitempty"`
+}
+
+// FarmPayload adalah isi event yang berkaitan dengan peternakan atau pengajuan peternak
+type FarmPayload struct {
+	FarmID        int64  `json:"farm_id,omitempty"`
+	UserID        int64  `json:"user_id"`
+	ApplicationID int64  `json:"application_id,omitempty"`
+	Reason        string `json:"reason,omitempty"` // Alasan penolakan atau informasi yang diminta
+	FarmName      string `json:"farm_name,omitempty"`
+	Role          string `json:"role,omitempty"` // Role anggota pada undangan
+	// Kontak undangan jika penerima belum memiliki akun (UserID 0)
+	InvitationID int64  `json:"invitation_id,omitempty"`
+	Email        string `json:"email,omitempty"`
+	NoTelp       string `json:"no_telp,omitempty"`
+}
+
+// ProductPayload adalah isi event stok produk
+type ProductPayload struct {
+	ProductID   int64   `json:"product_id"`
+	FarmID      int64   `json:"farm_id"`
+	Name        string  `json:"name"`
+	StockKg     float64 `json:"stock_kg"`
+	ThresholdKg float64 `json:"threshold_kg"`
+}
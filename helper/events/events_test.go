@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeReplacesByName(t *testing.T) {
+	var called string
+	Subscribe("test-a", "TestEvent", func(context.Context, Event) error { called = "first"; return nil })
+	Subscribe("test-a", "TestEvent", func(context.Context, Event) error { called = "second"; return nil })
+	Subscribe("test-b", "TestEvent", func(context.Context, Event) error { return nil })
+
+	subs := subscribersFor("TestEvent")
+	if len(subs) != 2 {
+		t.Fatalf("got %d subscribers, want 2", len(subs))
+	}
+	if err := call(context.Background(), subs[0], Event{}); err != nil || called != "second" {
+		t.Errorf("handler was not replaced: called=%q err=%v", called, err)
+	}
+}
+
+func TestCallRecoversPanic(t *testing.T) {
+	s := subscriber{"panic", func(context.Context, Event) error { panic("boom") }}
+	if err := call(context.Background(), s, Event{}); err == nil {
+		t.Error("panic should be returned as an error")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(1); got != 10*time.Second {
+		t.Errorf("backoff(1) = %v", got)
+	}
+	if got := backoff(3); got != 40*time.Second {
+		t.Errorf("backoff(3) = %v", got)
+	}
+	if got := backoff(20); got != 30*time.Minute {
+		t.Errorf("backoff(20) = %v", got)
+	}
+}
@@ -0,0 +1,249 @@
+// Package farmaccess menentukan hak akun atas peternakan berdasarkan keanggotaan (farm_member).
+// Setiap anggota memiliki satu role per peternakan; handler meminta Permission, bukan memeriksa
+// farms.owner_id, sehingga staf (manager, packer, cashier) dapat mengelola peternakan yang sama.
+package farmaccess
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Role anggota peternakan
+const (
+	RoleOwner   = "owner"
+	RoleManager = "manager"
+	RolePacker  = "packer"
+	RoleCashier = "cashier"
+)
+
+// Permission adalah tindakan pada peternakan yang dibatasi role
+type Permission string
+
+const (
+	ViewFarm       Permission = "farm.view"       // Melihat produk, pesanan, kurir dan pengiriman
+	ManageFarm     Permission = "farm.manage"     // Mengubah atau menghapus profil peternakan
+	ManageMembers  Permission = "members.manage"  // Mengundang, mengubah role dan mengeluarkan anggota
+	ManageWebhooks Permission = "webhooks.manage" // Mengelola endpoint webhook
+	ManageProducts Permission = "products.manage" // Membuat, mengubah dan menghapus produk
+	ManageCouriers Permission = "couriers.manage" // Mendaftarkan kurir peternakan
+	FulfilOrders   Permission = "orders.fulfil"   // Menimbang, mengemas dan mengirim pesanan
+	CancelOrders   Permission = "orders.cancel"   // Membatalkan invoice
+	VerifyPayments Permission = "payments.verify" // Memverifikasi bukti transfer
+)
+
+// grants adalah role yang memiliki tiap Permission. Owner selalu memiliki semua Permission.
+var grants = map[Permission][]string{
+	ViewFarm:       {RoleManager, RolePacker, RoleCashier},
+	ManageProducts: {RoleManager},
+	ManageCouriers: {RoleManager},
+	FulfilOrders:   {RoleManager, RolePacker},
+	CancelOrders:   {RoleManager},
+	VerifyPayments: {RoleManager, RoleCashier},
+}
+
+// Membership adalah keanggotaan satu akun pada satu peternakan
+type Membership struct {
+	FarmID int64  `json:"farm_id"`
+	AkunID int64  `json:"akun_id"`
+	Role   string `json:"role"`
+}
+
+// ErrNoFarm dikembalikan jika akun bukan anggota peternakan mana pun (atau peternakan yang diminta)
+var ErrNoFarm = errors.New("farmaccess: not a member of the farm")
+
+// ErrForbidden dikembalikan jika role akun tidak memiliki Permission yang diminta
+var ErrForbidden = errors.New("farmaccess: role does not allow this action")
+
+// ErrFarmRequired dikembalikan jika akun memiliki Permission pada beberapa peternakan tetapi
+// permintaan tidak menyebutkan peternakan yang dimaksud
+var ErrFarmRequired = errors.New("farmaccess: farm id is required for accounts with several farms")
+
+// FarmHeader adalah header berisi id peternakan yang sedang dipilih pengguna.
+// Parameter query farm_id dapat dipakai sebagai gantinya.
+const FarmHeader = "X-Farm-ID"
+
+// Queryer dipenuhi oleh *sql.DB dan *sql.Tx
+type Queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Execer dipenuhi oleh *sql.DB dan *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ValidRole melaporkan apakah role dikenal
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleManager || role == RolePacker || role == RoleCashier
+}
+
+// Allowed melaporkan apakah role memiliki Permission p
+func Allowed(role string, p Permission) bool {
+	if role == RoleOwner {
+		return true
+	}
+	for _, r := range grants[p] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesWith mengembalikan role yang memiliki p dalam bentuk "owner,manager", untuk dipakai
+// dalam SQL sebagai role = ANY(string_to_array($n, ','))
+func RolesWith(p Permission) string {
+	return strings.Join(append([]string{RoleOwner}, grants[p]...), ",")
+}
+
+// RequestedFarm membaca id peternakan dari header X-Farm-ID atau parameter query farm_id.
+// ok false berarti permintaan tidak menyebutkan peternakan; nilai yang tidak valid menjadi id 0.
+func RequestedFarm(r *http.Request) (farmID int64, ok bool) {
+	v := strings.TrimSpace(r.Header.Get(FarmHeader))
+	if v == "" {
+		v = strings.TrimSpace(r.URL.Query().Get("farm_id"))
+	}
+	if v == "" {
+		return 0, false
+	}
+	farmID, _ = strconv.ParseInt(v, 10, 64)
+	return farmID, true
+}
+
+// ForRequest menentukan peternakan untuk endpoint yang dibatasi peternakan. Jika permintaan
+// menyebutkan peternakan (RequestedFarm), keanggotaan pada peternakan itu diperiksa dengan Check.
+// Jika tidak, peternakan dipilih otomatis hanya bila akun memiliki Permission p pada tepat satu
+// peternakan; selebihnya ErrFarmRequired.
+func ForRequest(q Queryer, r *http.Request, akunID int64, p Permission) (Membership, error) {
+	if farmID, ok := RequestedFarm(r); ok {
+		return Check(q, akunID, farmID, p)
+	}
+	return only(q, `m.akun_id = $1`, akunID, p)
+}
+
+// ForRequestPhone seperti ForRequest untuk akun dengan nomor telepon noTelp (payload token)
+func ForRequestPhone(q Queryer, r *http.Request, noTelp string, p Permission) (Membership, error) {
+	if farmID, ok := RequestedFarm(r); ok {
+		return CheckPhone(q, noTelp, farmID, p)
+	}
+	return only(q, `m.akun_id = (SELECT id_user FROM akun WHERE no_telp = $1)`, noTelp, p)
+}
+
+func only(q Queryer, who string, key interface{}, p Permission) (Membership, error) {
+	m, n, err := first(q, who, key, p)
+	if err == nil && n > 1 {
+		return Membership{}, ErrFarmRequired
+	}
+	return m, err
+}
+
+// first mengembalikan keanggotaan pertama yang memiliki p beserta jumlah seluruh keanggotaan tersebut
+func first(q Queryer, who string, key interface{}, p Permission) (Membership, int, error) {
+	var m Membership
+	var n int
+	query := `SELECT m.farm_id, m.akun_id, m.role, COUNT(*) OVER () FROM farm_member m
+		WHERE ` + who + ` AND m.role = ANY(string_to_array($2, ','))
+		ORDER BY (m.role = 'owner') DESC, m.created_at, m.farm_id
+		LIMIT 1`
+	err := q.QueryRow(query, key, RolesWith(p)).Scan(&m.FarmID, &m.AkunID, &m.Role, &n)
+	if err != sql.ErrNoRows {
+		return m, n, err
+	}
+	// Bedakan akun tanpa peternakan dengan anggota yang role-nya tidak cukup
+	var member bool
+	query = `SELECT EXISTS (SELECT 1 FROM farm_member m WHERE ` + who + `)`
+	if err := q.QueryRow(query, key).Scan(&member); err != nil {
+		return m, 0, err
+	}
+	if member {
+		return m, 0, ErrForbidden
+	}
+	return m, 0, ErrNoFarm
+}
+
+// Check memastikan akun memiliki Permission p pada peternakan farmID
+func Check(q Queryer, akunID, farmID int64, p Permission) (Membership, error) {
+	return check(q, `akun_id = $2`, akunID, farmID, p)
+}
+
+// CheckPhone seperti Check untuk akun dengan nomor telepon noTelp (payload token)
+func CheckPhone(q Queryer, noTelp string, farmID int64, p Permission) (Membership, error) {
+	return check(q, `akun_id = (SELECT id_user FROM akun WHERE no_telp = $2)`, noTelp, farmID, p)
+}
+
+func check(q Queryer, who string, key interface{}, farmID int64, p Permission) (Membership, error) {
+	m := Membership{FarmID: farmID}
+	err := q.QueryRow(`SELECT akun_id, role FROM farm_member WHERE farm_id = $1 AND `+who, farmID, key).Scan(&m.AkunID, &m.Role)
+	if err == sql.ErrNoRows {
+		return m, ErrNoFarm
+	}
+	if err != nil {
+		return m, err
+	}
+	if !Allowed(m.Role, p) {
+		return m, ErrForbidden
+	}
+	return m, nil
+}
+
+// AddMember menambahkan akun ke peternakan, atau mengganti role-nya jika sudah menjadi anggota.
+// invitedBy 0 berarti tanpa pengundang, mis. owner saat peternakan dibuat.
+func AddMember(tx Execer, farmID, akunID int64, role string, invitedBy int64) error {
+	query := `INSERT INTO farm_member (farm_id, akun_id, role, invited_by, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NOW())
+		ON CONFLICT (farm_id, akun_id) DO UPDATE SET role = EXCLUDED.role`
+	_, err := tx.Exec(query, farmID, akunID, role, invitedBy)
+	return err
+}
+
+// Members mengembalikan akun anggota farmID yang memiliki Permission p, mis. penerima notifikasi
+func Members(ctx context.Context, db *sql.DB, farmID int64, p Permission) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT akun_id FROM farm_member
+		WHERE farm_id = $1 AND role = ANY(string_to_array($2, ',')) ORDER BY akun_id`, farmID, RolesWith(p))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// WriteError menulis respons JSON untuk error dari ForRequest, ForRequestPhone, Check atau CheckPhone
+func WriteError(w http.ResponseWriter, err error) {
+	status, body := http.StatusInternalServerError, map[string]string{
+		"error":   "Internal server error",
+		"message": "Failed to check farm access.",
+	}
+	switch {
+	case errors.Is(err, ErrNoFarm):
+		status, body = http.StatusNotFound, map[string]string{
+			"error":   "Farm not found",
+			"message": "No farm found for the current user.",
+		}
+	case errors.Is(err, ErrFarmRequired):
+		status, body = http.StatusBadRequest, map[string]string{
+			"error":   "Farm required",
+			"message": "You belong to several farms. Choose one with the X-Farm-ID header or the farm_id parameter.",
+		}
+	case errors.Is(err, ErrForbidden):
+		status, body = http.StatusForbidden, map[string]string{
+			"error":   "Forbidden",
+			"message": "Your role in this farm does not allow this action.",
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,82 @@
+package farmaccess
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleOwner, ManageMembers, true},
+		{RoleOwner, ManageWebhooks, true},
+		{RoleManager, ManageProducts, true},
+		{RoleManager, ManageMembers, false},
+		{RolePacker, FulfilOrders, true},
+		{RolePacker, VerifyPayments, false},
+		{RoleCashier, VerifyPayments, true},
+		{RoleCashier, FulfilOrders, false},
+		{RoleCashier, ViewFarm, true},
+		{"viewer", ViewFarm, false},
+	}
+	for _, c := range cases {
+		if got := Allowed(c.role, c.perm); got != c.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", c.role, c.perm, got, c.want)
+		}
+	}
+}
+
+func TestRolesWith(t *testing.T) {
+	if got := RolesWith(VerifyPayments); got != "owner,manager,cashier" {
+		t.Errorf("RolesWith(VerifyPayments) = %q", got)
+	}
+	if got := RolesWith(ManageFarm); got != "owner" {
+		t.Errorf("RolesWith(ManageFarm) = %q", got)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	cases := map[error]int{
+		ErrNoFarm:                            http.StatusNotFound,
+		ErrFarmRequired:                      http.StatusBadRequest,
+		fmt.Errorf("wrap: %w", ErrForbidden): http.StatusForbidden,
+		errors.New("connection reset"):       http.StatusInternalServerError,
+	}
+	for err, want := range cases {
+		rec := httptest.NewRecorder()
+		WriteError(rec, err)
+		if rec.Code != want {
+			t.Errorf("WriteError(%v) status = %d, want %d", err, rec.Code, want)
+		}
+	}
+}
+
+func TestRequestedFarm(t *testing.T) {
+	cases := []struct {
+		header, query string
+		want          int64
+		ok            bool
+	}{
+		{"", "", 0, false},
+		{"7", "", 7, true},
+		{"", "12", 12, true},
+		{"7", "12", 7, true},
+		{"abc", "", 0, true},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/product/mine?farm_id="+c.query, nil)
+		if c.header != "" {
+			r.Header.Set(FarmHeader, c.header)
+		}
+		got, ok := RequestedFarm(r)
+		if got != c.want || ok != c.ok {
+			t.Errorf("RequestedFarm(header %q, query %q) = %d, %v; want %d, %v", c.header, c.query, got, ok, c.want, c.ok)
+		}
+	}
+}
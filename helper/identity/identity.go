@@ -0,0 +1,75 @@
+// Package identity berisi pemeriksaan hak akses akun yang dipakai lintas controller
+package identity
+
+import (
+	"database/sql"
+	"errors"
+	"farmdistribution_be/config"
+	"farmdistribution_be/helper/at"
+	"farmdistribution_be/helper/watoken"
+	"net/http"
+	"strings"
+)
+
+// IsAdmin memeriksa apakah akun dengan nomor telepon noTelp memiliki role admin
+func IsAdmin(db *sql.DB, noTelp string) (bool, error) {
+	var roleName sql.NullString
+	query := `SELECT r.name_role FROM akun a LEFT JOIN role r ON r.id_role = a.id_role WHERE a.no_telp = $1`
+	err := db.QueryRow(query, noTelp).Scan(&roleName)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(roleName.String, config.AdminRoleName), nil
+}
+
+// Jenis identitas pemanggil
+const (
+	TypeAkun     = "akun"
+	TypePengirim = "pengirim"
+)
+
+// Caller adalah pemilik token login, baik akun (pembeli/peternak/admin) maupun pengirim
+type Caller struct {
+	Type   string
+	ID     int64
+	NoTelp string
+}
+
+// ErrUnauthorized dikembalikan jika token tidak valid atau kedaluwarsa
+var ErrUnauthorized = errors.New("invalid or expired token")
+
+// ErrNotFound dikembalikan jika pemilik token tidak ditemukan
+var ErrNotFound = errors.New("caller not found")
+
+// FromRequest membaca token login dari header dan mencari pemiliknya.
+// Akun diperiksa lebih dulu, sama seperti urutan pada login.
+func FromRequest(db *sql.DB, r *http.Request) (Caller, error) {
+	return FromToken(db, at.GetLoginFromHeader(r))
+}
+
+// FromToken seperti FromRequest untuk token yang tidak dikirim lewat header,
+// mis. query ?login= pada EventSource yang tidak bisa mengatur header
+func FromToken(db *sql.DB, token string) (Caller, error) {
+	payload, err := watoken.Decode(config.PUBLICKEY, token)
+	if err != nil {
+		return Caller{}, ErrUnauthorized
+	}
+	return Lookup(db, payload.Id)
+}
+
+// Lookup mencari akun atau pengirim dengan nomor telepon noTelp
+func Lookup(db *sql.DB, noTelp string) (Caller, error) {
+	c := Caller{Type: TypeAkun, NoTelp: noTelp}
+	err := db.QueryRow(`SELECT id_user FROM akun WHERE no_telp = $1`, noTelp).Scan(&c.ID)
+	if err == sql.ErrNoRows {
+		c.Type = TypePengirim
+		err = db.QueryRow(`SELECT id FROM pengirim WHERE phone = $1`, noTelp).Scan(&c.ID)
+	}
+	if err == sql.ErrNoRows {
+		return Caller{}, ErrNotFound
+	}
+	return c, err
+}
@@ -0,0 +1,199 @@
+// Package inventory mencatat setiap perubahan stok produk ke ledger inventory_movements.
+// farm_products.stock_kg adalah saldo berjalan ledger: Move mengubah saldo dan mencatat
+// mutasinya di transaksi yang sama, sehingga peternakan dapat menelusuri sebab perubahan stok
+// dan saldo selalu bisa direkonsiliasi dengan jumlah mutasi.
+package inventory
+
+import (
+	"database/sql"
+	"errors"
+	"farmdistribution_be/helper/qty"
+	"fmt"
+)
+
+// Jenis mutasi stok
+const (
+	Restock            = "restock"             // Stok masuk dari panen atau pembelian
+	Sale               = "sale"                // Stok keluar karena order (termasuk koreksi berat timbang)
+	CancellationReturn = "cancellation_return" // Stok kembali karena invoice dibatalkan atau kedaluwarsa
+	Spoilage           = "spoilage"            // Stok rusak, mati atau busuk
+	Adjustment         = "adjustment"          // Koreksi manual hasil stock opname atau rekonsiliasi
+)
+
+// Types adalah semua jenis mutasi yang dikenal
+var Types = []string{Restock, Sale, CancellationReturn, Spoilage, Adjustment}
+
+// ErrNotFound dikembalikan jika produk tidak ada
+var ErrNotFound = errors.New("inventory: product not found")
+
+// ErrInsufficient dikembalikan jika mutasi keluar melebihi stok
+var ErrInsufficient = errors.New("inventory: insufficient stock")
+
+// Movement adalah satu mutasi stok yang akan dicatat
+type Movement struct {
+	ProductID  int64
+	Type       string
+	QuantityKg qty.Qty // Positif menambah stok, negatif mengurangi
+	Reason     string
+	ActorType  string // akun, pengirim atau system
+	ActorID    int64  // 0 untuk system
+	RefType    string // mis. "invoice" atau "order"; kosong jika tanpa referensi
+	RefID      int64
+	// Clamp memotong mutasi keluar sampai stok nol alih-alih mengembalikan ErrInsufficient
+	Clamp bool
+}
+
+// Result adalah keadaan produk setelah mutasi
+type Result struct {
+	FarmID     int64
+	AppliedKg  qty.Qty // Mutasi yang benar-benar dicatat; dapat lebih kecil dari QuantityKg jika Clamp
+	BalanceKg  qty.Qty
+	LowStockKg sql.NullFloat64 // Batas stok menipis produk, jika dipantau
+}
+
+// Queryer dipenuhi oleh *sql.Tx dan *sql.DB
+type Queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ValidType melaporkan apakah t adalah jenis mutasi yang dikenal
+func ValidType(t string) bool {
+	for _, v := range Types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckQuantity memastikan arah mutasi sesuai jenisnya: restock dan cancellation_return
+// selalu menambah, spoilage selalu mengurangi, sale dan adjustment boleh keduanya.
+func CheckQuantity(t string, q qty.Qty) error {
+	if !ValidType(t) {
+		return fmt.Errorf("unknown movement type %q", t)
+	}
+	switch {
+	case q == 0:
+		return errors.New("quantity must not be zero")
+	case (t == Restock || t == CancellationReturn) && q < 0:
+		return fmt.Errorf("%s quantity must be positive", t)
+	case t == Spoilage && q > 0:
+		return fmt.Errorf("%s quantity must be negative", t)
+	}
+	return nil
+}
+
+// apply menghitung saldo baru dan mutasi yang dicatat dari saldo saat ini
+func apply(current, q qty.Qty, clamp bool) (balance, applied qty.Qty, err error) {
+	balance = current + q
+	if balance < 0 {
+		if !clamp {
+			return current, 0, ErrInsufficient
+		}
+		balance = 0
+	}
+	return balance, balance - current, nil
+}
+
+// Move mengunci produk, mengubah stok dan mencatat mutasinya. Panggil di dalam transaksi yang
+// sama dengan perubahan penyebabnya (order, pembatalan, penyuntingan produk).
+// Mutasi yang terpotong habis oleh Clamp tidak dicatat.
+func Move(tx Queryer, m Movement) (Result, error) {
+	var res Result
+	if err := CheckQuantity(m.Type, m.QuantityKg); err != nil {
+		return res, err
+	}
+
+	var current qty.Qty
+	err := tx.QueryRow(`SELECT farm_id, stock_kg, low_stock_threshold_kg FROM farm_products WHERE id = $1 FOR UPDATE`, m.ProductID).
+		Scan(&res.FarmID, &current, &res.LowStockKg)
+	if err == sql.ErrNoRows {
+		return res, ErrNotFound
+	}
+	if err != nil {
+		return res, err
+	}
+
+	res.BalanceKg, res.AppliedKg, err = apply(current, m.QuantityKg, m.Clamp)
+	if err != nil || res.AppliedKg == 0 {
+		res.BalanceKg = current
+		return res, err
+	}
+
+	if _, err := tx.Exec(`UPDATE farm_products SET stock_kg = $2, updated_at = NOW() WHERE id = $1`, m.ProductID, res.BalanceKg); err != nil {
+		return res, err
+	}
+	_, err = tx.Exec(`INSERT INTO inventory_movements
+		(product_id, farm_id, type, quantity_kg, balance_kg, reason, actor_type, actor_id, reference_type, reference_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, 0), NULLIF($9, ''), NULLIF($10, 0), NOW())`,
+		m.ProductID, res.FarmID, m.Type, res.AppliedKg, res.BalanceKg, m.Reason, actorType(m.ActorType), m.ActorID, m.RefType, m.RefID)
+	return res, err
+}
+
+func actorType(t string) string {
+	if t == "" {
+		return "system"
+	}
+	return t
+}
+
+// Reconciliation membandingkan stok produk dengan jumlah seluruh mutasinya
+type Reconciliation struct {
+	StockKg      qty.Qty `json:"stock_kg"`
+	LedgerKg     qty.Qty `json:"ledger_kg"`
+	DifferenceKg qty.Qty `json:"difference_kg"` // stock_kg - ledger_kg
+	InSync       bool    `json:"in_sync"`
+}
+
+// Reconcile menghitung Reconciliation satu produk
+func Reconcile(q Queryer, productID int64) (Reconciliation, error) {
+	var rec Reconciliation
+	err := q.QueryRow(`SELECT fp.stock_kg, COALESCE((SELECT SUM(m.quantity_kg) FROM inventory_movements m WHERE m.product_id = fp.id), 0)
+		FROM farm_products fp WHERE fp.id = $1`, productID).Scan(&rec.StockKg, &rec.LedgerKg)
+	if err == sql.ErrNoRows {
+		return rec, ErrNotFound
+	}
+	rec.DifferenceKg = rec.StockKg - rec.LedgerKg
+	rec.InSync = rec.DifferenceKg == 0
+	return rec, err
+}
+
+// Drift adalah produk yang stoknya berbeda dari jumlah ledger
+type Drift struct {
+	ProductID int64 `json:"product_id"`
+	FarmID    int64 `json:"farm_id"`
+	Reconciliation
+}
+
+// Lister dipenuhi oleh *sql.DB dan *sql.Tx
+type Lister interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ReconcileAll melaporkan setiap produk yang stoknya berbeda dari jumlah ledger (mis. karena
+// diubah langsung di database). Tidak ada mutasi yang dicatat: selisih perlu ditelusuri lalu
+// dikoreksi dengan mutasi adjustment yang beralasan.
+func ReconcileAll(db Lister) ([]Drift, error) {
+	rows, err := db.Query(`SELECT fp.id, fp.farm_id, fp.stock_kg, COALESCE(l.total, 0)
+		FROM farm_products fp
+		LEFT JOIN (SELECT product_id, SUM(quantity_kg) AS total FROM inventory_movements GROUP BY product_id) l
+			ON l.product_id = fp.id
+		WHERE fp.stock_kg <> COALESCE(l.total, 0)
+		ORDER BY fp.farm_id, fp.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drifts := []Drift{}
+	for rows.Next() {
+		var d Drift
+		if err := rows.Scan(&d.ProductID, &d.FarmID, &d.StockKg, &d.LedgerKg); err != nil {
+			return nil, err
+		}
+		d.DifferenceKg = d.StockKg - d.LedgerKg
+		drifts = append(drifts, d)
+	}
+	return drifts, rows.Err()
+}
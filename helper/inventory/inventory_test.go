@@ -0,0 +1,54 @@
+package inventory
+
+import (
+	"farmdistribution_be/helper/qty"
+	"testing"
+)
+
+func TestCheckQuantity(t *testing.T) {
+	tests := []struct {
+		typ   string
+		q     string
+		valid bool
+	}{
+		{Restock, "5", true},
+		{Restock, "-5", false},
+		{CancellationReturn, "1.5", true},
+		{CancellationReturn, "-1.5", false},
+		{Spoilage, "-2", true},
+		{Spoilage, "2", false},
+		{Sale, "-3", true},
+		{Sale, "0.25", true},
+		{Adjustment, "-0.5", true},
+		{Adjustment, "0", false},
+		{"theft", "-1", false},
+	}
+	for _, tt := range tests {
+		err := CheckQuantity(tt.typ, qty.MustParse(tt.q))
+		if (err == nil) != tt.valid {
+			t.Errorf("CheckQuantity(%q, %s) error = %v, want valid %v", tt.typ, tt.q, err, tt.valid)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		current, q     string
+		clamp          bool
+		balance, moved string
+		err            error
+	}{
+		{"10", "2.5", false, "12.5", "2.5", nil},
+		{"10", "-10", false, "0", "-10", nil},
+		{"10", "-10.001", false, "10", "0", ErrInsufficient},
+		{"10", "-12", true, "0", "-10", nil},
+		{"0", "-1", true, "0", "0", nil},
+	}
+	for _, tt := range tests {
+		balance, applied, err := apply(qty.MustParse(tt.current), qty.MustParse(tt.q), tt.clamp)
+		if err != tt.err || balance != qty.MustParse(tt.balance) || applied != qty.MustParse(tt.moved) {
+			t.Errorf("apply(%s, %s, %v) = %s, %s, %v; want %s, %s, %v",
+				tt.current, tt.q, tt.clamp, balance, applied, err, tt.balance, tt.moved, tt.err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package invoicepdf menyusun tata letak invoice/kwitansi di atas helper/pdf
+package invoicepdf
+
+import (
+	"farmdistribution_be/helper/format"
+	"farmdistribution_be/helper/pdf"
+	"farmdistribution_be/helper/qrcode"
+	"time"
+)
+
+type Party struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+}
+
+type Line struct {
+	Name      string
+	Quantity  string
+	Unit      string
+	UnitPrice float64
+	Total     float64
+}
+
+type Invoice struct {
+	Number        string
+	CheckoutNo    string
+	IssuedDate    time.Time
+	DueDate       time.Time
+	PaymentMethod string
+	PaymentStatus string
+	Farm          Party
+	Buyer         Party
+	Lines         []Line
+	Subtotal      float64
+	Shipping      float64
+	Discount      float64
+	Tax           float64
+	Total         float64
+	VerifyURL     string // Isi QR code verifikasi, kosong berarti tanpa QR
+}
+
+const (
+	marginLeft  = 40.0
+	marginRight = pdf.A4Width - 40.0
+	bottomLimit = pdf.A4Height - 60.0
+)
+
+func rupiah(v float64) string {
+	return format.FormatCurrency(v)
+}
+
+// Render menghasilkan PDF invoice. Dokumen disebut kwitansi (receipt) jika sudah lunas.
+func Render(inv Invoice) ([]byte, error) {
+	title := "INVOICE"
+	if inv.PaymentStatus == "Paid" {
+		title = "KWITANSI"
+	}
+
+	doc := pdf.New(title + " " + inv.Number)
+	page := doc.AddPage()
+
+	page.Text(marginLeft, 60, 22, true, title)
+	page.Text(marginLeft, 80, 10, false, inv.Number)
+	if inv.CheckoutNo != "" {
+		page.Text(marginLeft, 94, 9, false, "Checkout: "+inv.CheckoutNo)
+	}
+
+	page.TextRight(marginRight, 60, 10, true, "Status: "+inv.PaymentStatus)
+	page.TextRight(marginRight, 76, 9, false, "Tanggal: "+inv.IssuedDate.Format("02 Jan 2006"))
+	page.TextRight(marginRight, 90, 9, false, "Jatuh tempo: "+inv.DueDate.Format("02 Jan 2006"))
+	if inv.PaymentMethod != "" {
+		page.TextRight(marginRight, 104, 9, false, "Pembayaran: "+inv.PaymentMethod)
+	}
+
+	page.Line(marginLeft, 116, marginRight, 116, 0.8)
+	drawParty(page, marginLeft, 136, "Penjual", inv.Farm)
+	drawParty(page, 320, 136, "Pembeli", inv.Buyer)
+
+	// Tabel produk
+	y := 220.0
+	page.Rect(marginLeft, y-14, marginRight-marginLeft, 20, true, 0.9)
+	page.Text(marginLeft+6, y, 9, true, "Produk")
+	page.TextRight(330, y, 9, true, "Jumlah")
+	page.TextRight(440, y, 9, true, "Harga Satuan")
+	page.TextRight(marginRight-6, y, 9, true, "Total")
+	y += 22
+
+	for _, line := range inv.Lines {
+		if y > bottomLimit {
+			page = doc.AddPage()
+			y = 60
+		}
+		page.Text(marginLeft+6, y, 9, false, line.Name)
+		page.TextRight(330, y, 9, false, line.Quantity+" "+line.Unit)
+		page.TextRight(440, y, 9, false, rupiah(line.UnitPrice))
+		page.TextRight(marginRight-6, y, 9, false, rupiah(line.Total))
+		y += 16
+	}
+
+	if y+140 > bottomLimit {
+		page = doc.AddPage()
+		y = 60
+	}
+	page.Line(marginLeft, y, marginRight, y, 0.5)
+	y += 18
+
+	summaryTop := y
+	rows := []struct {
+		label string
+		value float64
+	}{
+		{"Subtotal", inv.Subtotal},
+		{"Ongkos kirim", inv.Shipping},
+		{"Diskon", -inv.Discount},
+		{"Pajak", inv.Tax},
+	}
+	for _, row := range rows {
+		page.Text(340, y, 9, false, row.label)
+		page.TextRight(marginRight-6, y, 9, false, rupiah(row.value))
+		y += 15
+	}
+	page.Line(340, y-8, marginRight, y-8, 0.5)
+	y += 6
+	page.Text(340, y, 11, true, "Total")
+	page.TextRight(marginRight-6, y, 11, true, rupiah(inv.Total))
+
+	if inv.VerifyURL != "" {
+		code, err := qrcode.Encode([]byte(inv.VerifyURL))
+		if err != nil {
+			return nil, err
+		}
+		drawQR(page, marginLeft, summaryTop-10, 96, code)
+		page.Text(marginLeft, summaryTop+100, 7, false, "Pindai untuk memverifikasi invoice")
+	}
+
+	return doc.Bytes(), nil
+}
+
+func drawParty(page *pdf.Page, x, y float64, label string, p Party) {
+	page.Text(x, y, 9, true, label)
+	y += 14
+	for _, s := range []string{p.Name, p.Address, p.Phone, p.Email} {
+		if s == "" {
+			continue
+		}
+		page.Text(x, y, 9, false, s)
+		y += 12
+	}
+}
+
+// drawQR menggambar QR code dengan quiet zone 4 modul pada kotak berukuran size
+func drawQR(page *pdf.Page, x, y, size float64, code *qrcode.Code) {
+	module := size / float64(code.Size+8)
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if code.Modules[row][col] {
+				page.Rect(x+float64(col+4)*module, y+float64(row+4)*module, module, module, true, 0)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	issued := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	inv := Invoice{
+		Number:        "INV/F0001/2026/10/000001",
+		IssuedDate:    issued,
+		DueDate:       issued.AddDate(0, 0, 7),
+		PaymentMethod: "Transfer",
+		PaymentStatus: "Pending",
+		Farm:          Party{Name: "Peternakan Sumber Rejeki", Phone: "08123"},
+		Buyer:         Party{Name: "Budi", Address: "Jl. Merdeka 1"},
+		Lines: []Line{
+			{Name: "Ayam Broiler", Quantity: "2.5", Unit: "kg", UnitPrice: 35000, Total: 87500},
+		},
+		Subtotal:  87500,
+		Shipping:  10000,
+		Total:     97500,
+		VerifyURL: "https://example.com/invoice/verify/abc",
+	}
+
+	out, err := Render(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Render(inv)
+	if !bytes.Equal(out, again) {
+		t.Fatal("rendering is not deterministic")
+	}
+
+	for _, want := range []string{
+		"(INVOICE) Tj",
+		"(INV/F0001/2026/10/000001) Tj",
+		"(Ayam Broiler) Tj",
+		"(2.5 kg) Tj",
+		"(Rp.97,500.00) Tj",
+		"(Status: Pending) Tj",
+		" re f",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+
+	inv.PaymentStatus = "Paid"
+	out, _ = Render(inv)
+	if !bytes.Contains(out, []byte("(KWITANSI) Tj")) {
+		t.Error("paid invoice should render as a receipt")
+	}
+}
@@ -0,0 +1,103 @@
+// Package jobs menjalankan pekerjaan latar belakang dari antrean di Postgres.
+// Job diambil dengan FOR UPDATE SKIP LOCKED sehingga beberapa worker (di dalam server
+// maupun proses worker terpisah) dapat berjalan bersamaan tanpa memproses job yang sama.
+// Job yang gagal diulang dengan backoff eksponensial, lalu dipindahkan ke job_dead_letter.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Handler memproses satu job; error menyebabkan job diulang
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Execer dipenuhi oleh *sql.DB maupun *sql.Tx, sehingga job dapat dibuat di dalam transaksi
+type Execer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type schedule struct {
+	name string
+	kind string
+	cron *Cron
+}
+
+var (
+	mu        sync.RWMutex
+	handlers  = map[string]Handler{}
+	schedules []schedule
+)
+
+// DefaultMaxAttempts adalah jumlah percobaan sebelum job masuk dead letter
+const DefaultMaxAttempts = 5
+
+// Register mendaftarkan handler untuk jenis job kind
+func Register(kind string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[kind] = h
+}
+
+// Schedule mendaftarkan job berulang bernama name yang membuat job kind sesuai ekspresi cron
+func Schedule(name, spec, kind string) error {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, s := range schedules {
+		if s.name == name {
+			schedules[i] = schedule{name, kind, c}
+			return nil
+		}
+	}
+	schedules = append(schedules, schedule{name, kind, c})
+	return nil
+}
+
+func handlerFor(kind string) (Handler, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	h, ok := handlers[kind]
+	return h, ok
+}
+
+func registeredSchedules() []schedule {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]schedule(nil), schedules...)
+}
+
+// Enqueue menambahkan job ke antrean untuk dijalankan paling cepat pada runAt
+func Enqueue(db Execer, kind string, payload interface{}, runAt time.Time) (int64, error) {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return 0, fmt.Errorf("jobs: encode payload: %w", err)
+		}
+	}
+	var id int64
+	query := `INSERT INTO job (kind, payload, run_at, max_attempts)
+		VALUES ($1, $2, $3, $4) RETURNING id`
+	err := db.QueryRow(query, kind, string(data), runAt, DefaultMaxAttempts).Scan(&id)
+	return id, err
+}
+
+// Backoff adalah jeda sebelum percobaan berikutnya: 30 detik dikali dua tiap percobaan, maksimal 1 jam
+func Backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
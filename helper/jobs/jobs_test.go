@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, time.January, 30, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 30, 10, 8, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2026, 1, 30, 10, 10, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2026, 1, 30, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 1-5", time.Date(2026, 2, 2, 6, 30, 0, 0, time.UTC)}, // Jumat -> Senin
+		{"0 0 30 * *", time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{"0 8 1 * 0", time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC)}, // tanggal 1 atau hari Minggu
+		{"15,45 9-10 * * 7", time.Date(2026, 2, 1, 9, 15, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.spec, err)
+		}
+		if got := c.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	c, _ := ParseCron("0 0 30 2 *")
+	if got := c.Next(base); !got.IsZero() {
+		t.Errorf("impossible schedule: Next = %v, want zero", got)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) should fail", spec)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		20: time.Hour,
+	}
+	for attempt, want := range tests {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Worker mengambil dan menjalankan job dari antrean
+type Worker struct {
+	DB           *sql.DB
+	Concurrency  int           // Jumlah job yang berjalan bersamaan, default 1
+	PollInterval time.Duration // Jeda saat antrean kosong, default 5 detik
+	Timeout      time.Duration // Batas waktu satu job, default 10 menit
+}
+
+// lease adalah batas job berstatus running dianggap terputus (mis. proses worker mati)
+const lease = 30 * time.Minute
+
+type claimedJob struct {
+	id          int64
+	kind        string
+	payload     json.RawMessage
+	attempts    int
+	maxAttempts int
+}
+
+// Run menjalankan penjadwal dan worker sampai ctx selesai
+func (w *Worker) Run(ctx context.Context) {
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if err := syncSchedules(w.DB, time.Now()); err != nil {
+		log.Println("[ERROR] jobs: failed to sync schedules:", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency + 1)
+	go func() {
+		defer wg.Done()
+		w.scheduleLoop(ctx)
+	}()
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			w.pollLoop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) pollInterval() time.Duration {
+	if w.PollInterval > 0 {
+		return w.PollInterval
+	}
+	return 5 * time.Second
+}
+
+func (w *Worker) pollLoop(ctx context.Context) {
+	for {
+		ran, err := w.RunNext(ctx)
+		if err != nil {
+			log.Println("[ERROR] jobs:", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.pollInterval()):
+		}
+	}
+}
+
+// RunNext menjalankan satu job yang sudah jatuh tempo; false berarti antrean kosong
+func (w *Worker) RunNext(ctx context.Context) (bool, error) {
+	job, err := claim(w.DB)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	runErr := execute(jobCtx, job)
+	cancel()
+
+	if runErr == nil {
+		_, err = w.DB.Exec(`UPDATE job SET status = 'done', last_error = NULL, finished_at = NOW(), updated_at = NOW() WHERE id = $1`, job.id)
+		return true, err
+	}
+
+	log.Printf("[ERROR] jobs: %s #%d attempt %d/%d failed: %v", job.kind, job.id, job.attempts, job.maxAttempts, runErr)
+	if job.attempts >= job.maxAttempts {
+		return true, deadLetter(w.DB, job, runErr)
+	}
+	query := `UPDATE job SET status = 'queued', run_at = $2, last_error = $3, locked_at = NULL, updated_at = NOW() WHERE id = $1`
+	_, err = w.DB.Exec(query, job.id, time.Now().Add(Backoff(job.attempts)), runErr.Error())
+	return true, err
+}
+
+// claim mengambil satu job dan menandainya running; baris yang sedang dikunci worker lain dilewati
+func claim(db *sql.DB) (*claimedJob, error) {
+	var job claimedJob
+	var payload []byte
+	query := `UPDATE job SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM job
+			WHERE status = 'queued' AND run_at <= NOW()
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts, max_attempts`
+	err := db.QueryRow(query).Scan(&job.id, &job.kind, &payload, &job.attempts, &job.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	job.payload = payload
+	return &job, nil
+}
+
+func execute(ctx context.Context, job *claimedJob) (err error) {
+	h, ok := handlerFor(job.kind)
+	if !ok {
+		return fmt.Errorf("no handler registered for %q", job.kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job.payload)
+}
+
+func deadLetter(db *sql.DB, job *claimedJob, cause error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO job_dead_letter (job_id, kind, payload, attempts, last_error, failed_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())`
+	if _, err := tx.Exec(query, job.id, job.kind, string(job.payload), job.attempts, cause.Error()); err != nil {
+		return err
+	}
+	query = `UPDATE job SET status = 'dead', last_error = $2, finished_at = NOW(), updated_at = NOW() WHERE id = $1`
+	if _, err := tx.Exec(query, job.id, cause.Error()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (w *Worker) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := fireSchedules(w.DB, time.Now()); err != nil {
+			log.Println("[ERROR] jobs: schedule:", err)
+		}
+		if err := requeueStale(w.DB); err != nil {
+			log.Println("[ERROR] jobs: requeue:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncSchedules menyimpan jadwal terdaftar ke job_schedule; next_run_at dihitung ulang jika ekspresi berubah
+func syncSchedules(db *sql.DB, now time.Time) error {
+	for _, s := range registeredSchedules() {
+		query := `INSERT INTO job_schedule (name, kind, next_run_at, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (name) DO UPDATE SET kind = EXCLUDED.kind,
+				next_run_at = LEAST(job_schedule.next_run_at, EXCLUDED.next_run_at), updated_at = NOW()`
+		if _, err := db.Exec(query, s.name, s.kind, s.cron.Next(now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireSchedules membuat job untuk jadwal yang sudah jatuh tempo. Baris jadwal dikunci
+// selama transaksi, jadi setiap jadwal hanya menghasilkan satu job walau banyak worker berjalan.
+func fireSchedules(db *sql.DB, now time.Time) error {
+	for _, s := range registeredSchedules() {
+		if err := fireSchedule(db, s, now); err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+func fireSchedule(db *sql.DB, s schedule, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var due time.Time
+	query := `SELECT next_run_at FROM job_schedule WHERE name = $1 AND next_run_at <= $2 FOR UPDATE SKIP LOCKED`
+	err = tx.QueryRow(query, s.name, now).Scan(&due)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := Enqueue(tx, s.kind, nil, due); err != nil {
+		return err
+	}
+	// Jadwal yang terlewat (mis. saat server mati) hanya dijalankan sekali
+	query = `UPDATE job_schedule SET last_run_at = $2, next_run_at = $3, updated_at = NOW() WHERE name = $1`
+	if _, err := tx.Exec(query, s.name, due, s.cron.Next(now)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func requeueStale(db *sql.DB) error {
+	query := `UPDATE job SET status = 'queued', locked_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < $1`
+	_, err := db.Exec(query, time.Now().Add(-lease))
+	return err
+}
@@ -0,0 +1,455 @@
+// Package listquery membaca parameter daftar (limit, cursor, sort, q dan filter) dari request dan
+// menyusun SQL berparameter dengan paginasi keyset. Nama kolom dan operator hanya berasal dari Spec
+// milik handler; nilai dari pengguna selalu dikirim sebagai parameter, tidak pernah disisipkan ke SQL.
+//
+// Parameter yang dikenali:
+//
+//	limit=20                 jumlah item per halaman (maks Spec.MaxLimit)
+//	cursor=<next_cursor>     halaman berikutnya
+//	sort=name | sort=-name   urutan naik atau turun pada field yang boleh diurutkan
+//	q=teks                   pencarian ILIKE pada Spec.Search
+//	field=v | field[op]=v    filter; op: eq, ne, lt, lte, gt, gte, like, in (dipisah koma), null (true/false)
+//
+// Respons daftar memakai amplop {"status","message","data":{"items","next_cursor","total"}}.
+package listquery
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type adalah tipe nilai field, menentukan konversi nilai filter dan operator yang boleh dipakai
+type Type int
+
+const (
+	Text Type = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Field adalah satu kolom yang boleh diurutkan dan/atau difilter
+type Field struct {
+	Column string // Ekspresi SQL, mis. "a.nama"
+	Type   Type
+	Sort   bool
+	Filter bool
+}
+
+// Spec mendefinisikan daftar milik satu endpoint
+type Spec struct {
+	Fields       map[string]Field
+	ID           string   // Kolom bilangan bulat unik sebagai pemutus seri urutan dan isi kursor
+	DefaultSort  string   // Nama field, awalan "-" untuk urutan turun; kosong berarti ID turun
+	Search       []string // Kolom teks yang dicari dengan q
+	DefaultLimit int      // 0 berarti 20
+	MaxLimit     int      // 0 berarti 100
+}
+
+// Error adalah kesalahan parameter dari klien (400)
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func invalid(msg string) error { return &Error{Message: msg} }
+
+// Query adalah parameter daftar yang sudah divalidasi
+type Query struct {
+	spec     Spec
+	conds    []string // Kondisi dengan placeholder "?"
+	args     []interface{}
+	sortName string
+	sortKey  string // Ekspresi kunci urutan, tidak pernah NULL
+	sortCast string
+	desc     bool
+	limit    int
+	after    *cursor
+}
+
+type cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int64  `json:"i"`
+}
+
+var ops = map[string]string{"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="}
+
+// Parse membaca parameter daftar dari r sesuai spec. Parameter lain diabaikan agar handler dapat
+// memakai parameter sendiri (mis. farm_id). Kesalahan parameter bertipe *Error.
+func Parse(r *http.Request, spec Spec) (*Query, error) {
+	if spec.DefaultLimit == 0 {
+		spec.DefaultLimit = 20
+	}
+	if spec.MaxLimit == 0 {
+		spec.MaxLimit = 100
+	}
+	values := r.URL.Query()
+	q := &Query{spec: spec, limit: spec.DefaultLimit}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > spec.MaxLimit {
+			return nil, invalid("limit must be between 1 and " + strconv.Itoa(spec.MaxLimit))
+		}
+		q.limit = limit
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = spec.DefaultSort
+	}
+	if err := q.setSort(sortParam); err != nil {
+		return nil, err
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != q.sortName {
+			return nil, invalid("cursor is invalid for this sort")
+		}
+		q.after = &c
+	}
+
+	if v := strings.TrimSpace(values.Get("q")); v != "" && len(spec.Search) > 0 {
+		pattern := "%" + escapeLike(v) + "%"
+		parts := make([]string, len(spec.Search))
+		args := make([]interface{}, len(spec.Search))
+		for i, col := range spec.Search {
+			parts[i] = col + " ILIKE ?"
+			args[i] = pattern
+		}
+		q.Where("("+strings.Join(parts, " OR ")+")", args...)
+	}
+
+	// Urutkan kunci agar SQL yang dihasilkan stabil
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name, op := k, "eq"
+		if i := strings.Index(k, "["); i > 0 && strings.HasSuffix(k, "]") {
+			name, op = k[:i], k[i+1:len(k)-1]
+		}
+		field, ok := spec.Fields[name]
+		if !ok || !field.Filter {
+			continue
+		}
+		if err := q.addFilter(name, field, op, values.Get(k)); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) setSort(param string) error {
+	name := strings.TrimPrefix(param, "-")
+	q.desc = strings.HasPrefix(param, "-")
+	if name == "" {
+		q.sortName, q.sortKey, q.sortCast, q.desc = "-id", q.spec.ID, "bigint", true
+		return nil
+	}
+	field, ok := q.spec.Fields[name]
+	if !ok || !field.Sort {
+		allowed := []string{}
+		for n, f := range q.spec.Fields {
+			if f.Sort {
+				allowed = append(allowed, n)
+			}
+		}
+		sort.Strings(allowed)
+		return invalid("sort must be one of: " + strings.Join(allowed, ", ") + " (prefix - for descending)")
+	}
+	q.sortName = param
+	switch field.Type {
+	case Int:
+		q.sortKey, q.sortCast = "COALESCE("+field.Column+", 0)", "bigint"
+	case Float:
+		q.sortKey, q.sortCast = "COALESCE("+field.Column+", 0)", "numeric"
+	case Bool:
+		q.sortKey, q.sortCast = "COALESCE("+field.Column+", FALSE)", "boolean"
+	case Time:
+		// Epoch dapat dibandingkan tepat untuk timestamp dengan atau tanpa zona waktu
+		q.sortKey, q.sortCast = "COALESCE(EXTRACT(EPOCH FROM "+field.Column+"), 0)", "numeric"
+	default:
+		q.sortKey, q.sortCast = "COALESCE("+field.Column+", '')", "text"
+	}
+	return nil
+}
+
+func (q *Query) addFilter(name string, field Field, op, raw string) error {
+	if op == "null" {
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return invalid(name + "[null] must be true or false")
+		}
+		if isNull {
+			q.Where(field.Column + " IS NULL")
+		} else {
+			q.Where(field.Column + " IS NOT NULL")
+		}
+		return nil
+	}
+
+	if op == "in" {
+		parts := strings.Split(raw, ",")
+		if len(parts) > 100 {
+			return invalid(name + "[in] accepts at most 100 values")
+		}
+		args := make([]interface{}, len(parts))
+		for i, p := range parts {
+			v, err := convert(field.Type, strings.TrimSpace(p))
+			if err != nil {
+				return invalid(name + ": " + err.Error())
+			}
+			args[i] = v
+		}
+		q.Where(field.Column+" IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+")", args...)
+		return nil
+	}
+
+	if op == "like" {
+		if field.Type != Text {
+			return invalid(name + "[like] is only supported on text fields")
+		}
+		q.Where(field.Column+" ILIKE ?", "%"+escapeLike(raw)+"%")
+		return nil
+	}
+
+	sqlOp, ok := ops[op]
+	if !ok {
+		return invalid("unknown operator " + op + " on " + name)
+	}
+	if (field.Type == Text || field.Type == Bool) && op != "eq" && op != "ne" {
+		return invalid(name + "[" + op + "] is not supported on this field")
+	}
+	v, err := convert(field.Type, raw)
+	if err != nil {
+		return invalid(name + ": " + err.Error())
+	}
+	q.Where(field.Column+" "+sqlOp+" ?", v)
+	return nil
+}
+
+// convert mengubah nilai filter ke tipe Go yang sesuai untuk parameter SQL
+func convert(t Type, raw string) (interface{}, error) {
+	switch t {
+	case Int:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return v, nil
+	case Float:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return v, nil
+	case Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("must be true or false")
+		}
+		return v, nil
+	case Time:
+		if v, err := time.Parse(time.RFC3339, raw); err == nil {
+			return v, nil
+		}
+		v, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, errors.New("must be a date (2006-01-02) or RFC3339 time")
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Where menambahkan kondisi tetap milik handler, mis. pembatasan per peternakan. cond memakai
+// placeholder "?" yang diisi berurutan oleh args; cond harus konstanta dari kode.
+func (q *Query) Where(cond string, args ...interface{}) {
+	q.conds = append(q.conds, cond)
+	q.args = append(q.args, args...)
+}
+
+// Limit mengembalikan jumlah item per halaman
+func (q *Query) Limit() int {
+	return q.limit
+}
+
+// Bind mengganti placeholder "?" pada query dengan $n berikutnya setelah args dan menambahkan values
+func Bind(query string, args []interface{}, values ...interface{}) (string, []interface{}) {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' && n < len(values) {
+			args = append(args, values[n])
+			n++
+			b.WriteString("$" + strconv.Itoa(len(args)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), args
+}
+
+func (q *Query) where(withCursor bool) (string, []interface{}) {
+	conds := append([]string{"TRUE"}, q.conds...)
+	where, args := Bind(strings.Join(conds, " AND "), nil, q.args...)
+	if withCursor && q.after != nil {
+		cmp := ">"
+		if q.desc {
+			cmp = "<"
+		}
+		var cond string
+		cond, args = Bind("("+q.sortKey+" "+cmp+" ?::"+q.sortCast+" OR ("+q.sortKey+" = ?::"+q.sortCast+" AND "+q.spec.ID+" "+cmp+" ?))",
+			args, q.after.Key, q.after.Key, q.after.ID)
+		where += " AND " + cond
+	}
+	return where, args
+}
+
+// build menyusun query halaman dan query total. Query halaman mengembalikan columns diikuti
+// kunci urutan (teks) dan ID.
+func (q *Query) build(columns, from string) (list string, listArgs []interface{}, count string, countArgs []interface{}) {
+	dir := "ASC"
+	if q.desc {
+		dir = "DESC"
+	}
+	where, listArgs := q.where(true)
+	listArgs = append(listArgs, q.limit+1)
+	list = "SELECT " + columns + ", (" + q.sortKey + ")::text, " + q.spec.ID +
+		" FROM " + from +
+		" WHERE " + where +
+		" ORDER BY " + q.sortKey + " " + dir + ", " + q.spec.ID + " " + dir +
+		" LIMIT $" + strconv.Itoa(len(listArgs))
+
+	where, countArgs = q.where(false)
+	count = "SELECT COUNT(*) FROM " + from + " WHERE " + where
+	return list, listArgs, count, countArgs
+}
+
+// EncodeCursor menyusun kursor opak dari nama urutan, kunci urutan dan ID baris terakhir
+func EncodeCursor(sortName, key string, id int64) string {
+	b, _ := json.Marshal(cursor{Sort: sortName, Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor membaca kursor dari EncodeCursor
+func DecodeCursor(s string) (sortName, key string, id int64, err error) {
+	c, err := decodeCursor(s)
+	return c.Sort, c.Key, c.ID, err
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// Page adalah isi data pada respons daftar
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int64   `json:"total"`
+}
+
+// Queryer dipenuhi oleh *sql.DB dan *sql.Tx
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ScanFunc membaca satu baris. key harus diteruskan ke rows.Scan setelah kolom milik handler:
+//
+//	rows.Scan(append([]interface{}{&a.ID, &a.Nama}, key...)...)
+type ScanFunc[T any] func(rows *sql.Rows, key ...interface{}) (T, error)
+
+// Run menjalankan query halaman dan total. columns dan from adalah SQL dari kode handler;
+// from boleh berisi JOIN.
+func Run[T any](db Queryer, q *Query, columns, from string, scan ScanFunc[T]) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+	list, listArgs, count, countArgs := q.build(columns, from)
+
+	rows, err := db.Query(list, listArgs...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var lastKey string
+	var lastID int64
+	for rows.Next() {
+		// setSort sudah membungkus kunci dengan COALESCE; NullString menjaga agar kunci yang
+		// tetap NULL tidak menggagalkan seluruh halaman dan diperlakukan seperti ''
+		var key sql.NullString
+		var id int64
+		item, err := scan(rows, &key, &id)
+		if err != nil {
+			return page, err
+		}
+		if len(page.Items) == q.limit {
+			// Baris tambahan hanya menandakan masih ada halaman berikutnya
+			next := EncodeCursor(q.sortName, lastKey, lastID)
+			page.NextCursor = &next
+			break
+		}
+		page.Items = append(page.Items, item)
+		lastKey, lastID = key.String, id
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	rows.Close()
+
+	err = db.QueryRow(count, countArgs...).Scan(&page.Total)
+	return page, err
+}
+
+// Write menulis respons sukses dengan amplop daftar
+func Write(w http.ResponseWriter, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"data":    data,
+	})
+}
+
+// WriteError menulis 400 untuk *Error dari Parse dan 500 untuk kesalahan lain
+func WriteError(w http.ResponseWriter, err error) {
+	status, body := http.StatusInternalServerError, map[string]string{
+		"error":   "Database error",
+		"message": "Failed to fetch data.",
+	}
+	var qe *Error
+	if errors.As(err, &qe) {
+		status, body = http.StatusBadRequest, map[string]string{
+			"error":   "Invalid query",
+			"message": qe.Message,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
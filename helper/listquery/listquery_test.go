@@ -0,0 +1,238 @@
+package listquery
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var akunSpec = Spec{
+	Fields: map[string]Field{
+		"nama":       {Column: "a.nama", Type: Text, Sort: true, Filter: true},
+		"id_role":    {Column: "a.id_role", Type: Int, Filter: true},
+		"created_at": {Column: "a.created_at", Type: Time, Sort: true, Filter: true},
+	},
+	ID:          "a.id_user",
+	DefaultSort: "nama",
+	Search:      []string{"a.nama", "a.email"},
+}
+
+func parse(t *testing.T, rawQuery string) *Query {
+	t.Helper()
+	q, err := Parse(httptest.NewRequest(http.MethodGet, "/all/akun?"+rawQuery, nil), akunSpec)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rawQuery, err)
+	}
+	return q
+}
+
+func TestBuildDefault(t *testing.T) {
+	q := parse(t, "")
+	list, args, count, countArgs := q.build("a.id_user, a.nama", "akun a")
+	want := "SELECT a.id_user, a.nama, (COALESCE(a.nama, ''))::text, a.id_user FROM akun a WHERE TRUE" +
+		" ORDER BY COALESCE(a.nama, '') ASC, a.id_user ASC LIMIT $1"
+	if list != want {
+		t.Errorf("list =\n%s\nwant\n%s", list, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{21}) {
+		t.Errorf("args = %v", args)
+	}
+	if count != "SELECT COUNT(*) FROM akun a WHERE TRUE" || len(countArgs) != 0 {
+		t.Errorf("count = %q %v", count, countArgs)
+	}
+}
+
+func TestBuildFiltersSearchAndCursor(t *testing.T) {
+	cur := EncodeCursor("-created_at", "1700000000.5", 42)
+	q := parse(t, "q=bud_i&id_role[in]=1,2&nama[like]=a&sort=-created_at&limit=5&cursor="+cur+"&farm_id=9")
+	q.Where("a.deleted_at IS NULL")
+	list, args, count, countArgs := q.build("a.id_user", "akun a")
+
+	for _, part := range []string{
+		"(a.nama ILIKE $1 OR a.email ILIKE $2)",
+		"a.id_role IN ($3, $4)",
+		"a.nama ILIKE $5",
+		"a.deleted_at IS NULL",
+		"(COALESCE(EXTRACT(EPOCH FROM a.created_at), 0) < $6::numeric OR (COALESCE(EXTRACT(EPOCH FROM a.created_at), 0) = $7::numeric AND a.id_user < $8))",
+		"ORDER BY COALESCE(EXTRACT(EPOCH FROM a.created_at), 0) DESC, a.id_user DESC LIMIT $9",
+	} {
+		if !strings.Contains(list, part) {
+			t.Errorf("list missing %q:\n%s", part, list)
+		}
+	}
+	want := []interface{}{`%bud\_i%`, `%bud\_i%`, int64(1), int64(2), "%a%", "1700000000.5", "1700000000.5", int64(42), 6}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v\nwant %#v", args, want)
+	}
+	if strings.Contains(count, "EPOCH") || len(countArgs) != 5 {
+		t.Errorf("count must not include the cursor: %q %v", count, countArgs)
+	}
+	if strings.Contains(list, "farm_id") {
+		t.Error("unknown parameters must be ignored")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{
+		"limit=0",
+		"limit=101",
+		"sort=password",
+		"id_role=abc",
+		"id_role[like]=1",
+		"nama[gt]=a",
+		"nama[regex]=a",
+		"created_at[gte]=yesterday",
+		"cursor=not-a-cursor",
+		"cursor=" + EncodeCursor("nama", "a", 1) + "&sort=-created_at",
+	} {
+		_, err := Parse(httptest.NewRequest(http.MethodGet, "/x?"+raw, nil), akunSpec)
+		var qe *Error
+		if !errors.As(err, &qe) {
+			t.Errorf("Parse(%q) error = %v, want *Error", raw, err)
+		}
+	}
+}
+
+func TestDefaultSortByID(t *testing.T) {
+	q, err := Parse(httptest.NewRequest(http.MethodGet, "/x", nil), Spec{ID: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _, _, _ := q.build("id", "role")
+	if !strings.HasSuffix(list, "ORDER BY id DESC, id DESC LIMIT $1") {
+		t.Errorf("list = %s", list)
+	}
+}
+
+func TestBind(t *testing.T) {
+	sql, args := Bind("a = ? AND b = ?", []interface{}{"x"}, 1, 2)
+	if sql != "a = $2 AND b = $3" || len(args) != 3 {
+		t.Errorf("Bind = %q %v", sql, args)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sortName, key, id, err := DecodeCursor(EncodeCursor("-nama", "a|b", 7))
+	if err != nil || sortName != "-nama" || key != "a|b" || id != 7 {
+		t.Errorf("DecodeCursor = %q %q %d %v", sortName, key, id, err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(rec, invalid("bad"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	WriteError(rec, errors.New("connection reset"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+// stubDriver mengembalikan baris tetap untuk query halaman dan total tanpa database
+type stubDriver struct {
+	rows  [][]driver.Value
+	total int64
+}
+
+func (d *stubDriver) Open(string) (driver.Conn, error) { return stubConn{d}, nil }
+
+type stubConn struct{ d *stubDriver }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.d, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type stubStmt struct {
+	d     *stubDriver
+	query string
+}
+
+func (s stubStmt) Close() error                               { return nil }
+func (s stubStmt) NumInput() int                              { return -1 }
+func (s stubStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "SELECT COUNT(*)") {
+		return &stubRows{cols: []string{"count"}, rows: [][]driver.Value{{s.d.total}}}, nil
+	}
+	return &stubRows{cols: []string{"id_user", "city", "key", "id"}, rows: s.d.rows}, nil
+}
+
+type stubRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.cols }
+func (r *stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestRunNullSortKey(t *testing.T) {
+	// Profil tanpa alamat: kota dari LEFT JOIN bernilai NULL
+	sql.Register("listquery-null-key", &stubDriver{
+		rows: [][]driver.Value{
+			{int64(7), nil, nil, int64(7)},
+			{int64(3), "Bandung", "Bandung", int64(3)},
+		},
+		total: 2,
+	})
+	db, err := sql.Open("listquery-null-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	spec := Spec{
+		Fields: map[string]Field{"city": {Column: "ad.city", Type: Text, Sort: true}},
+		ID:     "a.id_user",
+	}
+	q, err := Parse(httptest.NewRequest(http.MethodGet, "/profile/all?sort=city&limit=1", nil), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := Run(db, q, "a.id_user, ad.city", "akun a LEFT JOIN alamat ad ON ad.id_user = a.id_user",
+		func(rows *sql.Rows, key ...interface{}) (int64, error) {
+			var id int64
+			var city sql.NullString
+			err := rows.Scan(append([]interface{}{&id, &city}, key...)...)
+			return id, err
+		})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0] != 7 || page.Total != 2 || page.NextCursor == nil {
+		t.Fatalf("page = %+v", page)
+	}
+
+	// Kursor dari baris NULL dilanjutkan dengan kunci COALESCE yang sama ('')
+	sortName, key, id, err := DecodeCursor(*page.NextCursor)
+	if err != nil || sortName != "city" || key != "" || id != 7 {
+		t.Errorf("cursor = %q %q %d %v", sortName, key, id, err)
+	}
+	q, err = Parse(httptest.NewRequest(http.MethodGet, "/profile/all?sort=city&cursor="+*page.NextCursor, nil), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, args, _, _ := q.build("a.id_user", "akun a")
+	if !strings.Contains(list, "(COALESCE(ad.city, '') > $1::text OR (COALESCE(ad.city, '') = $2::text AND a.id_user > $3))") {
+		t.Errorf("list = %s", list)
+	}
+	if args[0] != "" || args[2] != int64(7) {
+		t.Errorf("args = %#v", args)
+	}
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WhatsApp mengirim pesan teks melalui gateway HTTP WhatsApp (format WhatsAuth)
+type WhatsApp struct {
+	URL    string // Endpoint gateway, mis. https://api.wa.my.id/api/v2/send/message/text
+	Token  string // Dikirim pada header "Token"
+	Client *http.Client
+}
+
+func (c *WhatsApp) Name() string { return ChannelWhatsApp }
+
+func (c *WhatsApp) Send(ctx context.Context, to Recipient, msg Message) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"to":       to.Phone,
+		"isgroup":  false,
+		"messages": "*" + msg.Subject + "*\n" + msg.Body,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Token", c.Token)
+
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("whatsapp gateway: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// SMTP mengirim email teks biasa
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (c *SMTP) Name() string { return ChannelEmail }
+
+func (c *SMTP) Send(ctx context.Context, to Recipient, msg Message) error {
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	if msg.Link != "" {
+		b.WriteString("\r\n\r\n" + msg.Link)
+	}
+	return smtp.SendMail(c.Host+":"+c.Port, auth, c.From, []string{to.Email}, []byte(b.String()))
+}
+
+// InApp menyimpan pesan ke tabel notifications sebagai inbox di aplikasi
+type InApp struct {
+	DB *sql.DB
+}
+
+func (c *InApp) Name() string { return ChannelInApp }
+
+func (c *InApp) Send(ctx context.Context, to Recipient, msg Message) error {
+	data, _ := json.Marshal(msg.Data)
+	query := `INSERT INTO notifications (recipient_type, recipient_id, event, title, body, link, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NOW())`
+	_, err := c.DB.ExecContext(ctx, query, to.Type, to.ID, msg.Event, msg.Subject, msg.Body, msg.Link, string(data))
+	return err
+}
+
+// Fake mencatat pesan di memori; dipakai untuk pengujian dan pengembangan lokal
+// sebagai pengganti channel yang belum dikonfigurasi. Err membuat Send gagal.
+type Fake struct {
+	Channel string
+	Err     error
+	Log     bool // Tulis pesan ke log server
+
+	mu   sync.Mutex
+	Sent []FakeMessage
+}
+
+// FakeMessage adalah pesan yang diterima Fake
+type FakeMessage struct {
+	To      Recipient
+	Message Message
+}
+
+func (c *Fake) Name() string { return c.Channel }
+
+func (c *Fake) Send(ctx context.Context, to Recipient, msg Message) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	c.mu.Lock()
+	c.Sent = append(c.Sent, FakeMessage{to, msg})
+	c.mu.Unlock()
+	if c.Log {
+		log.Printf("[INFO] notify(%s) to %s %d: %s - %s", c.Channel, to.Type, to.ID, msg.Subject, msg.Body)
+	}
+	return nil
+}
+
+// Messages mengembalikan salinan pesan yang sudah diterima
+func (c *Fake) Messages() []FakeMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]FakeMessage(nil), c.Sent...)
+}
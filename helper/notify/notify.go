@@ -0,0 +1,156 @@
+// Package notify mengirim notifikasi ke akun pembeli/peternak dan pengirim melalui beberapa
+// channel (WhatsApp, email, inbox di aplikasi). Pesan disusun dari template per event dan
+// bahasa, channel dipilih sesuai preferensi penerima, dan setiap pengiriman dicatat.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Jenis penerima
+const (
+	RecipientAkun     = "akun"
+	RecipientPengirim = "pengirim"
+	// RecipientContact adalah email atau nomor telepon tanpa akun (mis. undangan peternakan);
+	// ID diisi id sumbernya, mis. id undangan. Kontak tidak memiliki inbox di aplikasi.
+	RecipientContact = "contact"
+)
+
+// Nama channel
+const (
+	ChannelInApp    = "in_app"
+	ChannelWhatsApp = "whatsapp"
+	ChannelEmail    = "email"
+)
+
+// Recipient adalah penerima notifikasi
+type Recipient struct {
+	Type  string // RecipientAkun atau RecipientPengirim
+	ID    int64
+	Name  string
+	Phone string
+	Email string
+	Lang  string
+}
+
+// Message adalah notifikasi yang sudah dirender
+type Message struct {
+	Event   string
+	Subject string
+	Body    string
+	Link    string            // Tautan opsional ke halaman terkait
+	Data    map[string]string // Data tambahan untuk inbox, mis. invoice_id
+}
+
+// Channel mengirim pesan ke penerima melalui satu media
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
+
+// Store menyediakan data penerima, preferensi dan log pengiriman
+type Store interface {
+	Recipient(ctx context.Context, recipientType string, id int64) (Recipient, error)
+	// Enabled mengembalikan preferensi channel penerima; channel yang tidak disebut dianggap aktif
+	Enabled(ctx context.Context, to Recipient) (map[string]bool, error)
+	// Delivered memeriksa apakah pesan dengan key sudah berhasil dikirim lewat channel
+	Delivered(ctx context.Context, key string, to Recipient, channel string) (bool, error)
+	LogDelivery(ctx context.Context, key string, to Recipient, channel string, msg Message, sendErr error) error
+}
+
+// ErrNoRecipient dikembalikan jika penerima tidak ditemukan
+var ErrNoRecipient = errors.New("notify: recipient not found")
+
+// Notifier merender template dan mengirim pesan ke semua channel yang aktif
+type Notifier struct {
+	Store    Store
+	Channels []Channel
+}
+
+// Notify mengirim notifikasi event ke penerima. key dipakai untuk mencegah pesan ganda saat
+// pengiriman diulang (mis. ID event outbox); channel yang sudah berhasil untuk key tersebut dilewati.
+// Error dikembalikan jika ada channel yang gagal agar pemanggil dapat mencoba lagi.
+func (n *Notifier) Notify(ctx context.Context, key, recipientType string, recipientID int64, event string, data map[string]string) error {
+	to, err := n.Store.Recipient(ctx, recipientType, recipientID)
+	if err != nil {
+		if errors.Is(err, ErrNoRecipient) {
+			log.Printf("[INFO] notify: %s %d not found, %s skipped", recipientType, recipientID, event)
+			return nil
+		}
+		return err
+	}
+	return n.send(ctx, key, to, event, data)
+}
+
+// NotifyContact mengirim notifikasi event ke kontak tanpa akun (RecipientContact) lewat
+// channel yang memiliki alamatnya, yaitu WhatsApp untuk Phone dan email untuk Email
+func (n *Notifier) NotifyContact(ctx context.Context, key string, to Recipient, event string, data map[string]string) error {
+	to.Type = RecipientContact
+	if to.Lang == "" {
+		to.Lang = DefaultLang
+	}
+	return n.send(ctx, key, to, event, data)
+}
+
+func (n *Notifier) send(ctx context.Context, key string, to Recipient, event string, data map[string]string) error {
+	vars := map[string]string{"name": to.Name}
+	for k, v := range data {
+		vars[k] = v
+	}
+	msg, err := Render(event, to.Lang, vars)
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+
+	prefs, err := n.Store.Enabled(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, ch := range n.Channels {
+		name := ch.Name()
+		if enabled, ok := prefs[name]; ok && !enabled {
+			continue
+		}
+		if !reachable(to, name) {
+			continue
+		}
+		done, err := n.Store.Delivered(ctx, key, to, name)
+		if err != nil {
+			return err
+		}
+		if done {
+			continue
+		}
+		sendErr := ch.Send(ctx, to, msg)
+		if err := n.Store.LogDelivery(ctx, key, to, name, msg, sendErr); err != nil {
+			return err
+		}
+		if sendErr != nil {
+			failures = append(failures, name+": "+sendErr.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("notify %s: %s", event, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// reachable memeriksa apakah penerima memiliki alamat untuk channel tersebut
+func reachable(to Recipient, channel string) bool {
+	switch channel {
+	case ChannelWhatsApp:
+		return to.Phone != ""
+	case ChannelEmail:
+		return to.Email != ""
+	case ChannelInApp:
+		return to.Type != RecipientContact
+	}
+	return true
+}
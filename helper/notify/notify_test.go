@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type memStore struct {
+	recipients map[int64]Recipient
+	prefs      map[string]bool
+	sent       map[string]bool
+	logs       []string
+}
+
+func (s *memStore) Recipient(_ context.Context, _ string, id int64) (Recipient, error) {
+	r, ok := s.recipients[id]
+	if !ok {
+		return r, ErrNoRecipient
+	}
+	return r, nil
+}
+
+func (s *memStore) Enabled(context.Context, Recipient) (map[string]bool, error) {
+	return s.prefs, nil
+}
+
+func (s *memStore) Delivered(_ context.Context, key string, _ Recipient, channel string) (bool, error) {
+	return s.sent[key+"/"+channel], nil
+}
+
+func (s *memStore) LogDelivery(_ context.Context, key string, _ Recipient, channel string, _ Message, err error) error {
+	status := "sent"
+	if err != nil {
+		status = "failed"
+	} else {
+		s.sent[key+"/"+channel] = true
+	}
+	s.logs = append(s.logs, channel+":"+status)
+	return nil
+}
+
+func TestRender(t *testing.T) {
+	msg, err := Render("PaymentApproved", "en", map[string]string{"name": "Budi", "invoice_number": "INV/1", "total": "Rp.10,000.00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Payment for INV/1 approved" || !strings.Contains(msg.Body, "Hi Budi") {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	// Bahasa yang tidak tersedia memakai bahasa default
+	msg, _ = Render("PaymentApproved", "fr", map[string]string{"invoice_number": "INV/1"})
+	if !strings.HasPrefix(msg.Subject, "Pembayaran INV/1") {
+		t.Errorf("fallback subject = %q", msg.Subject)
+	}
+
+	if _, err := Render("Unknown", "id", nil); err == nil {
+		t.Error("unknown event should fail")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	store := &memStore{
+		recipients: map[int64]Recipient{
+			1: {Type: RecipientAkun, ID: 1, Name: "Budi", Phone: "0812", Lang: "id"},
+		},
+		prefs: map[string]bool{ChannelWhatsApp: true},
+		sent:  map[string]bool{},
+	}
+	inApp := &Fake{Channel: ChannelInApp}
+	wa := &Fake{Channel: ChannelWhatsApp, Err: errors.New("gateway down")}
+	email := &Fake{Channel: ChannelEmail}
+	n := &Notifier{Store: store, Channels: []Channel{inApp, wa, email}}
+
+	data := map[string]string{"invoice_number": "INV/1"}
+	if err := n.Notify(context.Background(), "evt-1", RecipientAkun, 1, "ShipmentDispatched", data); err == nil {
+		t.Fatal("failed channel should return an error")
+	}
+	if len(inApp.Messages()) != 1 {
+		t.Fatalf("in-app messages = %d, want 1", len(inApp.Messages()))
+	}
+	if got := inApp.Messages()[0].Message.Body; !strings.Contains(got, "Halo Budi") {
+		t.Errorf("body = %q", got)
+	}
+	if len(email.Messages()) != 0 {
+		t.Error("email sent to a recipient without an address")
+	}
+
+	// Percobaan ulang hanya mengirim ke channel yang sebelumnya gagal
+	wa.Err = nil
+	if err := n.Notify(context.Background(), "evt-1", RecipientAkun, 1, "ShipmentDispatched", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(inApp.Messages()) != 1 || len(wa.Messages()) != 1 {
+		t.Errorf("retry resent messages: in_app=%d whatsapp=%d", len(inApp.Messages()), len(wa.Messages()))
+	}
+
+	// Channel yang dimatikan penerima dilewati
+	store.prefs = map[string]bool{ChannelInApp: false}
+	if err := n.Notify(context.Background(), "evt-2", RecipientAkun, 1, "ShipmentDispatched", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(inApp.Messages()) != 1 {
+		t.Error("disabled channel received a message")
+	}
+
+	// Penerima yang tidak ada tidak dianggap error
+	if err := n.Notify(context.Background(), "evt-3", RecipientAkun, 99, "ShipmentDispatched", data); err != nil {
+		t.Errorf("missing recipient: %v", err)
+	}
+}
+
+func TestNotifyContact(t *testing.T) {
+	store := &memStore{prefs: map[string]bool{}, sent: map[string]bool{}}
+	inApp := &Fake{Channel: ChannelInApp}
+	wa := &Fake{Channel: ChannelWhatsApp}
+	email := &Fake{Channel: ChannelEmail}
+	n := &Notifier{Store: store, Channels: []Channel{inApp, wa, email}}
+
+	to := Recipient{ID: 7, Email: "calon@example.com"}
+	data := map[string]string{"farm_name": "Sumber Rejeki", "role": "packer"}
+	if err := n.NotifyContact(context.Background(), "evt-1", to, "FarmInvitedContact", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(inApp.Messages()) != 0 || len(wa.Messages()) != 0 {
+		t.Errorf("contact without account reached in_app=%d whatsapp=%d", len(inApp.Messages()), len(wa.Messages()))
+	}
+	if len(email.Messages()) != 1 {
+		t.Fatalf("email messages = %d, want 1", len(email.Messages()))
+	}
+	if got := email.Messages()[0]; got.To.Type != RecipientContact || !strings.Contains(got.Message.Body, "Sumber Rejeki") {
+		t.Errorf("sent %+v", got)
+	}
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PGStore adalah Store di atas Postgres
+type PGStore struct {
+	DB *sql.DB
+}
+
+func (s *PGStore) Recipient(ctx context.Context, recipientType string, id int64) (Recipient, error) {
+	to := Recipient{Type: recipientType, ID: id}
+	var query string
+	switch recipientType {
+	case RecipientAkun:
+		query = `SELECT COALESCE(nama, ''), COALESCE(no_telp, ''), COALESCE(email, ''), COALESCE(language, '') FROM akun WHERE id_user = $1`
+	case RecipientPengirim:
+		query = `SELECT COALESCE(name, ''), COALESCE(phone, ''), COALESCE(email, ''), '' FROM pengirim WHERE id = $1`
+	default:
+		return to, ErrNoRecipient
+	}
+	err := s.DB.QueryRowContext(ctx, query, id).Scan(&to.Name, &to.Phone, &to.Email, &to.Lang)
+	if err == sql.ErrNoRows {
+		return to, ErrNoRecipient
+	}
+	if to.Lang == "" {
+		to.Lang = DefaultLang
+	}
+	return to, err
+}
+
+func (s *PGStore) Enabled(ctx context.Context, to Recipient) (map[string]bool, error) {
+	query := `SELECT channel, enabled FROM notification_preference WHERE recipient_type = $1 AND recipient_id = $2`
+	rows, err := s.DB.QueryContext(ctx, query, to.Type, to.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := map[string]bool{}
+	for rows.Next() {
+		var channel string
+		var enabled bool
+		if err := rows.Scan(&channel, &enabled); err != nil {
+			return nil, err
+		}
+		prefs[channel] = enabled
+	}
+	return prefs, rows.Err()
+}
+
+func (s *PGStore) Delivered(ctx context.Context, key string, to Recipient, channel string) (bool, error) {
+	var done bool
+	query := `SELECT EXISTS (
+		SELECT 1 FROM notification_delivery
+		WHERE dedupe_key = $1 AND recipient_type = $2 AND recipient_id = $3 AND channel = $4 AND status = 'sent'
+	)`
+	err := s.DB.QueryRowContext(ctx, query, key, to.Type, to.ID, channel).Scan(&done)
+	return done, err
+}
+
+func (s *PGStore) LogDelivery(ctx context.Context, key string, to Recipient, channel string, msg Message, sendErr error) error {
+	status, errText := "sent", ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	query := `INSERT INTO notification_delivery (dedupe_key, recipient_type, recipient_id, event, channel, status, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NOW())`
+	_, err := s.DB.ExecContext(ctx, query, key, to.Type, to.ID, msg.Event, channel, status, errText)
+	return err
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// DefaultLang dipakai jika bahasa penerima tidak memiliki template
+const DefaultLang = "id"
+
+type messageTemplate struct {
+	Subject string
+	Body    string
+}
+
+// templates berisi teks per event dan bahasa; field {{.nama}} diisi dari data event
+var templates = map[string]map[string]messageTemplate{
+	"OrderPlaced": {
+		"id": {"Pesanan {{.invoice_number}} dibuat", "Halo {{.name}}, pesanan {{.invoice_number}} sebesar {{.total}} telah dibuat. Silakan selesaikan pembayaran sebelum {{.due_date}}."},
+		"en": {"Order {{.invoice_number}} placed", "Hi {{.name}}, order {{.invoice_number}} for {{.total}} has been placed. Please complete payment before {{.due_date}}."},
+	},
+	"OrderReceived": {
+		"id": {"Pesanan baru {{.invoice_number}}", "Halo {{.name}}, ada pesanan baru {{.invoice_number}} sebesar {{.total}}."},
+		"en": {"New order {{.invoice_number}}", "Hi {{.name}}, you have a new order {{.invoice_number}} for {{.total}}."},
+	},
+	"PaymentSubmitted": {
+		"id": {"Bukti transfer {{.invoice_number}}", "Halo {{.name}}, pembeli telah mengirim bukti transfer untuk {{.invoice_number}}. Mohon segera diverifikasi."},
+		"en": {"Proof of transfer for {{.invoice_number}}", "Hi {{.name}}, the buyer has uploaded a proof of transfer for {{.invoice_number}}. Please verify it."},
+	},
+	"PaymentApproved": {
+		"id": {"Pembayaran {{.invoice_number}} diterima", "Halo {{.name}}, pembayaran untuk {{.invoice_number}} sebesar {{.total}} telah diverifikasi. Terima kasih!"},
+		"en": {"Payment for {{.invoice_number}} approved", "Hi {{.name}}, your payment of {{.total}} for {{.invoice_number}} has been verified. Thank you!"},
+	},
+	"PaymentRejected": {
+		"id": {"Pembayaran {{.invoice_number}} ditolak", "Halo {{.name}}, bukti transfer untuk {{.invoice_number}} ditolak: {{.reason}}. Silakan kirim ulang."},
+		"en": {"Payment for {{.invoice_number}} rejected", "Hi {{.name}}, the proof of transfer for {{.invoice_number}} was rejected: {{.reason}}. Please upload it again."},
+	},
+	"InvoiceCancelled": {
+		"id": {"Pesanan {{.invoice_number}} dibatalkan", "Halo {{.name}}, pesanan {{.invoice_number}} telah dibatalkan. Alasan: {{.reason}}."},
+		"en": {"Order {{.invoice_number}} cancelled", "Hi {{.name}}, order {{.invoice_number}} has been cancelled. Reason: {{.reason}}."},
+	},
+	"InvoiceExpired": {
+		"id": {"Pesanan {{.invoice_number}} kedaluwarsa", "Halo {{.name}}, pesanan {{.invoice_number}} dibatalkan karena pembayaran tidak diterima sampai batas waktu."},
+		"en": {"Order {{.invoice_number}} expired", "Hi {{.name}}, order {{.invoice_number}} was cancelled because payment was not received in time."},
+	},
+	"OrderWeighed": {
+		"id": {"Penyesuaian tagihan {{.invoice_number}}", "Halo {{.name}}, produk pada pesanan {{.invoice_number}} telah ditimbang. Selisih tagihan: {{.adjustment}}. Total sekarang {{.total}}."},
+		"en": {"Invoice {{.invoice_number}} adjusted", "Hi {{.name}}, items in order {{.invoice_number}} have been weighed. Adjustment: {{.adjustment}}. New total {{.total}}."},
+	},
+	"ShipmentDispatched": {
+		"id": {"Pesanan {{.invoice_number}} dikirim", "Halo {{.name}}, pesanan {{.invoice_number}} sedang dalam pengiriman."},
+		"en": {"Order {{.invoice_number}} shipped", "Hi {{.name}}, order {{.invoice_number}} is on its way."},
+	},
+	"ShipmentAssigned": {
+		"id": {"Tugas pengiriman {{.invoice_number}}", "Halo {{.name}}, Anda ditugaskan mengantar pesanan {{.invoice_number}}."},
+		"en": {"Delivery job {{.invoice_number}}", "Hi {{.name}}, you have been assigned to deliver order {{.invoice_number}}."},
+	},
+	"ShipmentDelivered": {
+		"id": {"Pesanan {{.invoice_number}} diterima", "Halo {{.name}}, pesanan {{.invoice_number}} telah sampai. Terima kasih telah berbelanja!"},
+		"en": {"Order {{.invoice_number}} delivered", "Hi {{.name}}, order {{.invoice_number}} has been delivered. Thank you for shopping!"},
+	},
+	"FarmApproved": {
+		"id": {"Pengajuan peternak disetujui", "Halo {{.name}}, pengajuan Anda sebagai peternak telah disetujui. Anda sekarang dapat mengelola peternakan dan produk."},
+		"en": {"Farm application approved", "Hi {{.name}}, your farm application has been approved. You can now manage your farm and products."},
+	},
+	"FarmNeedsInfo": {
+		"id": {"Pengajuan peternak perlu dilengkapi", "Halo {{.name}}, pengajuan peternak Anda memerlukan informasi tambahan: {{.reason}}. Silakan perbarui pengajuan Anda."},
+		"en": {"Farm application needs more information", "Hi {{.name}}, your farm application needs more information: {{.reason}}. Please update your application."},
+	},
+	"FarmRejected": {
+		"id": {"Pengajuan peternak ditolak", "Halo {{.name}}, pengajuan peternak Anda ditolak. Alasan: {{.reason}}."},
+		"en": {"Farm application rejected", "Hi {{.name}}, your farm application was rejected. Reason: {{.reason}}."},
+	},
+	"FarmInvited": {
+		"id": {"Undangan dari {{.farm_name}}", "Halo {{.name}}, Anda diundang bergabung dengan peternakan {{.farm_name}} sebagai {{.role}}. Buka aplikasi untuk menerima atau menolak undangan."},
+		"en": {"Invitation from {{.farm_name}}", "Hi {{.name}}, you have been invited to join {{.farm_name}} as {{.role}}. Open the app to accept or decline the invitation."},
+	},
+	// Undangan untuk email atau nomor telepon yang belum memiliki akun
+	"FarmInvitedContact": {
+		"id": {"Undangan dari {{.farm_name}}", "Halo, Anda diundang bergabung dengan peternakan {{.farm_name}} sebagai {{.role}}. Daftar di aplikasi dengan email atau nomor telepon ini untuk menerima undangan."},
+		"en": {"Invitation from {{.farm_name}}", "Hi, you have been invited to join {{.farm_name}} as {{.role}}. Sign up in the app with this email or phone number to accept the invitation."},
+	},
+}
+
+// Render menyusun pesan event dalam bahasa lang, dengan DefaultLang sebagai cadangan.
+// Data "name" diisi otomatis dengan nama penerima oleh Notifier jika belum ada.
+func Render(event, lang string, data map[string]string) (Message, error) {
+	byLang, ok := templates[event]
+	if !ok {
+		return Message{}, fmt.Errorf("notify: no template for %q", event)
+	}
+	tpl, ok := byLang[lang]
+	if !ok {
+		tpl = byLang[DefaultLang]
+	}
+
+	subject, err := execute(event+".subject", tpl.Subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(event+".body", tpl.Body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Event: event, Subject: subject, Body: body, Link: data["link"]}, nil
+}
+
+func execute(name, text string, data map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,73 @@
+package numbering
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vars adalah nilai yang dapat dipakai di dalam format nomor dokumen
+type Vars struct {
+	Scope string // Kode lingkup yang ditampilkan, misalnya kode peternakan
+	Time  time.Time
+	Seq   int64
+}
+
+var tokenPattern = regexp.MustCompile(`\{([A-Z]+)(?::(\d+))?\}`)
+
+// Render mengisi format seperti "INV/{SCOPE}/{YYYY}/{MM}/{SEQ:6}".
+// Token yang didukung: {SCOPE}, {YYYY}, {YY}, {MM}, {DD} dan {SEQ} dengan lebar opsional {SEQ:n}.
+// Token yang tidak dikenal dibiarkan apa adanya.
+func Render(format string, v Vars) string {
+	return tokenPattern.ReplaceAllStringFunc(format, func(token string) string {
+		m := tokenPattern.FindStringSubmatch(token)
+		name, width := m[1], m[2]
+		switch name {
+		case "SCOPE":
+			return v.Scope
+		case "YYYY":
+			return fmt.Sprintf("%04d", v.Time.Year())
+		case "YY":
+			return fmt.Sprintf("%02d", v.Time.Year()%100)
+		case "MM":
+			return fmt.Sprintf("%02d", int(v.Time.Month()))
+		case "DD":
+			return fmt.Sprintf("%02d", v.Time.Day())
+		case "SEQ":
+			seq := strconv.FormatInt(v.Seq, 10)
+			if n, _ := strconv.Atoi(width); len(seq) < n {
+				seq = strings.Repeat("0", n-len(seq)) + seq
+			}
+			return seq
+		}
+		return token
+	})
+}
+
+// Next mengambil nomor urut berikutnya untuk kind/scope/year di dalam transaksi.
+// Baris counter terkunci sampai transaksi selesai dan kenaikan ikut dibatalkan saat rollback,
+// sehingga nomor yang terbit selalu berurutan tanpa celah.
+func Next(tx *sql.Tx, kind, scope string, year int) (int64, error) {
+	var seq int64
+	query := `INSERT INTO number_sequence (kind, scope, year, last_value, updated_at)
+		VALUES ($1, $2, $3, 1, NOW())
+		ON CONFLICT (kind, scope, year)
+		DO UPDATE SET last_value = number_sequence.last_value + 1, updated_at = NOW()
+		RETURNING last_value`
+	err := tx.QueryRow(query, kind, scope, year).Scan(&seq)
+	return seq, err
+}
+
+// Issue mengambil nomor urut berikutnya dari counter dan langsung merendernya dengan format.
+// counter adalah kunci lingkup yang tidak berubah (mis. id peternakan), sedangkan scope hanya
+// teks {SCOPE}, sehingga mengganti kode peternakan tidak mengulang nomor dari 1.
+func Issue(tx *sql.Tx, kind, format, counter, scope string, now time.Time) (string, error) {
+	seq, err := Next(tx, kind, counter, now.Year())
+	if err != nil {
+		return "", err
+	}
+	return Render(format, Vars{Scope: scope, Time: now, Seq: seq}), nil
+}
@@ -0,0 +1,25 @@
+package numbering
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	at := time.Date(2026, time.October, 5, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{"INV/{SCOPE}/{YYYY}/{MM}/{SEQ:6}", "INV/SBR01/2026/10/000123"},
+		{"{YY}{MM}{DD}-{SEQ}", "261005-123"},
+		{"CO-{SEQ:2}", "CO-123"},
+		{"X/{UNKNOWN}/{SEQ:4}", "X/{UNKNOWN}/0123"},
+	}
+	for _, c := range cases {
+		got := Render(c.format, Vars{Scope: "SBR01", Time: at, Seq: 123})
+		if got != c.want {
+			t.Errorf("Render(%q) = %q, want %q", c.format, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package pdf menulis dokumen PDF sederhana (teks Helvetica, garis, kotak) tanpa dependensi luar.
+// Content stream tidak dikompresi dan tidak ada tanggal atau ID acak, sehingga keluaran
+// untuk input yang sama selalu identik byte per byte.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ukuran A4 dalam point
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+type Document struct {
+	Title string
+	pages []*Page
+}
+
+// Page memakai koordinat dari kiri atas (y bertambah ke bawah) dalam satuan point
+type Page struct {
+	Width, Height float64
+	content       bytes.Buffer
+}
+
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+func (d *Document) AddPage() *Page {
+	p := &Page{Width: A4Width, Height: A4Height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// escape mengubah teks ke WinAnsi dan meng-escape karakter khusus string PDF
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// Text menulis teks dengan baseline pada (x, y)
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(p.Height-y), escape(s))
+}
+
+// TextRight menulis teks rata kanan yang berakhir di x
+func (p *Page) TextRight(x, y, size float64, bold bool, s string) {
+	p.Text(x-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// Line menggambar garis dengan ketebalan width
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Rect menggambar kotak dengan pojok kiri atas (x, y); fill mengisi dengan warna abu-abu gray (0 = hitam, 1 = putih)
+func (p *Page) Rect(x, y, w, h float64, fill bool, gray float64) {
+	op := "S"
+	if fill {
+		op = "f"
+	}
+	fmt.Fprintf(&p.content, "%s g %s G %s %s %s %s re %s 0 g 0 G\n", num(gray), num(gray), num(x), num(p.Height-y-h), num(w), num(h), op)
+}
+
+// Bytes menghasilkan file PDF lengkap
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	// Objek 1: catalog, 2: pages, 3-4: font, 5: info, lalu pasangan page + content
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // diisi setelah jumlah halaman diketahui
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (farmdistribution_be) >>", escape(d.Title)),
+	}
+	var kids []string
+	for i, p := range d.pages {
+		pageObj := 6 + i*2
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				num(p.Width), num(p.Height), pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	out.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
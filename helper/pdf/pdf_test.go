@@ -0,0 +1,51 @@
+package pdf
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	build := func() []byte {
+		d := New("Invoice (test)")
+		p := d.AddPage()
+		p.Text(50, 50, 12, true, `Total (Rp) \ 100`)
+		p.Line(50, 60, 200, 60, 1)
+		p.Rect(50, 70, 10, 10, true, 0)
+		return d.Bytes()
+	}
+
+	out := build()
+	if !bytes.Equal(out, build()) {
+		t.Fatal("output is not deterministic")
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if !bytes.Contains(out, []byte(`(Total \(Rp\) \\ 100) Tj`)) {
+		t.Error("text was not escaped correctly")
+	}
+
+	// Setiap offset pada xref harus menunjuk ke awal objek
+	s := string(out)
+	xrefStart := strings.Index(s, "xref\n")
+	lines := strings.Split(s[xrefStart:], "\n")
+	for i, line := range lines[3:] {
+		if !strings.HasSuffix(line, " n ") {
+			break
+		}
+		off, _ := strconv.Atoi(line[:10])
+		want := strconv.Itoa(i+1) + " 0 obj"
+		if !strings.HasPrefix(s[off:], want) {
+			t.Errorf("xref entry %d points to %q", i+1, s[off:off+10])
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	if w := TextWidth("AB", 10, false); w != 13.34 {
+		t.Errorf("TextWidth = %v, want 13.34", w)
+	}
+}
@@ -0,0 +1,110 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Contoh 1-M "HELLO WORLD" dari spesifikasi
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	want := []int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+	for mask, w := range want {
+		if got := formatBits(mask); got != w {
+			t.Errorf("formatBits(%d) = %015b, want %015b", mask, got, w)
+		}
+	}
+}
+
+// readBack membaca ulang codeword dari matriks untuk memastikan penempatan dan mask konsisten
+func readBack(q *Code) []byte {
+	ref := newCode(q.Version)
+	ref.drawFunctionPatterns()
+
+	var bits []bool
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !ref.isFunction[y][x] {
+					bits = append(bits, q.Modules[y][x] != maskBit(q.Mask, x, y))
+				}
+			}
+		}
+	}
+	out := make([]byte, len(bits)/8)
+	for i := range out {
+		for k := 0; k < 8; k++ {
+			if bits[i*8+k] {
+				out[i] |= 1 << (7 - uint(k))
+			}
+		}
+	}
+	return out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"https://example.com/invoice/verify/ABC123",
+		"INV/F0001/2026/10/000123 " + string(bytes.Repeat([]byte("x"), 120)),
+	}
+	for _, in := range inputs {
+		q, err := Encode([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Size != q.Version*4+17 {
+			t.Fatalf("size %d does not match version %d", q.Size, q.Version)
+		}
+
+		codewords := readBack(q)
+		expected := addECCAndInterleave(encodeData([]byte(in), q.Version), q.Version)
+		if !bytes.Equal(codewords[:len(expected)], expected) {
+			t.Fatalf("version %d: codewords read back do not match", q.Version)
+		}
+
+		again, _ := Encode([]byte(in))
+		for y := range q.Modules {
+			for x := range q.Modules[y] {
+				if q.Modules[y][x] != again.Modules[y][x] {
+					t.Fatal("encoding is not deterministic")
+				}
+			}
+		}
+	}
+
+	if _, err := Encode(bytes.Repeat([]byte("x"), 300)); err != ErrTooLong {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	q := newCode(7)
+	q.drawVersion()
+	// Informasi versi 7 adalah 000111110010010100
+	want := 0x07C94
+	got := 0
+	for i := 17; i >= 0; i-- {
+		got <<= 1
+		if q.Modules[i/3][q.Size-11+i%3] {
+			got |= 1
+		}
+	}
+	if got != want {
+		t.Errorf("version bits = %018b, want %018b", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package qty
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale adalah jumlah satuan terkecil dalam satu unit (tiga angka di belakang koma)
+const Scale = 1000
+
+// Qty menyimpan kuantitas desimal secara pasti dalam perseribu unit,
+// sehingga 2.5 kg disimpan sebagai 2500 tanpa kesalahan pembulatan float
+type Qty int64
+
+var ErrInvalid = errors.New("invalid quantity")
+
+// Units adalah satuan penjualan yang didukung
+var Units = map[string]bool{
+	"kg":    true,
+	"piece": true,
+	"tray":  true,
+	"liter": true,
+}
+
+// FromInt membuat Qty dari bilangan bulat
+func FromInt(n int64) Qty {
+	return Qty(n * Scale)
+}
+
+// Parse membaca kuantitas seperti "2", "2.5" atau "0.125".
+// Lebih dari tiga angka di belakang koma dianggap tidak valid.
+func Parse(s string) (Qty, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalid
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, ErrInvalid
+	}
+	if hasDot && fracPart == "" {
+		return 0, ErrInvalid
+	}
+	if len(fracPart) > 3 {
+		// Nilai dari kolom NUMERIC bisa memiliki nol tambahan, misalnya "2.500000"
+		if strings.TrimRight(fracPart[3:], "0") != "" {
+			return 0, ErrInvalid
+		}
+		fracPart = fracPart[:3]
+	}
+
+	var whole int64
+	if intPart != "" {
+		n, err := strconv.ParseUint(intPart, 10, 63)
+		if err != nil || n > math.MaxInt64/Scale {
+			return 0, ErrInvalid
+		}
+		whole = int64(n)
+	}
+
+	var frac int64
+	if fracPart != "" {
+		n, err := strconv.ParseUint(fracPart+strings.Repeat("0", 3-len(fracPart)), 10, 16)
+		if err != nil {
+			return 0, ErrInvalid
+		}
+		frac = int64(n)
+	}
+
+	q := Qty(whole*Scale + frac)
+	if negative {
+		q = -q
+	}
+	return q, nil
+}
+
+// MustParse seperti Parse tetapi panic jika input tidak valid
+func MustParse(s string) Qty {
+	q, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String menampilkan kuantitas tanpa nol di belakang koma, misalnya "2.5"
+func (q Qty) String() string {
+	sign := ""
+	n := int64(q)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	whole, frac := n/Scale, n%Scale
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%03d", frac), "0")
+	return sign + strconv.FormatInt(whole, 10) + "." + fracStr
+}
+
+// Float64 mengubah kuantitas ke float untuk perhitungan harga dan tampilan
+func (q Qty) Float64() float64 {
+	return float64(q) / Scale
+}
+
+// Mul mengalikan kuantitas dengan faktor konversi yang juga berupa Qty,
+// hasilnya dibulatkan ke perseribu terdekat
+func (q Qty) Mul(factor Qty) Qty {
+	product := int64(q) * int64(factor)
+	half := int64(Scale / 2)
+	if product < 0 {
+		half = -half
+	}
+	return Qty((product + half) / Scale)
+}
+
+// Amount menghitung total harga untuk kuantitas ini dengan harga per unit, dibulatkan ke dua desimal
+func (q Qty) Amount(unitPrice float64) float64 {
+	return math.Round(unitPrice*float64(q)/Scale*100) / 100
+}
+
+// IsStepOf memeriksa apakah kuantitas merupakan kelipatan step
+func (q Qty) IsStepOf(step Qty) bool {
+	if step <= 0 {
+		return true
+	}
+	return q%step == 0
+}
+
+// Validate memeriksa kuantitas pesanan terhadap minimum dan kelipatan yang ditetapkan produk
+func (q Qty) Validate(min, step Qty) error {
+	if q <= 0 {
+		return fmt.Errorf("quantity must be greater than zero")
+	}
+	if q < min {
+		return fmt.Errorf("minimum quantity is %s", min)
+	}
+	if !q.IsStepOf(step) {
+		return fmt.Errorf("quantity must be a multiple of %s", step)
+	}
+	return nil
+}
+
+func (q Qty) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON menerima angka maupun string JSON, misalnya 2.5 atau "2.5"
+func (q *Qty) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		*q = 0
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalid, string(data))
+	}
+	*q = parsed
+	return nil
+}
+
+// Value menyimpan Qty ke kolom NUMERIC sebagai string desimal
+func (q Qty) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+// Scan membaca Qty dari kolom NUMERIC atau INT
+func (q *Qty) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*q = 0
+		return nil
+	case int64:
+		*q = FromInt(v)
+		return nil
+	case float64:
+		*q = Qty(math.Round(v * Scale))
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*q = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*q = parsed
+		return nil
+	}
+	return fmt.Errorf("qty: cannot scan %T", src)
+}
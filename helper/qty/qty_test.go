@@ -0,0 +1,81 @@
+package qty
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]Qty{
+		"2":        2000,
+		"2.5":      2500,
+		"0.125":    125,
+		".5":       500,
+		"2.500000": 2500,
+		"-1.25":    -1250,
+	}
+	for in, want := range cases {
+		got, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("Parse(%q) = %d, want %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1.2345", "1.", "1e3"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) expected error", in)
+		}
+	}
+}
+
+func TestStringAndJSON(t *testing.T) {
+	if s := MustParse("2.50").String(); s != "2.5" {
+		t.Errorf("String() = %q, want 2.5", s)
+	}
+
+	var p struct {
+		Quantity Qty `json:"quantity"`
+	}
+	if err := json.Unmarshal([]byte(`{"quantity":2.5}`), &p); err != nil || p.Quantity != 2500 {
+		t.Fatalf("unmarshal number: %v %d", err, p.Quantity)
+	}
+	if err := json.Unmarshal([]byte(`{"quantity":"0.75"}`), &p); err != nil || p.Quantity != 750 {
+		t.Fatalf("unmarshal string: %v %d", err, p.Quantity)
+	}
+	out, _ := json.Marshal(p)
+	if string(out) != `{"quantity":0.75}` {
+		t.Errorf("marshal = %s", out)
+	}
+}
+
+func TestMulAmountStep(t *testing.T) {
+	// 12 butir telur dengan 0.06 kg per butir = 0.72 kg stok
+	if got := FromInt(12).Mul(MustParse("0.06")); got != 720 {
+		t.Errorf("Mul = %d, want 720", got)
+	}
+	if got := MustParse("2.5").Amount(35000); got != 87500 {
+		t.Errorf("Amount = %v, want 87500", got)
+	}
+	if !MustParse("2.5").IsStepOf(MustParse("0.5")) {
+		t.Error("2.5 should be a step of 0.5")
+	}
+	if MustParse("2.3").IsStepOf(MustParse("0.5")) {
+		t.Error("2.3 should not be a step of 0.5")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	min, step := MustParse("1"), MustParse("0.5")
+	if err := MustParse("2.5").Validate(min, step); err != nil {
+		t.Errorf("2.5 should be valid: %v", err)
+	}
+	if err := MustParse("0.5").Validate(min, step); err == nil {
+		t.Error("0.5 is below the minimum")
+	}
+	if err := MustParse("1.2").Validate(min, step); err == nil {
+		t.Error("1.2 is not a multiple of 0.5")
+	}
+}
@@ -0,0 +1,136 @@
+// Package stream menyimpan event real-time terbaru di ring buffer dan membagikannya ke
+// koneksi Server-Sent Events yang sedang terbuka. Setiap pesan membawa daftar penerima
+// ("akun:<id>" atau "pengirim:<id>") sehingga satu koneksi hanya menerima pesan miliknya.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Message adalah satu event SSE. ID diambil dari id outbox sehingga sama di semua instance.
+type Message struct {
+	ID         int64           `json:"id"`
+	Event      string          `json:"event"`
+	Data       json.RawMessage `json:"data"`
+	Recipients []string        `json:"recipients"`
+}
+
+// Recipient membentuk kunci penerima dari jenis dan id pemanggil
+func Recipient(recipientType string, id int64) string {
+	return recipientType + ":" + strconv.FormatInt(id, 10)
+}
+
+func (m Message) sentTo(recipient string) bool {
+	for _, r := range m.Recipients {
+		if r == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription adalah satu koneksi yang menunggu pesan. C ditutup jika koneksi terlalu
+// lambat membaca; klien cukup menyambung ulang dengan Last-Event-ID.
+type Subscription struct {
+	C         <-chan Message
+	ch        chan Message
+	recipient string
+	broker    *Broker
+}
+
+// Close berhenti menerima pesan
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Broker membagikan pesan ke subscriber di dalam satu proses
+type Broker struct {
+	mu         sync.Mutex
+	buf        []Message
+	start      int   // Posisi pesan tertua di buf
+	evictedMax int64 // ID terbesar yang sudah tergeser dari buffer
+	subs       map[*Subscription]struct{}
+}
+
+// NewBroker membuat broker yang mengingat size pesan terakhir untuk resume
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = 1024
+	}
+	return &Broker{buf: make([]Message, 0, size), subs: map[*Subscription]struct{}{}}
+}
+
+// Publish menyimpan pesan lalu meneruskannya ke subscriber yang menjadi penerima.
+// Pesan dengan ID yang masih ada di buffer diabaikan (NOTIFY dapat terkirim dua kali).
+func (b *Broker) Publish(m Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, old := range b.buf {
+		if old.ID == m.ID {
+			return
+		}
+	}
+	if len(b.buf) < cap(b.buf) {
+		b.buf = append(b.buf, m)
+	} else {
+		if old := b.buf[b.start]; old.ID > b.evictedMax {
+			b.evictedMax = old.ID
+		}
+		b.buf[b.start] = m
+		b.start = (b.start + 1) % len(b.buf)
+	}
+
+	for s := range b.subs {
+		if !m.sentTo(s.recipient) {
+			continue
+		}
+		select {
+		case s.ch <- m:
+		default:
+			// Subscriber lambat diputus agar tidak menahan pesan untuk yang lain
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Subscribe mendaftarkan recipient dan mengembalikan pesan di buffer dengan ID > lastID.
+// complete bernilai false jika sebagian pesan setelah lastID sudah tergeser dari buffer;
+// klien sebaiknya memuat ulang data lengkapnya.
+func (b *Broker) Subscribe(recipient string, lastID int64) (backlog []Message, sub *Subscription, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	complete = lastID == 0 || lastID >= b.evictedMax
+	if lastID > 0 {
+		for i := range b.buf {
+			m := b.buf[(b.start+i)%len(b.buf)]
+			if m.ID > lastID && m.sentTo(recipient) {
+				backlog = append(backlog, m)
+			}
+		}
+	}
+	ch := make(chan Message, 64)
+	sub = &Subscription{C: ch, ch: ch, recipient: recipient, broker: b}
+	b.subs[sub] = struct{}{}
+	return backlog, sub, complete
+}
+
+// WriteEvent menulis pesan dalam format text/event-stream
+func WriteEvent(w io.Writer, m Message) error {
+	data := m.Data
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", m.ID, m.Event, data)
+	return err
+}
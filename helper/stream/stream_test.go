@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func msg(id int64, to ...string) Message {
+	return Message{ID: id, Event: "order", Data: json.RawMessage(`{"n":1}`), Recipients: to}
+}
+
+func TestPublishFiltersByRecipient(t *testing.T) {
+	b := NewBroker(10)
+	_, buyer, _ := b.Subscribe("akun:1", 0)
+	_, other, _ := b.Subscribe("akun:2", 0)
+	defer buyer.Close()
+	defer other.Close()
+
+	b.Publish(msg(1, "akun:1", "pengirim:7"))
+
+	if m := <-buyer.C; m.ID != 1 {
+		t.Errorf("buyer got %+v", m)
+	}
+	select {
+	case m := <-other.C:
+		t.Errorf("unrelated subscriber got %+v", m)
+	default:
+	}
+}
+
+func TestResume(t *testing.T) {
+	b := NewBroker(3)
+	for id := int64(1); id <= 4; id++ {
+		b.Publish(msg(id, "akun:1"))
+	}
+	b.Publish(msg(4, "akun:1")) // duplikat dari instance lain
+
+	backlog, sub, complete := b.Subscribe("akun:1", 2)
+	sub.Close()
+	if !complete || len(backlog) != 2 || backlog[0].ID != 3 || backlog[1].ID != 4 {
+		t.Errorf("resume from 2: complete=%v backlog=%+v", complete, backlog)
+	}
+
+	backlog, sub, complete = b.Subscribe("akun:1", 0)
+	sub.Close()
+	if !complete || len(backlog) != 0 {
+		t.Errorf("fresh subscribe should not replay: complete=%v backlog=%+v", complete, backlog)
+	}
+
+	// ID 1 sudah tergeser, sehingga resume dari 0 < lastID < 1 tidak mungkin lengkap
+	b.Publish(msg(5, "akun:1"))
+	_, sub, complete = b.Subscribe("akun:1", 1)
+	sub.Close()
+	if complete {
+		t.Error("resume past evicted messages reported complete")
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroker(200)
+	_, sub, _ := b.Subscribe("akun:1", 0)
+	for id := int64(1); id <= 100; id++ {
+		b.Publish(msg(id, "akun:1"))
+	}
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n == 0 || n == 100 {
+		t.Errorf("received %d messages before being dropped", n)
+	}
+	sub.Close() // aman dipanggil setelah diputus
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteEvent(&buf, msg(9)); err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 9\nevent: order\ndata: {\"n\":1}\n\n"
+	if buf.String() != want {
+		t.Errorf("got %q", buf.String())
+	}
+}
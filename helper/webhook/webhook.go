@@ -0,0 +1,209 @@
+// Package webhook mengirim event ke endpoint HTTP milik peternakan. Setiap request
+// ditandatangani HMAC-SHA256 atas "<timestamp>.<body>" dengan secret endpoint, dikirim
+// pada header X-Webhook-Signature dengan format "t=<unix>,v1=<hex>".
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Header yang dikirim bersama setiap delivery
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Jenis event webhook
+const (
+	OrderCreated    = "order.created"
+	PaymentApproved = "payment.approved"
+	ShipmentUpdated = "shipment.updated"
+	ProductLowStock = "product.low_stock"
+)
+
+// EventTypes adalah semua jenis event yang dapat dilanggan
+var EventTypes = []string{OrderCreated, PaymentApproved, ShipmentUpdated, ProductLowStock}
+
+// ValidEventType memeriksa apakah t termasuk EventTypes
+func ValidEventType(t string) bool {
+	for _, e := range EventTypes {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrBlockedAddress dikembalikan jika tujuan webhook adalah alamat internal
+var ErrBlockedAddress = errors.New("webhook: destination address is not allowed")
+
+// ValidateURL memastikan URL endpoint berupa https absolut yang tidak menunjuk ke alamat
+// internal. Nama host diperiksa lagi saat dial (lihat NewClient) karena DNS dapat berubah.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Scheme != "https" {
+		return errors.New("url must be an absolute https URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return errors.New("url must not point to an internal host")
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+		return errors.New("url must not point to a loopback, private, link-local or metadata address")
+	}
+	return nil
+}
+
+// sharedAddressSpace adalah 100.64.0.0/10 (CGNAT), tidak tercakup net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// blockedIP melaporkan alamat yang tidak boleh dihubungi webhook: loopback, private,
+// link-local (termasuk metadata cloud 169.254.169.254), unspecified dan multicast
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) || (ip.To4() != nil && ip.To4()[0] == 0)
+}
+
+// dialControl menolak koneksi ke alamat internal setelah DNS di-resolve, sehingga nama host
+// yang berganti alamat (DNS rebinding) tetap tidak dapat menjangkau jaringan internal
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || blockedIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// NewClient membuat http.Client untuk webhook: alamat tujuan diperiksa saat dial, proxy
+// lingkungan diabaikan dan redirect tidak diikuti (respons 3xx dianggap gagal)
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialControl}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NewSecret membuat secret acak untuk endpoint baru
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign menghasilkan nilai header X-Webhook-Signature
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify memeriksa header tanda tangan; dipakai penerima (dan pengujian).
+// tolerance membatasi umur request untuk mencegah replay; 0 berarti tidak diperiksa.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			sig = kv[1]
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("webhook: malformed signature header")
+	}
+	if tolerance > 0 {
+		if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return errors.New("webhook: timestamp outside tolerance")
+		}
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errors.New("webhook: signature mismatch")
+	}
+	return nil
+}
+
+var defaultClient = NewClient()
+
+// Result adalah hasil satu percobaan pengiriman
+type Result struct {
+	StatusCode int
+	Body       string // Potongan isi respons, maksimal 1 KB
+	Duration   time.Duration
+}
+
+// Deliver mengirim body ke url. Respons selain 2xx dianggap gagal.
+// client nil berarti NewClient, yang menolak alamat internal.
+func Deliver(ctx context.Context, client *http.Client, endpointURL, secret, eventType, deliveryID string, body []byte) (Result, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "farmdistribution-webhook/1")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderSignature, Sign(secret, time.Now(), body))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	result.StatusCode = resp.StatusCode
+	result.Body = string(b)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, fmt.Errorf("webhook: endpoint responded %s", resp.Status)
+	}
+	return result, nil
+}
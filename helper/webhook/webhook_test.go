@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeliverSignsRequest(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"order.created"}`)
+
+	var gotErr error
+	var gotEvent, gotDelivery string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotErr = Verify(secret, r.Header.Get(HeaderSignature), b, 5*time.Minute, time.Now())
+		gotEvent = r.Header.Get(HeaderEvent)
+		gotDelivery = r.Header.Get(HeaderDelivery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	res, err := Deliver(context.Background(), receiver.Client(), receiver.URL, secret, OrderCreated, "42", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d", res.StatusCode)
+	}
+	if gotErr != nil {
+		t.Errorf("receiver could not verify signature: %v", gotErr)
+	}
+	if gotEvent != OrderCreated || gotDelivery != "42" {
+		t.Errorf("headers: event=%q delivery=%q", gotEvent, gotDelivery)
+	}
+}
+
+func TestDeliverFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	res, err := Deliver(context.Background(), receiver.Client(), receiver.URL, "s", OrderCreated, "1", []byte("{}"))
+	if err == nil {
+		t.Fatal("non-2xx response should fail")
+	}
+	if res.StatusCode != http.StatusInternalServerError || res.Body != "boom\n" {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte("payload")
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, time.Minute, now); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := Verify("other", header, body, time.Minute, now); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := Verify("secret", header, []byte("tampered"), time.Minute, now); err == nil {
+		t.Error("tampered body accepted")
+	}
+	if err := Verify("secret", header, body, time.Minute, now.Add(2*time.Minute)); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+	if err := Verify("secret", "garbage", body, 0, now); err == nil {
+		t.Error("malformed header accepted")
+	}
+}
+
+func TestDeliverBlocksInternalAddress(t *testing.T) {
+	reached := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer receiver.Close()
+
+	// Client bawaan memeriksa alamat saat dial; httptest mendengarkan di loopback
+	_, err := Deliver(context.Background(), nil, receiver.URL, "s", OrderCreated, "1", []byte("{}"))
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("err = %v, want ErrBlockedAddress", err)
+	}
+	if reached {
+		t.Error("request reached a loopback receiver")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/hook", "https://93.184.216.34:8443/x"} {
+		if err := ValidateURL(u); err != nil {
+			t.Errorf("%q rejected: %v", u, err)
+		}
+	}
+	for _, u := range []string{"", "example.com", "ftp://example.com", "/relative", "http://example.com/hook",
+		"https://10.0.0.1:8080/x", "https://127.0.0.1/", "https://[::1]/", "https://169.254.169.254/latest/meta-data",
+		"https://localhost/", "https://metadata.google.internal/", "https://100.64.0.1/"} {
+		if err := ValidateURL(u); err == nil {
+			t.Errorf("%q accepted", u)
+		}
+	}
+}
@@ -1,18 +1,53 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"farmdistribution_be/config"
+	"farmdistribution_be/controller/realtime"
+	"farmdistribution_be/helper/audit"
 	"farmdistribution_be/routes"
+	"farmdistribution_be/worker"
 )
 
 func main() {
+	// "go run . worker" hanya menjalankan job latar belakang tanpa server HTTP
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Println("Job worker is running")
+		if err := worker.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// IP klien di audit log hanya diambil dari header proxy yang terdaftar
+	if err := audit.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
+
 	// Inisialisasi router
 	router := routes.InitializeRoutes()
 
+	// Setiap instance mendengarkan NOTIFY agar koneksi /stream menerima event dari instance mana pun
+	go realtime.Listen(context.Background())
+
+	// Job latar belakang (mis. invoice yang tidak dibayar sampai due_date otomatis Expired)
+	if config.JobsInProcess {
+		go func() {
+			if err := worker.Run(context.Background()); err != nil {
+				log.Println("[ERROR] Job worker stopped:", err)
+			}
+		}()
+	}
+
 	// Baca port dari environment variable
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"farmdistribution_be/helper/qty"
+	"time"
+)
+
+type CartItem struct {
+	ProductID         int       `json:"product_id"`
+	ProductName       string    `json:"product_name"`
+	ImageURL          string    `json:"image_url"`
+	FarmID            int64     `json:"farm_id"`
+	FarmName          string    `json:"farm_name"`
+	Quantity          qty.Qty   `json:"quantity"`
+	Unit              string    `json:"unit"`
+	MinQty            qty.Qty   `json:"min_qty"`
+	StepQty           qty.Qty   `json:"step_qty"`
+	PriceAtAdd        float64   `json:"price_at_add"`
+	CurrentPrice      float64   `json:"current_price"`
+	StockKg           float64   `json:"stock_kg"`
+	StatusName        string    `json:"status_name"`
+	LineTotal         float64   `json:"line_total"`
+	Available         bool      `json:"available"`
+	PriceChanged      bool      `json:"price_changed"`
+	OutOfStock        bool      `json:"out_of_stock"`
+	InsufficientStock bool      `json:"insufficient_stock"`
+	InvalidQuantity   bool      `json:"invalid_quantity"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"farmdistribution_be/helper/qty"
+	"time"
+)
 
 type Order struct {
 	ID            int       `json:"id"`
@@ -19,8 +22,8 @@ type Order struct {
 }
 
 type Product struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int     `json:"product_id"`
+	Quantity  qty.Qty `json:"quantity"`
 }
 
 type Invoice struct {
@@ -36,3 +39,74 @@ type Invoice struct {
 	UpdatedAt         time.Time `json:"updated_at"`
 	TotalHargaProduct float64   `json:"total_harga_product"`
 }
+
+// CheckoutRequest adalah payload keranjang yang dipakai bersama oleh CreateOrder dan quote checkout
+type CheckoutRequest struct {
+	QuoteID          string    `json:"quote_id,omitempty"`
+	FromCart         bool      `json:"from_cart,omitempty"`
+	Products         []Product `json:"products"`
+	PengirimanID     int       `json:"pengiriman_id"`
+	PaymentMethod    string    `json:"payment_method"`
+	AlamatPengirim   string    `json:"alamat_pengirim"`
+	AlamatPenerima   string    `json:"alamat_penerima"`
+	LocationPengirim []float64 `json:"location_pengirim"`
+	LocationPenerima []float64 `json:"location_penerima"`
+	VoucherCode      string    `json:"voucher_code,omitempty"`
+}
+
+type QuoteLine struct {
+	ProductID   int64   `json:"product_id"`
+	FarmID      int64   `json:"farm_id"`
+	ProductName string  `json:"product_name"`
+	ImageURL    string  `json:"image_url"`
+	Unit        string  `json:"unit"`
+	UnitPrice   float64 `json:"unit_price"`
+	Quantity    qty.Qty `json:"quantity"`
+	StockQty    qty.Qty `json:"stock_quantity"` // Jumlah stok (kg) yang dikurangi untuk baris ini
+	LineTotal   float64 `json:"line_total"`
+	// Produk catch-weight ditagih ulang berdasarkan berat timbang, LineTotal hanya estimasi
+	CatchWeight     bool    `json:"catch_weight"`
+	PricePerKg      float64 `json:"price_per_kg,omitempty"`
+	WeightTolerance float64 `json:"weight_tolerance_percent,omitempty"`
+}
+
+type QuoteFarm struct {
+	FarmID       int64   `json:"farm_id"`
+	FarmName     string  `json:"farm_name"`
+	DistanceKM   float64 `json:"distance_km"`
+	Subtotal     float64 `json:"subtotal"`
+	ShippingCost float64 `json:"shipping_cost"`
+	Discount     float64 `json:"discount"`
+	Tax          float64 `json:"tax"`
+	Total        float64 `json:"total"`
+}
+
+type Quote struct {
+	ID            string      `json:"quote_id"`
+	Lines         []QuoteLine `json:"lines"`
+	Farms         []QuoteFarm `json:"farms"`
+	VoucherCode   string      `json:"voucher_code,omitempty"`
+	Subtotal      float64     `json:"subtotal"`
+	ShippingTotal float64     `json:"shipping_total"`
+	DiscountTotal float64     `json:"discount_total"`
+	TaxRate       float64     `json:"tax_rate"`
+	TaxTotal      float64     `json:"tax_total"`
+	GrandTotal    float64     `json:"grand_total"`
+	ExpiresAt     time.Time   `json:"expires_at"`
+}
+
+// InvoiceAdjustment mencatat selisih tagihan akibat penimbangan ulang produk catch-weight
+type InvoiceAdjustment struct {
+	ID                int64      `json:"id"`
+	InvoiceID         int64      `json:"invoice_id"`
+	OrderID           int64      `json:"order_id"`
+	EstimatedWeightKg qty.Qty    `json:"estimated_weight_kg"`
+	ActualWeightKg    qty.Qty    `json:"actual_weight_kg"`
+	ChargedWeightKg   qty.Qty    `json:"charged_weight_kg"`
+	Amount            float64    `json:"amount"`
+	TaxAmount         float64    `json:"tax_amount"`
+	Type              string     `json:"type"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	SettledAt         *time.Time `json:"settled_at"`
+}
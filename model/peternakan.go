@@ -1,6 +1,6 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import "time"
 
 type Peternakan struct {
 	User_id            int64   `json:"user_id"`
@@ -37,16 +37,53 @@ type Farms struct {
 	Description     string  `json:"description"`
 }
 
-type ReqPeternakan struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	User_id    int64              `json:"user_id"`
-	Keterangan string             `json:"keterangan"`
+// FarmApplication adalah pengajuan akun menjadi peternak beserta data peternakan yang diusulkan
+type FarmApplication struct {
+	ID              int64                     `json:"id"`
+	UserID          int64                     `json:"user_id"`
+	NamaAkun        string                    `json:"nama_akun,omitempty"`
+	NoTelp          string                    `json:"no_telp,omitempty"`
+	Status          string                    `json:"status"`
+	Keterangan      string                    `json:"keterangan"`
+	Name            string                    `json:"name"`
+	FarmType        string                    `json:"farm_type"`
+	PhonenumberFarm string                    `json:"phonenumber_farm"`
+	Email           string                    `json:"email"`
+	Description     string                    `json:"description"`
+	Street          string                    `json:"street"`
+	City            string                    `json:"city"`
+	State           string                    `json:"state"`
+	PostalCode      string                    `json:"postal_code"`
+	Country         string                    `json:"country"`
+	Lat             float64                   `json:"lat"`
+	Lon             float64                   `json:"lon"`
+	RejectionReason *string                   `json:"rejection_reason"`
+	ReviewerID      *int64                    `json:"reviewer_id"`
+	ReviewedAt      *time.Time                `json:"reviewed_at"`
+	FarmID          *int64                    `json:"farm_id"` // Terisi setelah disetujui
+	CreatedAt       time.Time                 `json:"created_at"`
+	UpdatedAt       time.Time                 `json:"updated_at"`
+	Documents       []FarmApplicationDocument `json:"documents,omitempty"`
+	History         []FarmApplicationEvent    `json:"history,omitempty"`
 }
 
-type ResReqPeternakan struct {
-	ID string `json:"id"`
-	ReqPeternakan
-	NamaAkun string `json:"nama_akun"`
-	NoTelp   string `json:"no_telp"`
-	Email    string `json:"email"`
+// FarmApplicationDocument adalah dokumen pendukung pengajuan (KTP, foto peternakan, izin usaha)
+type FarmApplicationDocument struct {
+	ID         int64     `json:"id"`
+	DocType    string    `json:"doc_type"`
+	URL        string    `json:"url"`
+	FileName   string    `json:"file_name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// FarmApplicationEvent adalah perubahan status atau komentar pada pengajuan
+type FarmApplicationEvent struct {
+	ID         int64     `json:"id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int64    `json:"actor_id"`
+	ActorName  *string   `json:"actor_name"`
+	ActorRole  string    `json:"actor_role"` // applicant atau reviewer
+	Comment    *string   `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
 }
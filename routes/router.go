@@ -2,15 +2,20 @@ package routes
 
 import (
 	"farmdistribution_be/controller"
+	"farmdistribution_be/controller/admin"
 	"farmdistribution_be/controller/akun"
 	"farmdistribution_be/controller/alamat"
 	"farmdistribution_be/controller/auth"
+	"farmdistribution_be/controller/cart"
 	"farmdistribution_be/controller/image"
+	"farmdistribution_be/controller/notification"
 	"farmdistribution_be/controller/order"
 	"farmdistribution_be/controller/peternakan"
 	"farmdistribution_be/controller/profile"
 	"farmdistribution_be/controller/radius"
+	"farmdistribution_be/controller/realtime"
 	"farmdistribution_be/controller/role"
+	"farmdistribution_be/controller/webhook"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -69,12 +74,27 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/peternakan/update", handleCORS(peternakan.UpdatePeternakan)).Methods("PUT", "OPTIONS")
 	router.HandleFunc("/peternakan/delete", handleCORS(peternakan.DeletePeternakan)).Methods("DELETE", "OPTIONS")
 	router.HandleFunc("/all/peternak", handleCORS(peternakan.GetAllPeternak)).Methods("GET", "OPTIONS")
-	router.HandleFunc("/req/peternak", handleCORS(peternakan.ReqPeternak)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/get/req/peternak", handleCORS(peternakan.GetReqPeternakan)).Methods("GET", "OPTIONS")
-	router.HandleFunc("/delete/req/peternak", handleCORS(peternakan.DeleteReqPeternakan)).Methods("DELETE", "OPTIONS")
-	router.HandleFunc("/update/req/peternak", handleCORS(peternakan.UpdateRole)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/req/peternak", handleCORS(peternakan.SubmitFarmApplication)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/get/req/peternak", handleCORS(peternakan.GetFarmApplications)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/status/peternak", handleCORS(peternakan.CekUsers)).Methods("GET", "OPTIONS")
 
+	// Pengajuan peternak
+	router.HandleFunc("/farm-applications", handleCORS(peternakan.SubmitFarmApplication)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/farm-applications/mine", handleCORS(peternakan.GetMyFarmApplication)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/farm-applications/{id:[0-9]+}", handleCORS(peternakan.GetFarmApplicationByID)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/farm-applications/{id:[0-9]+}", handleCORS(peternakan.ResubmitFarmApplication)).Methods("PUT", "OPTIONS")
+
+	// Anggota peternakan
+	router.HandleFunc("/farm/members", handleCORS(peternakan.GetFarmMembers)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/farm/members/{akun_id:[0-9]+}", handleCORS(peternakan.UpdateFarmMember)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/farm/members/{akun_id:[0-9]+}", handleCORS(peternakan.RemoveFarmMember)).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/farm/invitations", handleCORS(peternakan.CreateFarmInvitation)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/farm/invitations", handleCORS(peternakan.GetFarmInvitations)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/farm/invitations/mine", handleCORS(peternakan.GetMyFarmInvitations)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/farm/invitations/{id:[0-9]+}", handleCORS(peternakan.RevokeFarmInvitation)).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/farm/invitations/{id:[0-9]+}/accept", handleCORS(peternakan.AcceptFarmInvitation)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/farm/invitations/{id:[0-9]+}/decline", handleCORS(peternakan.DeclineFarmInvitation)).Methods("POST", "OPTIONS")
+
 	// Status Product
 	router.HandleFunc("/status-product", handleCORS(peternakan.CreateStatusProduct)).Methods("POST", "OPTIONS")
 	router.HandleFunc("/status-product/get", handleCORS(peternakan.GetAllStatusProducts)).Methods("GET", "OPTIONS")
@@ -90,6 +110,9 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/product/farm", handleCORS(peternakan.GetAllProductsByFarm)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/product/get/", handleCORS(peternakan.GetProductById)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/product/delete", handleCORS(peternakan.DeleteProduk)).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/product-categories", handleCORS(peternakan.GetProductCategories)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/product/{id:[0-9]+}/stock-movements", handleCORS(peternakan.GetStockMovements)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/product/{id:[0-9]+}/stock-movements", handleCORS(peternakan.CreateStockMovement)).Methods("POST", "OPTIONS")
 
 	// Order
 	router.HandleFunc("/order", handleCORS(order.CreateOrder)).Methods("POST", "OPTIONS")
@@ -99,6 +122,41 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/order/update", handleCORS(order.UpdateOrderStatus)).Methods("PUT", "OPTIONS")
 	router.HandleFunc("/order/delete", handleCORS(order.DeleteOrderByInvoiceID)).Methods("DELETE", "OPTIONS")
 	router.HandleFunc("/order/bukti-transfer", handleCORS(order.BuktiTransfer)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/order/payment/verify", handleCORS(order.VerifyPayment)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/order/weigh", handleCORS(order.WeighOrder)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/order/{invoice}/history", handleCORS(order.GetOrderHistory)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/order/adjustment/settle", handleCORS(order.SettleInvoiceAdjustment)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/invoice/{id}/pdf", handleCORS(order.InvoicePDF)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/invoice/verify/{code}", handleCORS(order.VerifyInvoice)).Methods("GET", "OPTIONS")
+
+	// Checkout
+	router.HandleFunc("/checkout/quote", handleCORS(order.QuoteCheckout)).Methods("POST", "OPTIONS")
+
+	// Keranjang
+	router.HandleFunc("/cart", handleCORS(cart.GetCart)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/cart/add", handleCORS(cart.AddToCart)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/cart/update", handleCORS(cart.UpdateCartItem)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/cart/remove", handleCORS(cart.RemoveCartItem)).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/cart/clear", handleCORS(cart.ClearCart)).Methods("DELETE", "OPTIONS")
+
+	// Notifikasi
+	router.HandleFunc("/notification/preferences", handleCORS(notification.GetPreferences)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/notification/preferences", handleCORS(notification.UpdatePreferences)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/notifications", handleCORS(notification.GetNotifications)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/notifications/unread-count", handleCORS(notification.GetUnreadCount)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/notifications/read-all", handleCORS(notification.MarkAllNotificationsRead)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/notifications/{id:[0-9]+}/read", handleCORS(notification.MarkNotificationRead)).Methods("PUT", "OPTIONS")
+
+	// Update status order, pembayaran dan pengiriman secara real-time (Server-Sent Events)
+	router.HandleFunc("/stream", handleCORS(realtime.Stream)).Methods("GET", "OPTIONS")
+
+	// Webhook peternakan
+	router.HandleFunc("/webhooks", handleCORS(webhook.GetWebhooks)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/webhooks", handleCORS(webhook.CreateWebhook)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/webhooks/{id:[0-9]+}", handleCORS(webhook.UpdateWebhook)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/webhooks/{id:[0-9]+}", handleCORS(webhook.DeleteWebhook)).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", handleCORS(webhook.GetWebhookDeliveries)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/webhooks/deliveries/{id:[0-9]+}/replay", handleCORS(webhook.ReplayWebhookDelivery)).Methods("POST", "OPTIONS")
 
 	// get toko by location and radius
 	router.HandleFunc("/toko", handleCORS(radius.GetAllTokoByRadius)).Methods("GET", "OPTIONS")
@@ -121,7 +179,7 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/pengirim/get/", handleCORS(order.GetPengirimByID)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/pengirim/update", handleCORS(order.UpdatePengirim)).Methods("PUT", "OPTIONS")
 	router.HandleFunc("/pengirim/delete", handleCORS(order.DeletePengirim)).Methods("DELETE", "OPTIONS")
-	router.HandleFunc("/pengirim/all", handleCORS(order.GetAllPengirim)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/pengirim/all", handleCORS(order.GetAllPengirim)).Methods("GET", "OPTIONS") // Khusus admin
 
 	// proses pengiriman
 	router.HandleFunc("/proses-pengiriman", handleCORS(order.GetAllProsesPengiriman)).Methods("GET", "OPTIONS")
@@ -129,6 +187,17 @@ func InitializeRoutes() *mux.Router {
 	router.HandleFunc("/proses-pengiriman/edit/{id}", handleCORS(order.UpdateProsesPengiriman)).Methods("PUT", "OPTIONS")
 	router.HandleFunc("/proses-pengiriman/peternak/", handleCORS(order.GetAllProsesPengirimanPeternak)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/proses-pengiriman/pengirim/", handleCORS(order.GetAllProsesPengirimanPengirim)).Methods("GET", "OPTIONS")
+
+	// admin
+	router.HandleFunc("/admin/jobs", handleCORS(admin.GetJobs)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/jobs/failures", handleCORS(admin.GetJobFailures)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/audit", handleCORS(admin.GetAuditLog)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/inventory/drift", handleCORS(admin.GetInventoryDrift)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/farm-applications", handleCORS(peternakan.GetFarmApplications)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/farm-applications/{id:[0-9]+}/review", handleCORS(peternakan.ReviewFarmApplication)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/product-categories", handleCORS(peternakan.CreateProductCategory)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/product-categories/{id:[0-9]+}", handleCORS(peternakan.UpdateProductCategory)).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/admin/product-categories/{id:[0-9]+}", handleCORS(peternakan.DeleteProductCategory)).Methods("DELETE", "OPTIONS")
 	return router
 }
 
@@ -143,7 +212,7 @@ func handleCORS(next http.HandlerFunc) http.HandlerFunc {
 				w.Header().Set("Access-Control-Allow-Origin", "*")
 			}
 			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, login")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, login, X-Farm-ID")
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -155,7 +224,7 @@ func handleCORS(next http.HandlerFunc) http.HandlerFunc {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, login")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, login, X-Farm-ID")
 
 		// Lanjutkan ke handler berikutnya
 		next(w, r)
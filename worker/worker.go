@@ -0,0 +1,46 @@
+// Package worker menyatukan pendaftaran job latar belakang dan subscriber event lalu
+// menjalankan worker dan dispatcher outbox, baik di dalam server HTTP maupun sebagai
+// proses terpisah ("worker").
+package worker
+
+import (
+	"context"
+	"farmdistribution_be/config"
+	"farmdistribution_be/controller/notification"
+	"farmdistribution_be/controller/order"
+	"farmdistribution_be/controller/peternakan"
+	"farmdistribution_be/controller/realtime"
+	"farmdistribution_be/controller/webhook"
+	"farmdistribution_be/helper/events"
+	"farmdistribution_be/helper/jobs"
+)
+
+// Register mendaftarkan semua handler job, jadwal berulang dan subscriber event
+func Register() error {
+	notification.RegisterSubscribers()
+	realtime.RegisterSubscribers()
+	webhook.RegisterSubscribers()
+	webhook.RegisterJobs()
+	if err := peternakan.RegisterJobs(); err != nil {
+		return err
+	}
+	return order.RegisterJobs()
+}
+
+// Run mendaftarkan job lalu menjalankan worker sampai ctx selesai
+func Run(ctx context.Context) error {
+	if err := Register(); err != nil {
+		return err
+	}
+	sqlDB, err := config.PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	// Dispatcher outbox berjalan bersama worker job
+	d := &events.Dispatcher{DB: sqlDB}
+	go d.Run(ctx)
+
+	w := &jobs.Worker{DB: sqlDB, Concurrency: config.JobWorkers}
+	w.Run(ctx)
+	return nil
+}